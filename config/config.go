@@ -0,0 +1,390 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is everything the service reads at startup. Values come from the
+// defaults below, then the file named by CONFIG_FILE, then the environment.
+// Drain_delay is how long readiness fails before the server stops taking
+// connections, so the load balancer notices first.
+type Config struct {
+	Port             int           `yaml:"port" toml:"port"`
+	Shutdown_timeout Duration      `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	Drain_delay      Duration      `yaml:"drain_delay" toml:"drain_delay"`
+	Timezone         string        `yaml:"timezone" toml:"timezone"`
+	Mongo            MongoConfig   `yaml:"mongo" toml:"mongo"`
+	Log              LogConfig     `yaml:"log" toml:"log"`
+	Cors             CorsConfig    `yaml:"cors" toml:"cors"`
+	Auth             AuthConfig    `yaml:"auth" toml:"auth"`
+	Pricing          PricingConfig `yaml:"pricing" toml:"pricing"`
+}
+
+// MongoConfig.Connect_timeout is how long startup keeps retrying before it
+// gives up on MongoDB.
+type MongoConfig struct {
+	Url             string   `yaml:"url" toml:"url"`
+	Database        string   `yaml:"database" toml:"database"`
+	Connect_timeout Duration `yaml:"connect_timeout" toml:"connect_timeout"`
+}
+
+// LogConfig points at the Logstash TCP input. Entries go to Fallback, which
+// is "stdout", "stderr" or a file path, while Logstash cannot be reached or
+// when no address is set.
+type LogConfig struct {
+	Address    string `yaml:"address" toml:"address"`
+	Fallback   string `yaml:"fallback" toml:"fallback"`
+	Queue_size int    `yaml:"queue_size" toml:"queue_size"`
+}
+
+type CorsConfig struct {
+	Origins []string `yaml:"origins" toml:"origins"`
+}
+
+// AuthConfig.Owner_email is the account that gets the OWNER role on start,
+// once it has signed up. Sign up itself always makes a waiter, and everyone
+// else is promoted by the owner.
+type AuthConfig struct {
+	Secret_key             string   `yaml:"secret_key" toml:"secret_key"`
+	Owner_email            string   `yaml:"owner_email" toml:"owner_email"`
+	Token_lifetime         Duration `yaml:"token_lifetime" toml:"token_lifetime"`
+	Refresh_token_lifetime Duration `yaml:"refresh_token_lifetime" toml:"refresh_token_lifetime"`
+}
+
+type PricingConfig struct {
+	Currency            string             `yaml:"currency" toml:"currency"`
+	Tax_rate            float64            `yaml:"tax_rate" toml:"tax_rate"`
+	Category_tax_rates  map[string]float64 `yaml:"category_tax_rates" toml:"category_tax_rates"`
+	Service_charge_rate float64            `yaml:"service_charge_rate" toml:"service_charge_rate"`
+	Size_multipliers    map[string]float64 `yaml:"size_multipliers" toml:"size_multipliers"`
+	Target_margin       float64            `yaml:"target_margin" toml:"target_margin"`
+}
+
+// Duration is written the way time.ParseDuration reads it, e.g. "24h" or "90m".
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(strings.TrimSpace(string(text)))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Settings is loaded once, before anything connects to MongoDB or Logstash.
+// An invalid configuration stops the service rather than running half set up.
+var Settings = mustLoad()
+
+func mustLoad() Config {
+	config, err := Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	return config
+}
+
+func Defaults() Config {
+	return Config{
+		Port:             8000,
+		Shutdown_timeout: Duration{15 * time.Second},
+		Drain_delay:      Duration{5 * time.Second},
+		Mongo:            MongoConfig{Url: "mongodb://mongo:27017", Database: "restaurant", Connect_timeout: Duration{time.Minute}},
+		Log:              LogConfig{Address: "logstash:5000", Fallback: "stdout", Queue_size: 10000},
+		Cors:             CorsConfig{Origins: []string{"http://localhost:5173"}},
+		Auth: AuthConfig{
+			Token_lifetime:         Duration{24 * time.Hour},
+			Refresh_token_lifetime: Duration{168 * time.Hour},
+		},
+		Pricing: PricingConfig{
+			Currency:           "USD",
+			Category_tax_rates: map[string]float64{},
+			Size_multipliers:   map[string]float64{"S": 0.8, "M": 1, "L": 1.25},
+			Target_margin:      0.7,
+		},
+	}
+}
+
+// Load reads the optional YAML or TOML file at path over the defaults,
+// applies the environment on top and checks the result.
+func Load(path string) (Config, error) {
+	config := Defaults()
+
+	if path != "" {
+		if err := config.readFile(path); err != nil {
+			return config, err
+		}
+	}
+	if err := config.readEnv(); err != nil {
+		return config, err
+	}
+	config.normalize()
+	return config, config.Validate()
+}
+
+func (config *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, config)
+	case ".toml":
+		err = toml.Unmarshal(data, config)
+	default:
+		return fmt.Errorf("%s: config files must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+// readEnv applies every variable that is set, reporting all the malformed
+// ones together.
+func (config *Config) readEnv() error {
+	var errs []error
+	envString("TIMEZONE", &config.Timezone)
+	envString("MONGO_URL", &config.Mongo.Url)
+	envString("MONGO_DATABASE", &config.Mongo.Database)
+	envString("LOGSTASH_ADDRESS", &config.Log.Address)
+	envString("LOG_FALLBACK", &config.Log.Fallback)
+	envString("SECRET_KEY", &config.Auth.Secret_key)
+	envString("OWNER_EMAIL", &config.Auth.Owner_email)
+	envString("CURRENCY", &config.Pricing.Currency)
+	if value := os.Getenv("CORS_ORIGINS"); value != "" {
+		config.Cors.Origins = nil
+		for _, origin := range strings.Split(value, ",") {
+			config.Cors.Origins = append(config.Cors.Origins, strings.TrimSpace(origin))
+		}
+	}
+
+	errs = append(errs,
+		envInt("PORT", &config.Port),
+		envDuration("SHUTDOWN_TIMEOUT", &config.Shutdown_timeout),
+		envDuration("DRAIN_DELAY", &config.Drain_delay),
+		envDuration("MONGO_CONNECT_TIMEOUT", &config.Mongo.Connect_timeout),
+		envInt("LOG_QUEUE_SIZE", &config.Log.Queue_size),
+		envDuration("TOKEN_LIFETIME", &config.Auth.Token_lifetime),
+		envDuration("REFRESH_TOKEN_LIFETIME", &config.Auth.Refresh_token_lifetime),
+		envFloat("TAX_RATE", &config.Pricing.Tax_rate),
+		envRates("CATEGORY_TAX_RATES", &config.Pricing.Category_tax_rates),
+		envFloat("SERVICE_CHARGE_RATE", &config.Pricing.Service_charge_rate),
+		envRates("SIZE_MULTIPLIERS", &config.Pricing.Size_multipliers),
+		envFloat("TARGET_MARGIN", &config.Pricing.Target_margin),
+	)
+	return errors.Join(errs...)
+}
+
+// normalize upper-cases the codes and lower-cases the email that are looked
+// up case-insensitively.
+func (config *Config) normalize() {
+	config.Auth.Owner_email = strings.ToLower(strings.TrimSpace(config.Auth.Owner_email))
+	config.Pricing.Currency = strings.ToUpper(strings.TrimSpace(config.Pricing.Currency))
+	config.Pricing.Category_tax_rates = upperKeys(config.Pricing.Category_tax_rates)
+	config.Pricing.Size_multipliers = upperKeys(config.Pricing.Size_multipliers)
+}
+
+// MIN_SECRET_KEY_LENGTH is the shortest key tokens may be signed with; 32
+// bytes matches the HS256 output size.
+const MIN_SECRET_KEY_LENGTH = 32
+
+// RequireSecrets fails when tokens could be forged because the signing key
+// is missing or short. It is checked by main at startup rather than in
+// Validate, so packages that import config load without a key in tests.
+func (config Config) RequireSecrets() error {
+	if config.Auth.Secret_key == "" {
+		return errors.New("secret key is required, set SECRET_KEY")
+	}
+	if len(config.Auth.Secret_key) < MIN_SECRET_KEY_LENGTH {
+		return fmt.Errorf("secret key must be at least %d characters", MIN_SECRET_KEY_LENGTH)
+	}
+	return nil
+}
+
+// Validate reports every problem at once so a deploy is fixed in one go.
+func (config Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if config.Port < 1 || config.Port > 65535 {
+		fail("port %d is out of range", config.Port)
+	}
+	if config.Shutdown_timeout.Duration <= 0 {
+		fail("shutdown timeout must be positive")
+	}
+	if config.Drain_delay.Duration < 0 {
+		fail("drain delay cannot be negative")
+	}
+	if config.Timezone != "" {
+		if _, err := time.LoadLocation(config.Timezone); err != nil {
+			fail("timezone %q is unknown", config.Timezone)
+		}
+	}
+
+	if !strings.HasPrefix(config.Mongo.Url, "mongodb://") && !strings.HasPrefix(config.Mongo.Url, "mongodb+srv://") {
+		fail("mongo url %q must start with mongodb:// or mongodb+srv://", config.Mongo.Url)
+	}
+	if config.Mongo.Database == "" {
+		fail("mongo database is required")
+	}
+	if config.Mongo.Connect_timeout.Duration <= 0 {
+		fail("mongo connect timeout must be positive")
+	}
+
+	if config.Log.Address != "" {
+		if _, _, err := net.SplitHostPort(config.Log.Address); err != nil {
+			fail("log address %q must be host:port", config.Log.Address)
+		}
+	}
+	if config.Log.Fallback == "" {
+		fail("log fallback must be stdout, stderr or a file path")
+	}
+	if config.Log.Queue_size < 1 {
+		fail("log queue size must be positive")
+	}
+
+	if len(config.Cors.Origins) == 0 {
+		fail("at least one cors origin is required")
+	}
+	for _, origin := range config.Cors.Origins {
+		if origin == "*" {
+			continue
+		}
+		parsed, err := url.Parse(origin)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			fail("cors origin %q must be * or an http(s) origin", origin)
+		}
+	}
+
+	if config.Auth.Token_lifetime.Duration <= 0 {
+		fail("token lifetime must be positive")
+	}
+	if config.Auth.Refresh_token_lifetime.Duration < config.Auth.Token_lifetime.Duration {
+		fail("refresh token lifetime must be at least the token lifetime")
+	}
+
+	if len(config.Pricing.Currency) != 3 {
+		fail("currency %q must be a three letter code", config.Pricing.Currency)
+	}
+	// ParseFloat accepts NaN and Inf, which slip past every comparison below
+	// and would break the money arithmetic on each priced request
+	if !finite(config.Pricing.Tax_rate) || config.Pricing.Tax_rate < 0 {
+		fail("tax rate must be a finite number, zero or more")
+	}
+	for category, rate := range config.Pricing.Category_tax_rates {
+		if !finite(rate) || rate < 0 {
+			fail("tax rate for %s must be a finite number, zero or more", category)
+		}
+	}
+	if !finite(config.Pricing.Service_charge_rate) || config.Pricing.Service_charge_rate < 0 {
+		fail("service charge rate must be a finite number, zero or more")
+	}
+	for size, multiplier := range config.Pricing.Size_multipliers {
+		if !finite(multiplier) || multiplier <= 0 {
+			fail("size multiplier for %s must be a finite positive number", size)
+		}
+	}
+	if !finite(config.Pricing.Target_margin) || config.Pricing.Target_margin < 0 || config.Pricing.Target_margin >= 1 {
+		fail("target margin must be a fraction such as 0.7")
+	}
+	return errors.Join(errs...)
+}
+
+func finite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+func envString(key string, target *string) {
+	if value := os.Getenv(key); value != "" {
+		*target = value
+	}
+}
+
+func envInt(key string, target *int) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s %q is not a whole number", key, value)
+	}
+	*target = parsed
+	return nil
+}
+
+func envFloat(key string, target *float64) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%s %q is not a number", key, value)
+	}
+	*target = parsed
+	return nil
+}
+
+func envDuration(key string, target *Duration) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	if err := target.UnmarshalText([]byte(value)); err != nil {
+		return fmt.Errorf("%s %q is not a duration such as 24h", key, value)
+	}
+	return nil
+}
+
+// envRates reads "KEY=rate,KEY=rate" lists such as CATEGORY_TAX_RATES=DRINKS=0.2,FOOD=0.1.
+func envRates(key string, target *map[string]float64) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+
+	rates := map[string]float64{}
+	for _, pair := range strings.Split(value, ",") {
+		name, rawRate, found := strings.Cut(strings.TrimSpace(pair), "=")
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rawRate), 64)
+		if !found || err != nil {
+			return fmt.Errorf("%s %q must look like NAME=0.2,OTHER=0.1", key, value)
+		}
+		rates[strings.TrimSpace(name)] = rate
+	}
+	*target = rates
+	return nil
+}
+
+func upperKeys(values map[string]float64) map[string]float64 {
+	upper := make(map[string]float64, len(values))
+	for key, value := range values {
+		upper[strings.ToUpper(key)] = value
+	}
+	return upper
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadRejectsBadEnvironment(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		want  string
+	}{
+		{"nan tax rate", "TAX_RATE", "NaN", "tax rate"},
+		{"infinite tax rate", "TAX_RATE", "Inf", "tax rate"},
+		{"negative tax rate", "TAX_RATE", "-0.1", "tax rate"},
+		{"nan category rate", "CATEGORY_TAX_RATES", "DRINKS=NaN", "tax rate for DRINKS"},
+		{"malformed category rates", "CATEGORY_TAX_RATES", "DRINKS", "must look like NAME=0.2"},
+		{"infinite service charge", "SERVICE_CHARGE_RATE", "+Inf", "service charge rate"},
+		{"infinite size multiplier", "SIZE_MULTIPLIERS", "L=Inf", "size multiplier for L"},
+		{"zero size multiplier", "SIZE_MULTIPLIERS", "L=0", "size multiplier for L"},
+		{"nan target margin", "TARGET_MARGIN", "NaN", "target margin"},
+		{"whole target margin", "TARGET_MARGIN", "1", "target margin"},
+		{"port not a number", "PORT", "http", "PORT"},
+		{"port out of range", "PORT", "70000", "port 70000"},
+		{"duration without unit", "TOKEN_LIFETIME", "24", "TOKEN_LIFETIME"},
+		{"negative drain delay", "DRAIN_DELAY", "-1s", "drain delay"},
+		{"mongo url scheme", "MONGO_URL", "http://mongo:27017", "mongo url"},
+		{"currency code", "CURRENCY", "euro", "currency"},
+		{"cors origin", "CORS_ORIGINS", "localhost:5173", "cors origin"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(test.key, test.value)
+			_, err := Load("")
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("expected an error mentioning %q, got %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadReadsEnvironment(t *testing.T) {
+	t.Setenv("CATEGORY_TAX_RATES", "drinks=0.2, food=0.1")
+	t.Setenv("OWNER_EMAIL", " Boss@Example.com ")
+	t.Setenv("CURRENCY", "eur")
+	t.Setenv("TOKEN_LIFETIME", "2h")
+	t.Setenv("DRAIN_DELAY", "0s")
+
+	config, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Pricing.Category_tax_rates["DRINKS"] != 0.2 || config.Pricing.Category_tax_rates["FOOD"] != 0.1 {
+		t.Errorf("expected upper-cased category rates, got %v", config.Pricing.Category_tax_rates)
+	}
+	if config.Auth.Owner_email != "boss@example.com" {
+		t.Errorf("expected a trimmed lower-case owner email, got %q", config.Auth.Owner_email)
+	}
+	if config.Pricing.Currency != "EUR" {
+		t.Errorf("expected EUR, got %q", config.Pricing.Currency)
+	}
+	if config.Auth.Token_lifetime.Duration != 2*time.Hour {
+		t.Errorf("expected a 2h token lifetime, got %s", config.Auth.Token_lifetime)
+	}
+	if config.Drain_delay.Duration != 0 {
+		t.Errorf("expected no drain delay, got %s", config.Drain_delay)
+	}
+}
+
+func TestLoadRejectsNaNFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("pricing:\n  tax_rate: .nan\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "tax rate") {
+		t.Fatalf("expected the NaN tax rate to be refused, got %v", err)
+	}
+}
+
+func TestRequireSecrets(t *testing.T) {
+	tests := []struct {
+		key     string
+		wantErr bool
+	}{
+		{"", true},
+		{strings.Repeat("k", MIN_SECRET_KEY_LENGTH-1), true},
+		{strings.Repeat("k", MIN_SECRET_KEY_LENGTH), false},
+	}
+	for _, test := range tests {
+		config := Defaults()
+		config.Auth.Secret_key = test.key
+		if err := config.RequireSecrets(); (err != nil) != test.wantErr {
+			t.Errorf("key of %d characters: expected error %v, got %v", len(test.key), test.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"context"
+	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const UNASSIGNED_SECTION = "UNASSIGNED"
+
+type TableState struct {
+	Status         string     `json:"status"`
+	Order_id       *string    `json:"order_id"`
+	Invoice_id     *string    `json:"invoice_id"`
+	Occupied_since *time.Time `json:"occupied_since"`
+}
+
+type FloorServer struct {
+	User_id    string  `json:"user_id"`
+	First_name *string `json:"first_name"`
+	Last_name  *string `json:"last_name"`
+}
+
+type FloorTable struct {
+	models.Table
+	Order_id       *string      `json:"order_id"`
+	Invoice_id     *string      `json:"invoice_id"`
+	Occupied_since *time.Time   `json:"occupied_since"`
+	Server         *FloorServer `json:"server"`
+}
+
+type FloorSection struct {
+	Section string       `json:"section"`
+	Tables  []FloorTable `json:"tables"`
+}
+
+type FloorView struct {
+	Sections      []FloorSection `json:"sections"`
+	Status_counts map[string]int `json:"status_counts"`
+}
+
+// tableStates looks at every table with an open order. A table whose order
+// already has an unpaid invoice is waiting on the bill; otherwise it is
+// occupied. Tables missing from the map have no guests.
+func tableStates(ctx context.Context, stores *store.Store) (map[string]TableState, error) {
+	open, err := openOrdersByTable(ctx, stores.Orders)
+	if err != nil {
+		return nil, err
+	}
+
+	states := map[string]TableState{}
+	var orderIds []string
+	tableByOrder := map[string]string{}
+	for tableId, order := range open {
+		orderId := order.Order_id
+		since := order.Created_at
+		states[tableId] = TableState{Status: models.TABLE_OCCUPIED, Order_id: &orderId, Occupied_since: &since}
+		orderIds = append(orderIds, orderId)
+		tableByOrder[orderId] = tableId
+	}
+	if len(orderIds) == 0 {
+		return states, nil
+	}
+
+	invoices, err := stores.Invoices.Unpaid(ctx, orderIds)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, invoice := range invoices {
+		tableId := tableByOrder[invoice.Order_id]
+		state := states[tableId]
+		invoiceId := invoice.Invoice_id
+		state.Status = models.TABLE_AWAITING_PAYMENT
+		state.Invoice_id = &invoiceId
+		states[tableId] = state
+	}
+	return states, nil
+}
+
+func (state TableState) statusOf(table models.Table) string {
+	if state.Status != "" {
+		return state.Status
+	}
+	if table.NeedsCleaning() {
+		return models.TABLE_CLEANING
+	}
+	return models.TABLE_FREE
+}
+
+func GetFloor(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var servers []models.User
+
+		tables, err := stores.Tables.List(ctx)
+
+		var states map[string]TableState
+		if err == nil {
+			states, err = tableStates(ctx, stores)
+		}
+
+		if err == nil {
+			var serverIds []string
+			for _, table := range tables {
+				if table.Server_id != nil {
+					serverIds = append(serverIds, *table.Server_id)
+				}
+			}
+			servers, err = stores.Users.Find(ctx, serverIds)
+		}
+
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "get_floor_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while building the floor plan")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while building the floor plan"})
+			return
+		}
+
+		serverById := map[string]*FloorServer{}
+		for _, server := range servers {
+			serverById[server.User_id] = &FloorServer{User_id: server.User_id, First_name: server.First_name, Last_name: server.Last_name}
+		}
+
+		view := FloorView{Sections: []FloorSection{}, Status_counts: map[string]int{
+			models.TABLE_FREE:             0,
+			models.TABLE_OCCUPIED:         0,
+			models.TABLE_AWAITING_PAYMENT: 0,
+			models.TABLE_CLEANING:         0,
+		}}
+		sectionIndex := map[string]int{}
+
+		sort.Slice(tables, func(i, j int) bool {
+			return valueOrZero(tables[i].Table_number) < valueOrZero(tables[j].Table_number)
+		})
+
+		for _, table := range tables {
+			state := states[table.Table_id]
+			table.Status = state.statusOf(table)
+			view.Status_counts[table.Status]++
+
+			floorTable := FloorTable{
+				Table:          table,
+				Order_id:       state.Order_id,
+				Invoice_id:     state.Invoice_id,
+				Occupied_since: state.Occupied_since,
+			}
+			if table.Server_id != nil {
+				floorTable.Server = serverById[*table.Server_id]
+			}
+
+			section := UNASSIGNED_SECTION
+			if table.Section != nil && *table.Section != "" {
+				section = *table.Section
+			}
+			index, ok := sectionIndex[section]
+			if !ok {
+				index = len(view.Sections)
+				sectionIndex[section] = index
+				view.Sections = append(view.Sections, FloorSection{Section: section})
+			}
+			view.Sections[index].Tables = append(view.Sections[index].Tables, floorTable)
+		}
+
+		sort.SliceStable(view.Sections, func(i, j int) bool {
+			return view.Sections[i].Section < view.Sections[j].Section
+		})
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event": "get_floor_success",
+			"time":  time.Now().Format(time.RFC3339),
+		}).Info("Successfully built the floor plan")
+		c.JSON(http.StatusOK, view)
+	}
+}
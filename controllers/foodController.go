@@ -2,12 +2,13 @@ package controller
 
 import (
 	"context"
-	"golang-restaurant-management/database"
+	"fmt"
 	appLogger "golang-restaurant-management/logger"
 	"golang-restaurant-management/models"
-	"math"
+	"golang-restaurant-management/store"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,16 +16,11 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var (
-	foodCollection *mongo.Collection = database.OpenCollection(database.Client, "food")
-	validate        = validator.New()
-)
+var validate = validator.New()
 
-func GetFoods() gin.HandlerFunc {
+func GetFoods(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -42,18 +38,17 @@ func GetFoods() gin.HandlerFunc {
 		startIndex := (page - 1) * recordPerPage
 		startIndex, err = strconv.Atoi(c.Query("startIndex"))
 
-		matchStage := bson.D{{"$match", bson.D{{}}}}
-		groupStage := bson.D{{"$group", bson.D{{"_id", bson.D{{"_id", "null"}}}, {"total_count", bson.D{{"$sum", 1}}}, {"data", bson.D{{"$push", "$$ROOT"}}}}}}
-		projectStage := bson.D{
-			{
-				"$project", bson.D{
-					{"_id", 0},
-					{"total_count", 1},
-					{"food_items", bson.D{{"$slice", []interface{}{"$data", startIndex, recordPerPage}}}},
-				}}}
-
-		result, err := foodCollection.Aggregate(ctx, mongo.Pipeline{
-			matchStage, groupStage, projectStage})
+		// ?allergen_free=NUTS,GLUTEN hides foods containing any of them,
+		// ?dietary=VEGAN,HALAL keeps only foods carrying all of them
+		filter := store.FoodFilter{
+			Allergen_free: models.NormalizeTags(strings.Split(c.Query("allergen_free"), ",")),
+			Dietary:       models.NormalizeTags(strings.Split(c.Query("dietary"), ",")),
+		}
+		if available, err := strconv.ParseBool(c.Query("available")); err == nil {
+			filter.Available = &available
+		}
+
+		foods, err := stores.Foods.Page(ctx, filter, startIndex, recordPerPage)
 		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event": "get_foods_error",
@@ -63,33 +58,22 @@ func GetFoods() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing food items"})
 			return
 		}
-		var allFoods []bson.M
-		if err = result.All(ctx, &allFoods); err != nil {
-			appLogger.Log.WithFields(logrus.Fields{
-				"event": "get_foods_error",
-				"time":  time.Now().Format(time.RFC3339),
-				"error": err,
-			}).Error("Error occurred while retrieving food items")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while retrieving food items"})
-			return
-		}
 		appLogger.Log.WithFields(logrus.Fields{
 			"event": "get_foods_success",
 			"time":  time.Now().Format(time.RFC3339),
 		}).Info("Successfully retrieved food items")
-		c.JSON(http.StatusOK, allFoods[0])
+		c.JSON(http.StatusOK, foods)
 	}
 }
 
-func GetFood() gin.HandlerFunc {
+func GetFood(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		foodId := c.Param("food_id")
-		var food models.Food
 
-		err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food)
+		food, err := stores.Foods.Get(ctx, foodId)
 		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event":   "get_food_error",
@@ -109,12 +93,11 @@ func GetFood() gin.HandlerFunc {
 	}
 }
 
-func CreateFood() gin.HandlerFunc {
+func CreateFood(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		var menu models.Menu
 		var food models.Food
 
 		if err := c.BindJSON(&food); err != nil {
@@ -138,7 +121,7 @@ func CreateFood() gin.HandlerFunc {
 			return
 		}
 
-		err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.Menu_id}).Decode(&menu)
+		_, err := stores.Menus.Get(ctx, *food.Menu_id)
 		if err != nil {
 			msg := "menu was not found"
 			appLogger.Log.WithFields(logrus.Fields{
@@ -151,14 +134,35 @@ func CreateFood() gin.HandlerFunc {
 			return
 		}
 
+		if food.Price.Amount < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "price cannot be negative"})
+			return
+		}
+
+		if err := models.PrepareModifierGroups(food.Modifier_groups); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := food.NormalizeSizePrices(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		food.Allergens = models.NormalizeTags(food.Allergens)
+		food.Dietary_tags = models.NormalizeTags(food.Dietary_tags)
+
+		if err := checkRecipe(ctx, stores.Ingredients, food.Recipe); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		food.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		food.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		food.ID = primitive.NewObjectID()
 		food.Food_id = food.ID.Hex()
-		num := toFixed(*food.Price, 2)
-		food.Price = &num
 
-		result, insertErr := foodCollection.InsertOne(ctx, food)
+		result, insertErr := stores.Foods.Insert(ctx, food)
 		if insertErr != nil {
 			msg := "Food item was not created"
 			appLogger.Log.WithFields(logrus.Fields{
@@ -179,12 +183,11 @@ func CreateFood() gin.HandlerFunc {
 	}
 }
 
-func UpdateFood() gin.HandlerFunc {
+func UpdateFood(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		var menu models.Menu
 		var food models.Food
 
 		foodId := c.Param("food_id")
@@ -199,22 +202,26 @@ func UpdateFood() gin.HandlerFunc {
 			return
 		}
 
-		var updateObj primitive.D
+		updateObj := bson.M{}
 
 		if food.Name != nil {
-			updateObj = append(updateObj, bson.E{"name", food.Name})
+			updateObj["name"] = food.Name
 		}
 
 		if food.Price != nil {
-			updateObj = append(updateObj, bson.E{"price", food.Price})
+			if food.Price.Amount < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "price cannot be negative"})
+				return
+			}
+			updateObj["price"] = food.Price
 		}
 
 		if food.Food_image != nil {
-			updateObj = append(updateObj, bson.E{"food_image", food.Food_image})
+			updateObj["food_image"] = food.Food_image
 		}
 
 		if food.Menu_id != nil {
-			err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.Menu_id}).Decode(&menu)
+			_, err := stores.Menus.Get(ctx, *food.Menu_id)
 			if err != nil {
 				msg := "menu was not found"
 				appLogger.Log.WithFields(logrus.Fields{
@@ -226,27 +233,57 @@ func UpdateFood() gin.HandlerFunc {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 				return
 			}
-			updateObj = append(updateObj, bson.E{"menu", food.Price})
+			updateObj["menu"] = food.Price
 		}
 
-		food.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{"updated_at", food.Updated_at})
+		if food.Size_prices != nil {
+			if err := food.NormalizeSizePrices(); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj["size_prices"] = food.Size_prices
+		}
 
-		upsert := true
-		filter := bson.M{"food_id": foodId}
+		if food.Allergens != nil {
+			updateObj["allergens"] = models.NormalizeTags(food.Allergens)
+		}
 
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		if food.Dietary_tags != nil {
+			updateObj["dietary_tags"] = models.NormalizeTags(food.Dietary_tags)
 		}
 
-		result, err := foodCollection.UpdateOne(
-			ctx,
-			filter,
-			bson.D{
-				{"$set", updateObj},
-			},
-			&opt,
-		)
+		if food.Recipe != nil {
+			if err := validate.Var(food.Recipe, "dive"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := checkRecipe(ctx, stores.Ingredients, food.Recipe); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj["recipe"] = food.Recipe
+		}
+
+		if food.Modifier_groups != nil {
+			if err := validate.Var(food.Modifier_groups, "dive"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := models.PrepareModifierGroups(food.Modifier_groups); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj["modifier_groups"] = food.Modifier_groups
+		}
+
+		food.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj["updated_at"] = food.Updated_at
+
+		result, err := stores.Foods.Update(ctx, foodId, store.Patch{Set: updateObj})
+		if err == nil && result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "food was not found"})
+			return
+		}
 
 		if err != nil {
 			msg := "food item update failed"
@@ -268,11 +305,180 @@ func UpdateFood() gin.HandlerFunc {
 	}
 }
 
-func round(num float64) int {
-	return int(num + math.Copysign(0.5, num))
+const SOLD_OUT_REASON = "sold out"
+
+type AvailabilityRequest struct {
+	Available          *bool   `json:"available"`
+	Remaining_portions *int    `json:"remaining_portions" validate:"omitempty,min=0"`
+	Reason             *string `json:"reason"`
+	// Untrack_portions stops counting portions for the food altogether
+	Untrack_portions bool `json:"untrack_portions"`
+}
+
+// claimPortions takes portions off the counter in one conditional update, so
+// two waiters cannot both sell the last portion. The food is 86'd as soon as
+// the counter reaches zero.
+func claimPortions(ctx context.Context, foods store.FoodStore, food models.Food, quantity int) error {
+	updated, err := foods.ClaimPortions(ctx, food.Food_id, quantity)
+	if err == store.ErrNotFound {
+		return fmt.Errorf("%s does not have %d portion(s) left", *food.Name, quantity)
+	}
+	if err != nil {
+		return err
+	}
+
+	if updated.Remaining_portions != nil && *updated.Remaining_portions == 0 {
+		markUnavailable(ctx, foods, food.Food_id, SOLD_OUT_REASON, "")
+	}
+	return nil
+}
+
+// releasePortions hands back portions claimed by an order that was then
+// refused, and takes back an 86 that only those portions caused.
+func releasePortions(ctx context.Context, foods store.FoodStore, claimed map[string]int) {
+	for foodId, quantity := range claimed {
+		food, err := foods.Modify(ctx, foodId, store.Patch{Inc: bson.M{"remaining_portions": quantity}})
+		if err == nil && food.Remaining_portions != nil && *food.Remaining_portions > 0 {
+			_, err = foods.Update(ctx, foodId, store.Patch{
+				If:    bson.M{"available": false, "unavailable_reason": SOLD_OUT_REASON},
+				Set:   bson.M{"available": true},
+				Unset: []string{"unavailable_reason", "unavailable_since"},
+			})
+		}
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":   "release_portions_error",
+				"time":    time.Now().Format(time.RFC3339),
+				"food_id": foodId,
+				"error":   err,
+			}).Error("Error occurred while returning claimed portions")
+		}
+	}
+}
+
+func markUnavailable(ctx context.Context, foods store.FoodStore, foodId string, reason string, uid string) error {
+	since, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := foods.Update(ctx, foodId, store.Patch{
+		Set: bson.M{"available": false, "unavailable_reason": reason, "unavailable_since": since, "updated_at": since},
+	})
+	if err != nil {
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":   "food_86_error",
+			"time":    time.Now().Format(time.RFC3339),
+			"food_id": foodId,
+			"error":   err,
+		}).Error("Error occurred while marking food unavailable")
+		return err
+	}
+
+	appLogger.Log.WithFields(logrus.Fields{
+		"event":   "food_86",
+		"time":    time.Now().Format(time.RFC3339),
+		"food_id": foodId,
+		"reason":  reason,
+		"by":      uid,
+	}).Warn("Food marked unavailable")
+	return nil
 }
 
-func toFixed(num float64, precision int) float64 {
-	output := math.Pow(10, float64(precision))
-	return float64(round(num*output)) / output
+// EightySixFood takes an item off sale straight away, e.g. when the kitchen
+// runs out of something that has no portion counter.
+func EightySixFood(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request AvailabilityRequest
+		foodId := c.Param("food_id")
+
+		if err := c.ShouldBindJSON(&request); err != nil && c.Request.ContentLength > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if _, err := stores.Foods.Get(ctx, foodId); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "food was not found"})
+			return
+		}
+
+		reason := "86'd by staff"
+		if request.Reason != nil && *request.Reason != "" {
+			reason = *request.Reason
+		}
+		if err := markUnavailable(ctx, stores.Foods, foodId, reason, c.GetString("uid")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "food could not be marked unavailable"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"food_id": foodId, "available": false, "unavailable_reason": reason})
+	}
+}
+
+func UpdateFoodAvailability(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request AvailabilityRequest
+		foodId := c.Param("food_id")
+
+		if err := c.BindJSON(&request); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "update_food_availability_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		update := store.Patch{Set: bson.M{}}
+		if request.Remaining_portions != nil {
+			update.Set["remaining_portions"] = *request.Remaining_portions
+		}
+		if request.Untrack_portions {
+			update.Unset = append(update.Unset, "remaining_portions")
+		}
+		if request.Available != nil {
+			update.Set["available"] = *request.Available
+			if *request.Available {
+				update.Unset = append(update.Unset, "unavailable_reason", "unavailable_since")
+			}
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		update.Set["updated_at"] = updatedAt
+
+		food, err := stores.Foods.Modify(ctx, foodId, update)
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "food was not found"})
+			return
+		}
+		if err != nil {
+			msg := "food availability update failed"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":   "update_food_availability_error",
+				"time":    time.Now().Format(time.RFC3339),
+				"food_id": foodId,
+				"error":   err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		available := food.IsAvailable()
+		food.Available = &available
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":     "update_food_availability_success",
+			"time":      time.Now().Format(time.RFC3339),
+			"food_id":   foodId,
+			"available": available,
+			"by":        c.GetString("uid"),
+		}).Info("Successfully updated food availability")
+		c.JSON(http.StatusOK, food)
+	}
 }
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"golang-restaurant-management/database"
+	appLogger "golang-restaurant-management/logger"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	HEALTH_OK       = "ok"
+	HEALTH_DEGRADED = "degraded"
+	HEALTH_DOWN     = "down"
+	HEALTH_DRAINING = "draining"
+)
+
+type MongoHealth struct {
+	Status     string `json:"status"`
+	Latency_ms int64  `json:"latency_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+type Health struct {
+	Status  string              `json:"status"`
+	Mongo   MongoHealth         `json:"mongo"`
+	Log     appLogger.SinkStats `json:"log"`
+	Log_ok  bool                `json:"log_ok"`
+	Checked time.Time           `json:"checked_at"`
+}
+
+var draining atomic.Bool
+
+// StartDraining fails readiness from now on, so the load balancer stops
+// sending new requests while the ones in flight finish.
+func StartDraining() {
+	draining.Store(true)
+}
+
+// checkHealth pings MongoDB and looks at the log sink. Logstash being down
+// only degrades the service, since entries still reach the fallback.
+func checkHealth(ctx context.Context, client *mongo.Client) Health {
+	health := Health{Status: HEALTH_OK, Checked: time.Now()}
+
+	started := time.Now()
+	err := database.Ping(ctx, client)
+	health.Mongo.Latency_ms = time.Since(started).Milliseconds()
+	health.Mongo.Status = HEALTH_OK
+	if err != nil {
+		health.Mongo.Status = HEALTH_DOWN
+		health.Mongo.Error = err.Error()
+		health.Status = HEALTH_DOWN
+	}
+
+	health.Log = appLogger.Stats()
+	health.Log_ok = health.Log.Address == "" || health.Log.Connected
+	if !health.Log_ok && health.Status == HEALTH_OK {
+		health.Status = HEALTH_DEGRADED
+	}
+
+	if draining.Load() {
+		health.Status = HEALTH_DRAINING
+	}
+	return health
+}
+
+// Healthz reports on the dependencies but always answers 200 while the
+// process serves, so an outage elsewhere does not get the instance restarted.
+func Healthz(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, checkHealth(c.Request.Context(), client))
+	}
+}
+
+// Readyz answers 503 when MongoDB is unreachable or the service is shutting
+// down, and 200 otherwise.
+func Readyz(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		health := checkHealth(c.Request.Context(), client)
+		if health.Status == HEALTH_DOWN || health.Status == HEALTH_DRAINING {
+			if health.Status == HEALTH_DOWN {
+				appLogger.Log.WithFields(logrus.Fields{
+					"event": "readiness_check_failed",
+					"time":  time.Now().Format(time.RFC3339),
+					"error": health.Mongo.Error,
+				}).Warn("Readiness check failed, MongoDB is unreachable")
+			}
+			c.JSON(http.StatusServiceUnavailable, health)
+			return
+		}
+		c.JSON(http.StatusOK, health)
+	}
+}
@@ -0,0 +1,531 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type StockAdjustment struct {
+	Change *float64 `json:"change" validate:"required"`
+	Note   string   `json:"note" validate:"required"`
+}
+
+func checkRecipe(ctx context.Context, ingredients store.IngredientStore, recipe []models.RecipeLine) error {
+	seen := map[string]bool{}
+	for _, line := range recipe {
+		if seen[line.Ingredient_id] {
+			return fmt.Errorf("ingredient %s is listed more than once", line.Ingredient_id)
+		}
+		seen[line.Ingredient_id] = true
+
+		if err := checkIngredient(ctx, ingredients, line.Ingredient_id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkIngredient(ctx context.Context, ingredients store.IngredientStore, ingredientId string) error {
+	_, err := ingredients.Get(ctx, ingredientId)
+	if err == store.ErrNotFound {
+		return fmt.Errorf("ingredient %s was not found", ingredientId)
+	}
+	return err
+}
+
+func depletionFor(food models.Food, orderItem models.OrderItem, uid string) []models.StockMovement {
+	quantity := quantityOf(orderItem)
+
+	var movements []models.StockMovement
+	for _, line := range food.Recipe {
+		movements = append(movements, models.StockMovement{
+			Ingredient_id: line.Ingredient_id,
+			Change:        -line.Quantity * float64(quantity),
+			Reason:        models.STOCK_DEPLETION,
+			Order_id:      orderItem.Order_id,
+			Order_item_id: orderItem.Order_item_id,
+			Created_by:    uid,
+		})
+	}
+	return movements
+}
+
+// redepletionFor is what correcting an item's food or quantity takes from
+// stock on top of what it took when ordered, one movement per ingredient
+// whose use changed. The movements are depletions, positive when less is
+// used now, so voiding the order still puts back exactly what it took.
+func redepletionFor(before models.Food, current models.OrderItem, after models.Food, updated models.OrderItem, uid string) []models.StockMovement {
+	changes := map[string]float64{}
+	var ingredientIds []string
+	add := func(movements []models.StockMovement, sign float64) {
+		for _, movement := range movements {
+			if _, seen := changes[movement.Ingredient_id]; !seen {
+				ingredientIds = append(ingredientIds, movement.Ingredient_id)
+			}
+			changes[movement.Ingredient_id] += sign * movement.Change
+		}
+	}
+	add(depletionFor(before, current, uid), -1)
+	add(depletionFor(after, updated, uid), 1)
+
+	var movements []models.StockMovement
+	for _, ingredientId := range ingredientIds {
+		if changes[ingredientId] == 0 {
+			continue
+		}
+		movements = append(movements, models.StockMovement{
+			Ingredient_id: ingredientId,
+			Change:        changes[ingredientId],
+			Reason:        models.STOCK_DEPLETION,
+			Order_id:      updated.Order_id,
+			Order_item_id: updated.Order_item_id,
+			Created_by:    uid,
+		})
+	}
+	return movements
+}
+
+// recordStockMovements writes the movements to the ledger and applies them to
+// the ingredients. Stock is allowed to go negative: the kitchen has already
+// cooked the dish, and a negative level is the cue to recount.
+func recordStockMovements(ctx context.Context, stores *store.Store, movements []models.StockMovement) error {
+	if len(movements) == 0 {
+		return nil
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	ingredientIds := make([]string, 0, len(movements))
+	for i := range movements {
+		movements[i].ID = primitive.NewObjectID()
+		movements[i].Movement_id = movements[i].ID.Hex()
+		movements[i].Created_at = now
+		ingredientIds = append(ingredientIds, movements[i].Ingredient_id)
+	}
+
+	if _, err := stores.StockMovements.InsertMany(ctx, movements); err != nil {
+		return err
+	}
+	if err := stores.Ingredients.ApplyStock(ctx, movements, now); err != nil {
+		return err
+	}
+	return checkReorderPoints(ctx, stores.Ingredients, ingredientIds)
+}
+
+// checkReorderPoints raises an alert for ingredients that have fallen to their
+// reorder point and clears it for ones restocked above it. The flag is flipped
+// with a conditional update, so each crossing is reported exactly once.
+func checkReorderPoints(ctx context.Context, ingredientStore store.IngredientStore, ingredientIds []string) error {
+	ingredients, err := ingredientStore.Find(ctx, ingredientIds)
+	if err != nil {
+		return err
+	}
+
+	for _, ingredient := range ingredients {
+		if ingredient.Reorder_point == nil {
+			continue
+		}
+		low := ingredient.Stock <= *ingredient.Reorder_point
+		if low == ingredient.Low_stock {
+			continue
+		}
+
+		result, err := ingredientStore.Update(ctx, ingredient.Ingredient_id, store.Patch{
+			If:  bson.M{"low_stock": !low},
+			Set: bson.M{"low_stock": low},
+		})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+
+		fields := logrus.Fields{
+			"time":          time.Now().Format(time.RFC3339),
+			"ingredient_id": ingredient.Ingredient_id,
+			"name":          valueOfString(ingredient.Name),
+			"stock":         ingredient.Stock,
+			"reorder_point": *ingredient.Reorder_point,
+		}
+		if low {
+			fields["event"] = "low_stock_alert"
+			if ingredient.Supplier_id != nil {
+				fields["supplier_id"] = *ingredient.Supplier_id
+			}
+			appLogger.Log.WithFields(fields).Warn("Ingredient stock has fallen to its reorder point")
+		} else {
+			fields["event"] = "low_stock_cleared"
+			appLogger.Log.WithFields(fields).Info("Ingredient restocked above its reorder point")
+		}
+	}
+	return nil
+}
+
+func GetLowStock(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ingredients, err := stores.Ingredients.List(ctx, true)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "get_low_stock_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while listing low stock")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing low stock"})
+			return
+		}
+
+		if ingredients == nil {
+			ingredients = []models.Ingredient{}
+		}
+		c.JSON(http.StatusOK, ingredients)
+	}
+}
+
+// restockOrder puts back what an order's items took from stock. Each
+// depletion is claimed before it is reversed, so voiding twice is harmless.
+func restockOrder(ctx context.Context, stores *store.Store, orderId string, uid string) {
+	depletions, err := stores.StockMovements.Depletions(ctx, orderId)
+
+	var reversals []models.StockMovement
+	for _, depletion := range depletions {
+		if err != nil {
+			break
+		}
+		var claim store.UpdateResult
+		claim, err = stores.StockMovements.Update(ctx, depletion.Movement_id, store.Patch{
+			If:  bson.M{"reversed": false},
+			Set: bson.M{"reversed": true},
+		})
+		if err != nil || claim.ModifiedCount == 0 {
+			continue
+		}
+		reversals = append(reversals, models.StockMovement{
+			Ingredient_id: depletion.Ingredient_id,
+			Change:        -depletion.Change,
+			Reason:        models.STOCK_REVERSAL,
+			Order_id:      depletion.Order_id,
+			Order_item_id: depletion.Order_item_id,
+			Created_by:    uid,
+		})
+	}
+	if err == nil {
+		err = recordStockMovements(ctx, stores, reversals)
+	}
+
+	if err != nil {
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":    "restock_order_error",
+			"time":     time.Now().Format(time.RFC3339),
+			"order_id": orderId,
+			"error":    err,
+		}).Error("Error occurred while returning a voided order's stock")
+		return
+	}
+	appLogger.Log.WithFields(logrus.Fields{
+		"event":     "restock_order_success",
+		"time":      time.Now().Format(time.RFC3339),
+		"order_id":  orderId,
+		"reversals": len(reversals),
+	}).Info("Returned a voided order's stock")
+}
+
+func GetInventory(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ingredients, err := stores.Ingredients.List(ctx, false)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "get_inventory_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while listing inventory")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing inventory"})
+			return
+		}
+
+		if ingredients == nil {
+			ingredients = []models.Ingredient{}
+		}
+		appLogger.Log.WithFields(logrus.Fields{
+			"event": "get_inventory_success",
+			"time":  time.Now().Format(time.RFC3339),
+		}).Info("Successfully retrieved inventory")
+		c.JSON(http.StatusOK, ingredients)
+	}
+}
+
+func GetStockMovements(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ingredientId := c.Param("ingredient_id")
+
+		movements, err := stores.StockMovements.ForIngredient(ctx, ingredientId, 200)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":         "get_stock_movements_error",
+				"time":          time.Now().Format(time.RFC3339),
+				"ingredient_id": ingredientId,
+				"error":         err,
+			}).Error("Error occurred while listing stock movements")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing stock movements"})
+			return
+		}
+
+		if movements == nil {
+			movements = []models.StockMovement{}
+		}
+		c.JSON(http.StatusOK, movements)
+	}
+}
+
+func CreateIngredient(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var ingredient models.Ingredient
+
+		if err := c.BindJSON(&ingredient); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_ingredient_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(ingredient)
+		if validationErr != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_ingredient_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": validationErr,
+			}).Error("Validation error")
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if ingredient.Supplier_id != nil {
+			if _, err := stores.Suppliers.Get(ctx, *ingredient.Supplier_id); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "supplier was not found"})
+				return
+			}
+		}
+
+		// opening stock is booked as an adjustment so the ledger adds up to the level
+		openingStock := ingredient.Stock
+		ingredient.Stock = 0
+		ingredient.Low_stock = false
+		ingredient.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		ingredient.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		ingredient.ID = primitive.NewObjectID()
+		ingredient.Ingredient_id = ingredient.ID.Hex()
+
+		result, insertErr := stores.Ingredients.Insert(ctx, ingredient)
+		if insertErr != nil {
+			msg := "ingredient was not created"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_ingredient_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": insertErr,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		if openingStock == 0 && ingredient.Reorder_point != nil {
+			checkReorderPoints(ctx, stores.Ingredients, []string{ingredient.Ingredient_id})
+		}
+		if openingStock != 0 {
+			err := recordStockMovements(ctx, stores, []models.StockMovement{{
+				Ingredient_id: ingredient.Ingredient_id,
+				Change:        openingStock,
+				Reason:        models.STOCK_ADJUSTMENT,
+				Note:          "opening stock",
+				Created_by:    c.GetString("uid"),
+			}})
+			if err != nil {
+				appLogger.Log.WithFields(logrus.Fields{
+					"event":         "create_ingredient_error",
+					"time":          time.Now().Format(time.RFC3339),
+					"ingredient_id": ingredient.Ingredient_id,
+					"error":         err,
+				}).Error("Error occurred while recording opening stock")
+			}
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":         "create_ingredient_success",
+			"time":          time.Now().Format(time.RFC3339),
+			"ingredient_id": ingredient.Ingredient_id,
+		}).Info("Successfully created ingredient")
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdateIngredient(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var ingredient models.Ingredient
+		ingredientId := c.Param("ingredient_id")
+
+		if err := c.BindJSON(&ingredient); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "update_ingredient_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// stock levels only change through movements, see AdjustStock
+		updateObj := bson.M{}
+		if ingredient.Name != nil {
+			updateObj["name"] = ingredient.Name
+		}
+		if ingredient.Unit != nil {
+			updateObj["unit"] = ingredient.Unit
+		}
+		if ingredient.Reorder_point != nil {
+			if *ingredient.Reorder_point < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "reorder_point cannot be negative"})
+				return
+			}
+			updateObj["reorder_point"] = ingredient.Reorder_point
+		}
+		if ingredient.Reorder_quantity != nil {
+			if *ingredient.Reorder_quantity <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "reorder_quantity must be positive"})
+				return
+			}
+			updateObj["reorder_quantity"] = ingredient.Reorder_quantity
+		}
+		if ingredient.Supplier_id != nil {
+			if *ingredient.Supplier_id != "" {
+				if _, err := stores.Suppliers.Get(ctx, *ingredient.Supplier_id); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "supplier was not found"})
+					return
+				}
+			}
+			updateObj["supplier_id"] = ingredient.Supplier_id
+		}
+		ingredient.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj["updated_at"] = ingredient.Updated_at
+
+		result, err := stores.Ingredients.Update(ctx, ingredientId, store.Patch{Set: updateObj})
+		if err != nil {
+			msg := "ingredient update failed"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":         "update_ingredient_error",
+				"time":          time.Now().Format(time.RFC3339),
+				"ingredient_id": ingredientId,
+				"error":         err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "ingredient was not found"})
+			return
+		}
+
+		if ingredient.Reorder_point != nil {
+			if err := checkReorderPoints(ctx, stores.Ingredients, []string{ingredientId}); err != nil {
+				appLogger.Log.WithFields(logrus.Fields{
+					"event":         "update_ingredient_error",
+					"time":          time.Now().Format(time.RFC3339),
+					"ingredient_id": ingredientId,
+					"error":         err,
+				}).Error("Error occurred while checking the reorder point")
+			}
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":         "update_ingredient_success",
+			"time":          time.Now().Format(time.RFC3339),
+			"ingredient_id": ingredientId,
+		}).Info("Successfully updated ingredient")
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func AdjustStock(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var adjustment StockAdjustment
+		ingredientId := c.Param("ingredient_id")
+
+		if err := c.BindJSON(&adjustment); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "adjust_stock_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(adjustment); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ingredient, err := stores.Ingredients.Get(ctx, ingredientId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "ingredient was not found"})
+			return
+		}
+
+		err = recordStockMovements(ctx, stores, []models.StockMovement{{
+			Ingredient_id: ingredientId,
+			Change:        *adjustment.Change,
+			Reason:        models.STOCK_ADJUSTMENT,
+			Note:          adjustment.Note,
+			Created_by:    c.GetString("uid"),
+		}})
+		if err != nil {
+			msg := "stock adjustment failed"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":         "adjust_stock_error",
+				"time":          time.Now().Format(time.RFC3339),
+				"ingredient_id": ingredientId,
+				"error":         err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		ingredient.Stock += *adjustment.Change
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":         "adjust_stock_success",
+			"time":          time.Now().Format(time.RFC3339),
+			"ingredient_id": ingredientId,
+			"change":        *adjustment.Change,
+			"adjusted_by":   c.GetString("uid"),
+		}).Info("Successfully adjusted stock")
+		c.JSON(http.StatusOK, ingredient)
+	}
+}
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"context"
+	"io"
+	"math"
+	"testing"
+
+	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func init() {
+	appLogger.Log = logrus.New()
+	appLogger.Log.Out = io.Discard
+}
+
+func recipeFood(lines ...models.RecipeLine) models.Food {
+	return models.Food{Food_id: primitive.NewObjectID().Hex(), Recipe: lines}
+}
+
+func orderItemOf(quantity *int) models.OrderItem {
+	return models.OrderItem{Order_id: "order-1", Order_item_id: "item-1", Quantity: quantity}
+}
+
+func changesByIngredient(movements []models.StockMovement) map[string]float64 {
+	changes := map[string]float64{}
+	for _, movement := range movements {
+		changes[movement.Ingredient_id] += movement.Change
+	}
+	return changes
+}
+
+func sameChanges(got map[string]float64, want map[string]float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for ingredientId, change := range want {
+		if math.Abs(got[ingredientId]-change) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDepletionFor(t *testing.T) {
+	one, three := 1, 3
+	burger := recipeFood(models.RecipeLine{Ingredient_id: "bun", Quantity: 1}, models.RecipeLine{Ingredient_id: "patty", Quantity: 0.2})
+
+	tests := []struct {
+		name     string
+		food     models.Food
+		quantity *int
+		want     map[string]float64
+	}{
+		{"no quantity is one", burger, nil, map[string]float64{"bun": -1, "patty": -0.2}},
+		{"one", burger, &one, map[string]float64{"bun": -1, "patty": -0.2}},
+		{"scaled by quantity", burger, &three, map[string]float64{"bun": -3, "patty": -0.6}},
+		{"no recipe", recipeFood(), &three, map[string]float64{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			movements := depletionFor(test.food, orderItemOf(test.quantity), "cook")
+			if got := changesByIngredient(movements); !sameChanges(got, test.want) {
+				t.Fatalf("expected %v, got %v", test.want, got)
+			}
+			for _, movement := range movements {
+				if movement.Reason != models.STOCK_DEPLETION || movement.Order_item_id != "item-1" || movement.Created_by != "cook" {
+					t.Fatalf("expected a depletion for item-1 by cook, got %+v", movement)
+				}
+			}
+		})
+	}
+}
+
+func TestRedepletionFor(t *testing.T) {
+	one, three := 1, 3
+	burger := recipeFood(models.RecipeLine{Ingredient_id: "bun", Quantity: 1}, models.RecipeLine{Ingredient_id: "patty", Quantity: 0.2})
+	wrap := recipeFood(models.RecipeLine{Ingredient_id: "tortilla", Quantity: 1}, models.RecipeLine{Ingredient_id: "patty", Quantity: 0.2})
+
+	tests := []struct {
+		name          string
+		before, after models.Food
+		from, to      *int
+		want          map[string]float64
+	}{
+		{"more ordered takes more", burger, burger, &one, &three, map[string]float64{"bun": -2, "patty": -0.4}},
+		{"fewer ordered puts some back", burger, burger, &three, &one, map[string]float64{"bun": 2, "patty": 0.4}},
+		{"another food swaps ingredients", burger, wrap, &one, &one, map[string]float64{"bun": 1, "tortilla": -1}},
+		{"another food and quantity", burger, wrap, &one, &three, map[string]float64{"bun": 1, "tortilla": -3, "patty": -0.4}},
+		{"nothing changed", burger, burger, &three, &three, map[string]float64{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			movements := redepletionFor(test.before, orderItemOf(test.from), test.after, orderItemOf(test.to), "waiter")
+			if len(movements) != len(test.want) {
+				t.Fatalf("expected one movement per changed ingredient %v, got %+v", test.want, movements)
+			}
+			if got := changesByIngredient(movements); !sameChanges(got, test.want) {
+				t.Fatalf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestRestockOrderReversesEachDepletionOnce(t *testing.T) {
+	stores := store.NewMemory()
+	ctx := context.Background()
+
+	name, unit, reorderPoint := "Patty", "kg", 9.0
+	ingredient := models.Ingredient{ID: primitive.NewObjectID(), Name: &name, Unit: &unit, Stock: 10, Reorder_point: &reorderPoint}
+	ingredient.Ingredient_id = ingredient.ID.Hex()
+	if _, err := stores.Ingredients.Insert(ctx, ingredient); err != nil {
+		t.Fatal(err)
+	}
+	stock := func() models.Ingredient {
+		t.Helper()
+		current, err := stores.Ingredients.Get(ctx, ingredient.Ingredient_id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return current
+	}
+
+	three := 3
+	patty := recipeFood(models.RecipeLine{Ingredient_id: ingredient.Ingredient_id, Quantity: 0.5})
+	if err := recordStockMovements(ctx, stores, depletionFor(patty, orderItemOf(&three), "waiter")); err != nil {
+		t.Fatal(err)
+	}
+	if current := stock(); current.Stock != 8.5 || !current.Low_stock {
+		t.Fatalf("expected 8.5 left and a low stock alert, got %v %v", current.Stock, current.Low_stock)
+	}
+
+	// voiding twice, e.g. a retried request, puts the stock back once
+	restockOrder(ctx, stores, "order-1", "manager")
+	restockOrder(ctx, stores, "order-1", "manager")
+	if current := stock(); current.Stock != 10 || current.Low_stock {
+		t.Fatalf("expected the stock back at 10 and the alert cleared, got %v %v", current.Stock, current.Low_stock)
+	}
+
+	movements, err := stores.StockMovements.ForIngredient(ctx, ingredient.Ingredient_id, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reasons := map[string]int{}
+	for _, movement := range movements {
+		reasons[movement.Reason]++
+	}
+	if reasons[models.STOCK_DEPLETION] != 1 || reasons[models.STOCK_REVERSAL] != 1 {
+		t.Fatalf("expected one depletion and one reversal, got %v", reasons)
+	}
+}
@@ -2,9 +2,11 @@ package controller
 
 import (
 	"context"
-	"golang-restaurant-management/database"
+	"fmt"
 	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/metrics"
 	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
 	"net/http"
 	"time"
 
@@ -12,8 +14,6 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type InvoiceViewFormat struct {
@@ -21,32 +21,27 @@ type InvoiceViewFormat struct {
 	Payment_method   string
 	Order_id         string
 	Payment_status   *string
-	Payment_due      interface{}
+	Subtotal         models.Money
+	Discount         models.Money
+	Discounts        []models.InvoiceDiscount
+	Service_charge   models.Money
+	Tax              models.Money
+	Tip              models.Money
+	Payment_due      models.Money
+	Amount_paid      models.Money
+	Balance          models.Money
 	Table_number     interface{}
 	Payment_due_date time.Time
 	Order_details    interface{}
 }
 
-var invoiceCollection *mongo.Collection = database.OpenCollection(database.Client, "invoice")
-
-func GetInvoices() gin.HandlerFunc {
+func GetInvoices(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		result, err := invoiceCollection.Find(context.TODO(), bson.M{})
+		allInvoices, err := stores.Invoices.All(ctx)
 		if err != nil {
-			appLogger.Log.WithFields(logrus.Fields{
-				"event": "get_invoices_error",
-				"time":  time.Now().Format(time.RFC3339),
-				"error": err,
-			}).Error("Error occurred while listing invoice items")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing invoice items"})
-			return
-		}
-
-		var allInvoices []bson.M
-		if err = result.All(ctx, &allInvoices); err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event": "get_invoices_error",
 				"time":  time.Now().Format(time.RFC3339),
@@ -63,15 +58,13 @@ func GetInvoices() gin.HandlerFunc {
 	}
 }
 
-func GetInvoice() gin.HandlerFunc {
+func GetInvoice(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		invoiceId := c.Param("invoice_id")
-		var invoice models.Invoice
-
-		err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
+		invoice, err := stores.Invoices.Get(ctx, invoiceId)
 		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event":      "get_invoice_error",
@@ -83,8 +76,8 @@ func GetInvoice() gin.HandlerFunc {
 			return
 		}
 
-		var invoiceView InvoiceViewFormat
-		allOrderItems, err := ItemsByOrder(invoice.Order_id)
+		invoiceView := InvoiceViewFormat{Payment_due: models.NewMoney(0), Discounts: []models.InvoiceDiscount{}}
+		summary, err := invoiceSummary(stores, invoice)
 		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event":      "get_invoice_error",
@@ -105,12 +98,31 @@ func GetInvoice() gin.HandlerFunc {
 
 		invoiceView.Invoice_id = invoice.Invoice_id
 		invoiceView.Payment_status = invoice.Payment_status
-		if len(allOrderItems) > 0 {
-			invoiceView.Payment_due = allOrderItems[0]["payment_due"]
-			invoiceView.Table_number = allOrderItems[0]["table_number"]
-			invoiceView.Order_details = allOrderItems[0]["order_items"]
+		if summary.Order_id != "" {
+			invoiceView.Payment_due = summary.Payment_due
+			invoiceView.Subtotal = summary.Subtotal
+			invoiceView.Discount = summary.Discount
+			invoiceView.Service_charge = summary.Service_charge
+			invoiceView.Tax = summary.Tax
+			invoiceView.Table_number = summary.Table_number
+			invoiceView.Order_details = summary.Order_items
+		}
+		if invoice.Discounts != nil {
+			invoiceView.Discounts = invoice.Discounts
 		}
 
+		// totals are frozen when the invoice is created, later menu price changes must not move them
+		if invoice.Total != nil {
+			invoiceView.Subtotal = valueOf(invoice.Subtotal)
+			invoiceView.Discount = valueOf(invoice.Discount)
+			invoiceView.Service_charge = valueOf(invoice.Service_charge)
+			invoiceView.Tax = valueOf(invoice.Tax)
+			invoiceView.Tip = valueOf(invoice.Tip)
+			invoiceView.Payment_due = *invoice.Total
+		}
+		invoiceView.Amount_paid = valueOf(invoice.Amount_paid)
+		invoiceView.Balance = invoiceView.Payment_due.Sub(invoiceView.Amount_paid)
+
 		appLogger.Log.WithFields(logrus.Fields{
 			"event":      "get_invoice_success",
 			"time":       time.Now().Format(time.RFC3339),
@@ -120,7 +132,7 @@ func GetInvoice() gin.HandlerFunc {
 	}
 }
 
-func CreateInvoice() gin.HandlerFunc {
+func CreateInvoice(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -136,8 +148,7 @@ func CreateInvoice() gin.HandlerFunc {
 			return
 		}
 
-		var order models.Order
-		err := orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order)
+		_, err := stores.Orders.Get(ctx, invoice.Order_id)
 		if err != nil {
 			msg := "Order was not found"
 			appLogger.Log.WithFields(logrus.Fields{
@@ -150,16 +161,62 @@ func CreateInvoice() gin.HandlerFunc {
 			return
 		}
 
-		status := "PENDING"
-		if invoice.Payment_status == nil {
-			invoice.Payment_status = &status
+		// an invoice only becomes PAID through the payments recorded against it
+		status := models.PAYMENT_PENDING
+		invoice.Payment_status = &status
+		invoice.Amount_paid = nil
+		invoice.Tips_collected = nil
+		invoice.Paid_items = nil
+
+		allOrderItems, err := ItemsByOrder(stores, invoice.Order_id)
+		if err != nil {
+			msg := "error occurred while pricing the order"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":    "create_invoice_error",
+				"time":     time.Now().Format(time.RFC3339),
+				"order_id": invoice.Order_id,
+				"error":    err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		summary := OrderSummary{}
+		if len(allOrderItems) > 0 {
+			summary = allOrderItems[0]
+		}
+
+		// running promotions go on by themselves, codes only when given
+		uid := c.GetString("uid")
+		appliedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		invoice.Discounts, err = automaticDiscounts(ctx, stores.Promotions, summary, uid, appliedAt)
+		if err != nil {
+			msg := "error occurred while loading promotions"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":    "create_invoice_error",
+				"time":     time.Now().Format(time.RFC3339),
+				"order_id": invoice.Order_id,
+				"error":    err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+		for _, code := range invoice.Promo_codes {
+			discount, err := discountForCode(ctx, stores.Promotions, code, summary, invoice.Discounts, uid, appliedAt)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			invoice.Discounts = append(invoice.Discounts, discount)
 		}
+		priceInvoice(&invoice, summary)
 
 		invoice.Payment_due_date, _ = time.Parse(time.RFC3339, time.Now().AddDate(0, 0, 1).Format(time.RFC3339))
 		invoice.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		invoice.ID = primitive.NewObjectID()
 		invoice.Invoice_id = invoice.ID.Hex()
+		invoice.Version = 1
 
 		validationErr := validate.Struct(invoice)
 		if validationErr != nil {
@@ -172,8 +229,14 @@ func CreateInvoice() gin.HandlerFunc {
 			return
 		}
 
-		result, insertErr := invoiceCollection.InsertOne(ctx, invoice)
+		if err := redeemPromotions(ctx, stores.Promotions, invoice.Discounts); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, insertErr := stores.Invoices.Insert(ctx, invoice)
 		if insertErr != nil {
+			releasePromotions(ctx, stores.Promotions, invoice.Discounts)
 			msg := "Invoice item was not created"
 			appLogger.Log.WithFields(logrus.Fields{
 				"event": "create_invoice_error",
@@ -189,11 +252,12 @@ func CreateInvoice() gin.HandlerFunc {
 			"time":       time.Now().Format(time.RFC3339),
 			"invoice_id": invoice.Invoice_id,
 		}).Info("Successfully created invoice item")
+		auditDiscounts(ctx, stores.DiscountAudits, invoice.Invoice_id, models.DISCOUNT_APPLIED, uid, invoice.Discounts)
 		c.JSON(http.StatusOK, result)
 	}
 }
 
-func UpdateInvoice() gin.HandlerFunc {
+func UpdateInvoice(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -211,38 +275,95 @@ func UpdateInvoice() gin.HandlerFunc {
 			return
 		}
 
-		filter := bson.M{"invoice_id": invoiceId}
-		var updateObj primitive.D
-
+		// only the fields sent are checked against the model's enums
+		var fields []string
 		if invoice.Payment_method != nil {
-			updateObj = append(updateObj, bson.E{"payment_method", invoice.Payment_method})
+			fields = append(fields, "Payment_method")
 		}
-
 		if invoice.Payment_status != nil {
-			updateObj = append(updateObj, bson.E{"payment_status", invoice.Payment_status})
+			fields = append(fields, "Payment_status")
+		}
+		if len(fields) > 0 {
+			if err := validate.StructPartial(invoice, fields...); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 		}
 
-		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{"updated_at", invoice.Updated_at})
+		existing, err := stores.Invoices.Get(ctx, invoiceId)
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invoice was not found"})
+			return
+		}
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":      "update_invoice_error",
+				"time":       time.Now().Format(time.RFC3339),
+				"invoice_id": invoiceId,
+				"error":      err,
+			}).Error("Error occurred while fetching invoice")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while fetching invoice"})
+			return
+		}
+		currentStatus := models.PAYMENT_PENDING
+		if existing.Payment_status != nil {
+			currentStatus = *existing.Payment_status
+		}
+
+		updateObj := bson.M{}
+		becamePaid := false
 
-		upsert := true
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		// payment status follows the recorded payments: it never goes back to
+		// PENDING once money is in, and is only forced to PAID once nothing is owed
+		if invoice.Payment_status != nil && *invoice.Payment_status == models.PAYMENT_PENDING && currentStatus != models.PAYMENT_PENDING {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("a %s invoice cannot go back to PENDING", currentStatus)})
+			return
+		}
+		if invoice.Payment_status != nil && *invoice.Payment_status != models.PAYMENT_PENDING {
+			if *invoice.Payment_status == models.PAYMENT_PARTIALLY_PAID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "partial payment is recorded through POST /invoices/:invoice_id/payments"})
+				return
+			}
+
+			summary, err := invoiceSummary(stores, existing)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while pricing the invoice"})
+				return
+			}
+			if balance := invoiceBalance(existing, summary); balance.Amount > 0 {
+				appLogger.Log.WithFields(logrus.Fields{
+					"event":      "update_invoice_rejected",
+					"time":       time.Now().Format(time.RFC3339),
+					"invoice_id": invoiceId,
+					"balance":    balance.String(),
+				}).Warn("Invoice cannot be marked paid while a balance is due")
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("invoice still has a balance of %s, record payments first", balance)})
+				return
+			}
+			becamePaid = currentStatus != models.PAYMENT_PAID
 		}
 
-		status := "PENDING"
-		if invoice.Payment_status == nil {
-			invoice.Payment_status = &status
+		if invoice.Payment_method != nil {
+			updateObj["payment_method"] = invoice.Payment_method
 		}
 
-		result, err := invoiceCollection.UpdateOne(
-			ctx,
-			filter,
-			bson.D{
-				{"$set", updateObj},
-			},
-			&opt,
-		)
+		if invoice.Payment_status != nil {
+			updateObj["payment_status"] = invoice.Payment_status
+		}
+
+		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj["updated_at"] = invoice.Updated_at
+
+		// a payment recorded since the checks above changes the status, so the update misses
+		result, err := stores.Invoices.Update(ctx, invoiceId, store.Patch{
+			If:  bson.M{"payment_status": existing.Payment_status},
+			Set: updateObj,
+			Inc: bson.M{"version": int64(1)},
+		})
+		if err == nil && result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "invoice was changed by someone else, try again"})
+			return
+		}
 		if err != nil {
 			msg := "Invoice item update failed"
 			appLogger.Log.WithFields(logrus.Fields{
@@ -254,6 +375,9 @@ func UpdateInvoice() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
+		if becamePaid {
+			metrics.InvoicePaid()
+		}
 
 		appLogger.Log.WithFields(logrus.Fields{
 			"event":      "update_invoice_success",
@@ -263,3 +387,19 @@ func UpdateInvoice() gin.HandlerFunc {
 		c.JSON(http.StatusOK, result)
 	}
 }
+
+// invoiceVersion is the condition matching the invoice's stored version;
+// invoices from before versions were kept have none.
+func invoiceVersion(invoice models.Invoice) interface{} {
+	if invoice.Version == 0 {
+		return nil
+	}
+	return invoice.Version
+}
+
+func valueOf(amount *models.Money) models.Money {
+	if amount == nil {
+		return models.NewMoney(0)
+	}
+	return *amount
+}
@@ -0,0 +1,253 @@
+package controller
+
+import (
+	"context"
+	helper "golang-restaurant-management/helpers"
+	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type KitchenEvent struct {
+	Type       string           `json:"type"`
+	Order_item models.OrderItem `json:"order_item"`
+}
+
+// kitchenBroker fans order item changes out to the kitchen screens connected
+// to this instance. Slow screens miss events rather than blocking ordering.
+type kitchenBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan KitchenEvent]string
+	closing     chan struct{}
+	closeOnce   sync.Once
+}
+
+var kitchen = &kitchenBroker{
+	subscribers: make(map[chan KitchenEvent]string),
+	closing:     make(chan struct{}),
+}
+
+// CloseKitchenStreams ends every open stream. Screens reconnect on their own,
+// so shutdown does not have to wait for them to hang up.
+func CloseKitchenStreams() {
+	kitchen.closeOnce.Do(func() { close(kitchen.closing) })
+}
+
+func (b *kitchenBroker) subscribe(station string) chan KitchenEvent {
+	ch := make(chan KitchenEvent, 64)
+	b.mu.Lock()
+	b.subscribers[ch] = station
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *kitchenBroker) unsubscribe(ch chan KitchenEvent) {
+	b.mu.Lock()
+	delete(b.subscribers, ch)
+	b.mu.Unlock()
+}
+
+func (b *kitchenBroker) publish(eventType string, orderItem models.OrderItem) {
+	event := KitchenEvent{Type: eventType, Order_item: orderItem}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch, station := range b.subscribers {
+		if station != "" && station != orderItem.Station {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":         "kitchen_event_dropped",
+				"time":          time.Now().Format(time.RFC3339),
+				"station":       station,
+				"order_item_id": orderItem.Order_item_id,
+			}).Warn("Kitchen screen is too slow, dropping event")
+		}
+	}
+}
+
+func publishOrderItem(ctx context.Context, orderItems store.OrderItemStore, eventType string, orderItemId string) {
+	orderItem, err := orderItems.Get(ctx, orderItemId)
+	if err != nil {
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":         "kitchen_publish_error",
+			"time":          time.Now().Format(time.RFC3339),
+			"order_item_id": orderItemId,
+			"error":         err,
+		}).Error("Error occurred while loading order item for the kitchen feed")
+		return
+	}
+	kitchen.publish(eventType, orderItem)
+}
+
+func voidKitchenItems(ctx context.Context, orderItemStore store.OrderItemStore, orderId string) {
+	orderItems, err := orderItemStore.VoidPending(ctx, orderId, time.Now())
+	if err != nil {
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":    "void_kitchen_items_error",
+			"time":     time.Now().Format(time.RFC3339),
+			"order_id": orderId,
+			"error":    err,
+		}).Error("Error occurred while pulling order items off the kitchen screens")
+		return
+	}
+
+	for _, orderItem := range orderItems {
+		orderItem.Kitchen_status = models.KITCHEN_VOIDED
+		kitchen.publish("voided", orderItem)
+	}
+}
+
+func GetKitchenItems(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		allOrderItems, err := stores.OrderItems.Pending(ctx, c.Query("station"))
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":   "get_kitchen_items_error",
+				"time":    time.Now().Format(time.RFC3339),
+				"station": c.Query("station"),
+				"error":   err,
+			}).Error("Error occurred while retrieving kitchen items")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while retrieving kitchen items"})
+			return
+		}
+		if allOrderItems == nil {
+			allOrderItems = []models.OrderItem{}
+		}
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":   "get_kitchen_items_success",
+			"time":    time.Now().Format(time.RFC3339),
+			"station": c.Query("station"),
+		}).Info("Successfully retrieved kitchen items")
+		c.JSON(http.StatusOK, allOrderItems)
+	}
+}
+
+// IssueKitchenStreamTicket hands out a short-lived ticket for opening the
+// stream as /kitchen/stream?ticket=..., since EventSource cannot send headers.
+func IssueKitchenStreamTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ticket, expiresAt, err := helper.GenerateStreamTicket(c.GetString("uid"))
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "kitchen_stream_ticket_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"uid":   c.GetString("uid"),
+				"error": err,
+			}).Error("Error occurred while issuing a kitchen stream ticket")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while issuing a kitchen stream ticket"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"ticket": ticket, "expires_at": expiresAt.UTC().Format(time.RFC3339)})
+	}
+}
+
+func StreamKitchenItems() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		station := c.Query("station")
+		events := kitchen.subscribe(station)
+		defer kitchen.unsubscribe(events)
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":   "kitchen_stream_opened",
+			"time":    time.Now().Format(time.RFC3339),
+			"station": station,
+		}).Info("Kitchen screen connected")
+
+		keepAlive := time.NewTicker(15 * time.Second)
+		defer keepAlive.Stop()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case event := <-events:
+				c.SSEvent(event.Type, event)
+				return true
+			case <-keepAlive.C:
+				c.SSEvent("ping", time.Now().Format(time.RFC3339))
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			case <-kitchen.closing:
+				return false
+			}
+		})
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":   "kitchen_stream_closed",
+			"time":    time.Now().Format(time.RFC3339),
+			"station": station,
+		}).Info("Kitchen screen disconnected")
+	}
+}
+
+func BumpOrderItem(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		setKitchenStatus(c, stores.OrderItems, "bump_order_item", models.KITCHEN_PENDING, bson.M{
+			"kitchen_status": models.KITCHEN_BUMPED,
+			"bumped_at":      now,
+			"updated_at":     now,
+		}, "bumped")
+	}
+}
+
+func RecallOrderItem(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		setKitchenStatus(c, stores.OrderItems, "recall_order_item", models.KITCHEN_BUMPED, bson.M{
+			"kitchen_status": models.KITCHEN_PENDING,
+			"bumped_at":      nil,
+			"updated_at":     now,
+		}, "recalled")
+	}
+}
+
+func setKitchenStatus(c *gin.Context, orderItems store.OrderItemStore, event string, from string, set bson.M, eventType string) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	orderItemId := c.Param("order_item_id")
+
+	orderItem, err := orderItems.Modify(ctx, orderItemId, store.Patch{If: bson.M{"kitchen_status": from}, Set: set})
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusConflict, gin.H{"error": "order item was not found or is not " + from})
+		return
+	}
+	if err != nil {
+		msg := "kitchen status update failed"
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":         event + "_error",
+			"time":          time.Now().Format(time.RFC3339),
+			"order_item_id": orderItemId,
+			"error":         err,
+		}).Error(msg)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	kitchen.publish(eventType, orderItem)
+
+	appLogger.Log.WithFields(logrus.Fields{
+		"event":         event + "_success",
+		"time":          time.Now().Format(time.RFC3339),
+		"order_item_id": orderItemId,
+		"station":       orderItem.Station,
+		"changed_by":    c.GetString("uid"),
+	}).Info("Successfully updated kitchen status")
+	c.JSON(http.StatusOK, orderItem)
+}
@@ -2,9 +2,10 @@ package controller
 
 import (
 	"context"
-	"golang-restaurant-management/database"
+	"fmt"
 	appLogger "golang-restaurant-management/logger"
 	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
 	"net/http"
 	"time"
 
@@ -12,30 +13,15 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var menuCollection *mongo.Collection = database.OpenCollection(database.Client, "menu")
-
-func GetMenus() gin.HandlerFunc {
+func GetMenus(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		result, err := menuCollection.Find(context.TODO(), bson.M{})
+		allMenus, err := stores.Menus.All(ctx)
 		if err != nil {
-			appLogger.Log.WithFields(logrus.Fields{
-				"event": "get_menus_error",
-				"time":  time.Now().Format(time.RFC3339),
-				"error": err,
-			}).Error("Error occurred while listing the menu items")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the menu items"})
-			return
-		}
-
-		var allMenus []bson.M
-		if err = result.All(ctx, &allMenus); err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event": "get_menus_error",
 				"time":  time.Now().Format(time.RFC3339),
@@ -52,15 +38,14 @@ func GetMenus() gin.HandlerFunc {
 	}
 }
 
-func GetMenu() gin.HandlerFunc {
+func GetMenu(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		menuId := c.Param("menu_id")
-		var menu models.Menu
 
-		err := menuCollection.FindOne(ctx, bson.M{"menu_id": menuId}).Decode(&menu)
+		menu, err := stores.Menus.Get(ctx, menuId)
 		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event":   "get_menu_error",
@@ -80,7 +65,7 @@ func GetMenu() gin.HandlerFunc {
 	}
 }
 
-func CreateMenu() gin.HandlerFunc {
+func CreateMenu(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var menu models.Menu
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
@@ -96,6 +81,7 @@ func CreateMenu() gin.HandlerFunc {
 			return
 		}
 
+		models.NormalizeSchedule(menu.Dayparts, menu.Overrides)
 		validationErr := validate.Struct(menu)
 		if validationErr != nil {
 			appLogger.Log.WithFields(logrus.Fields{
@@ -107,12 +93,17 @@ func CreateMenu() gin.HandlerFunc {
 			return
 		}
 
+		if err := checkMenuSchedule(menu); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		menu.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		menu.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		menu.ID = primitive.NewObjectID()
 		menu.Menu_id = menu.ID.Hex()
 
-		result, insertErr := menuCollection.InsertOne(ctx, menu)
+		result, insertErr := stores.Menus.Insert(ctx, menu)
 		if insertErr != nil {
 			msg := "Menu item was not created"
 			appLogger.Log.WithFields(logrus.Fields{
@@ -132,11 +123,16 @@ func CreateMenu() gin.HandlerFunc {
 	}
 }
 
-func inTimeSpan(start, end, check time.Time) bool {
-	return start.Before(check) && end.After(check)
+// checkMenuSchedule allows menus that start in the future, so a seasonal menu
+// can be set up ahead of time; the range only has to end after it starts.
+func checkMenuSchedule(menu models.Menu) error {
+	if menu.Start_Date != nil && menu.End_Date != nil && !menu.End_Date.After(*menu.Start_Date) {
+		return fmt.Errorf("end_date must be after start_date")
+	}
+	return models.ValidateSchedule(menu.Dayparts, menu.Overrides)
 }
 
-func UpdateMenu() gin.HandlerFunc {
+func UpdateMenu(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -153,49 +149,51 @@ func UpdateMenu() gin.HandlerFunc {
 		}
 
 		menuId := c.Param("menu_id")
-		filter := bson.M{"menu_id": menuId}
 
-		var updateObj primitive.D
+		updateObj := bson.M{}
+
+		models.NormalizeSchedule(menu.Dayparts, menu.Overrides)
+		if err := validate.StructPartial(menu, "Dayparts", "Overrides"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkMenuSchedule(menu); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "update_menu_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Invalid menu schedule")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		if menu.Start_Date != nil && menu.End_Date != nil {
-			if !inTimeSpan(*menu.Start_Date, *menu.End_Date, time.Now()) {
-				msg := "Kindly retype the time"
-				appLogger.Log.WithFields(logrus.Fields{
-					"event": "update_menu_error",
-					"time":  time.Now().Format(time.RFC3339),
-					"error": msg,
-				}).Error(msg)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-				return
-			}
+			updateObj["start_date"] = menu.Start_Date
+			updateObj["end_date"] = menu.End_Date
+		}
 
-			updateObj = append(updateObj, bson.E{"start_date", menu.Start_Date})
-			updateObj = append(updateObj, bson.E{"end_date", menu.End_Date})
+		if menu.Dayparts != nil {
+			updateObj["dayparts"] = menu.Dayparts
+		}
+		if menu.Overrides != nil {
+			updateObj["overrides"] = menu.Overrides
 		}
 
 		if menu.Name != "" {
-			updateObj = append(updateObj, bson.E{"name", menu.Name})
+			updateObj["name"] = menu.Name
 		}
 		if menu.Category != "" {
-			updateObj = append(updateObj, bson.E{"category", menu.Category})
+			updateObj["category"] = menu.Category
 		}
 
 		menu.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{"updated_at", menu.Updated_at})
+		updateObj["updated_at"] = menu.Updated_at
 
-		upsert := true
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		result, err := stores.Menus.Update(ctx, menuId, store.Patch{Set: updateObj})
+		if err == nil && result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "menu was not found"})
+			return
 		}
-
-		result, err := menuCollection.UpdateOne(
-			ctx,
-			filter,
-			bson.D{
-				{"$set", updateObj},
-			},
-			&opt,
-		)
 		if err != nil {
 			msg := "Menu update failed"
 			appLogger.Log.WithFields(logrus.Fields{
@@ -215,3 +213,70 @@ func UpdateMenu() gin.HandlerFunc {
 		c.JSON(http.StatusOK, result)
 	}
 }
+
+type ActiveMenu struct {
+	models.Menu
+	Foods []models.Food `json:"foods"`
+}
+
+// GetActiveMenus lists what can be ordered right now, or at ?at=<RFC3339>:
+// menus inside their schedule, with only the foods that are not 86'd.
+func GetActiveMenus(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		at := time.Now()
+		if raw := c.Query("at"); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "at must be an RFC3339 time"})
+				return
+			}
+			at = parsed
+		}
+
+		var foods []models.Food
+
+		menus, err := stores.Menus.Find(ctx)
+
+		activeMenus := []ActiveMenu{}
+		menuIndex := map[string]int{}
+		var menuIds []string
+		for _, menu := range menus {
+			if menu.IsActive(at) {
+				menuIndex[menu.Menu_id] = len(activeMenus)
+				activeMenus = append(activeMenus, ActiveMenu{Menu: menu, Foods: []models.Food{}})
+				menuIds = append(menuIds, menu.Menu_id)
+			}
+		}
+
+		if err == nil && len(menuIds) > 0 {
+			foods, err = stores.Foods.ForMenus(ctx, menuIds...)
+		}
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "get_active_menus_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while listing active menus")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing active menus"})
+			return
+		}
+
+		for _, food := range foods {
+			if !food.IsAvailable() {
+				continue
+			}
+			index := menuIndex[*food.Menu_id]
+			activeMenus[index].Foods = append(activeMenus[index].Foods, food)
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event": "get_active_menus_success",
+			"time":  time.Now().Format(time.RFC3339),
+			"menus": len(activeMenus),
+		}).Info("Successfully retrieved active menus")
+		c.JSON(http.StatusOK, activeMenus)
+	}
+}
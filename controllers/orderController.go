@@ -3,9 +3,10 @@ package controller
 import (
 	"context"
 	"fmt"
-	"golang-restaurant-management/database"
 	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/metrics"
 	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
 	"net/http"
 	"time"
 
@@ -13,30 +14,15 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var orderCollection *mongo.Collection = database.OpenCollection(database.Client, "order")
-
-func GetOrders() gin.HandlerFunc {
+func GetOrders(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		result, err := orderCollection.Find(context.TODO(), bson.M{})
+		allOrders, err := stores.Orders.All(ctx)
 		if err != nil {
-			appLogger.Log.WithFields(logrus.Fields{
-				"event": "get_orders_error",
-				"time":  time.Now().Format(time.RFC3339),
-				"error": err,
-			}).Error("Error occurred while listing order items")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing order items"})
-			return
-		}
-
-		var allOrders []bson.M
-		if err = result.All(ctx, &allOrders); err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event": "get_orders_error",
 				"time":  time.Now().Format(time.RFC3339),
@@ -53,15 +39,13 @@ func GetOrders() gin.HandlerFunc {
 	}
 }
 
-func GetOrder() gin.HandlerFunc {
+func GetOrder(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		orderId := c.Param("order_id")
-		var order models.Order
-
-		err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order)
+		order, err := stores.Orders.Get(ctx, orderId)
 		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event":   "get_order_error",
@@ -81,9 +65,8 @@ func GetOrder() gin.HandlerFunc {
 	}
 }
 
-func CreateOrder() gin.HandlerFunc {
+func CreateOrder(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var table models.Table
 		var order models.Order
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -110,7 +93,7 @@ func CreateOrder() gin.HandlerFunc {
 		}
 
 		if order.Table_id != nil {
-			err := tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table)
+			_, err := stores.Tables.Get(ctx, *order.Table_id)
 			if err != nil {
 				msg := fmt.Sprintf("message:Table was not found")
 				appLogger.Log.WithFields(logrus.Fields{
@@ -124,13 +107,16 @@ func CreateOrder() gin.HandlerFunc {
 			}
 		}
 
+		models.NormalizeAllergies(order.Allergies)
+
 		order.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		order.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		placeOrder(&order, c.GetString("uid"))
 
 		order.ID = primitive.NewObjectID()
 		order.Order_id = order.ID.Hex()
 
-		result, insertErr := orderCollection.InsertOne(ctx, order)
+		result, insertErr := stores.Orders.Insert(ctx, order)
 		if insertErr != nil {
 			msg := fmt.Sprintf("order item was not created")
 			appLogger.Log.WithFields(logrus.Fields{
@@ -141,6 +127,7 @@ func CreateOrder() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
+		metrics.OrderCreated()
 
 		appLogger.Log.WithFields(logrus.Fields{
 			"event":   "create_order_success",
@@ -151,14 +138,13 @@ func CreateOrder() gin.HandlerFunc {
 	}
 }
 
-func UpdateOrder() gin.HandlerFunc {
+func UpdateOrder(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var table models.Table
 		var order models.Order
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		var updateObj primitive.D
+		updateObj := bson.M{}
 
 		orderId := c.Param("order_id")
 		if err := c.BindJSON(&order); err != nil {
@@ -172,7 +158,7 @@ func UpdateOrder() gin.HandlerFunc {
 		}
 
 		if order.Table_id != nil {
-			err := tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table)
+			_, err := stores.Tables.Get(ctx, *order.Table_id)
 			if err != nil {
 				msg := fmt.Sprintf("message:Table was not found")
 				appLogger.Log.WithFields(logrus.Fields{
@@ -184,26 +170,26 @@ func UpdateOrder() gin.HandlerFunc {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 				return
 			}
-			updateObj = append(updateObj, bson.E{"table_id", order.Table_id})
+			updateObj["table_id"] = order.Table_id
+		}
+
+		if order.Allergies != nil {
+			if err := validate.Var(order.Allergies, "dive"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			models.NormalizeAllergies(order.Allergies)
+			updateObj["allergies"] = order.Allergies
 		}
 
 		order.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{"updated_at", order.Updated_at})
-
-		upsert := true
-		filter := bson.M{"order_id": orderId}
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
-		}
-
-		result, err := orderCollection.UpdateOne(
-			ctx,
-			filter,
-			bson.D{
-				{"$set", updateObj},
-			},
-			&opt,
-		)
+		updateObj["updated_at"] = order.Updated_at
+
+		result, err := stores.Orders.Update(ctx, orderId, store.Patch{Set: updateObj})
+		if err == nil && result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order was not found"})
+			return
+		}
 		if err != nil {
 			msg := fmt.Sprintf("order item update failed")
 			appLogger.Log.WithFields(logrus.Fields{
@@ -225,16 +211,19 @@ func UpdateOrder() gin.HandlerFunc {
 	}
 }
 
-func OrderItemOrderCreator(order models.Order) string {
+func OrderItemOrderCreator(orders store.OrderStore, order models.Order) string {
 	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	order.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 	order.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	order.ID = primitive.NewObjectID()
-	order.Order_id = order.ID.Hex()
+	// callers that need the id before the order is stored assign it themselves
+	if order.ID.IsZero() {
+		order.ID = primitive.NewObjectID()
+		order.Order_id = order.ID.Hex()
+	}
 
-	_, err := orderCollection.InsertOne(ctx, order)
+	_, err := orders.Insert(ctx, order)
 	if err != nil {
 		appLogger.Log.WithFields(logrus.Fields{
 			"event": "order_item_order_creator_error",
@@ -243,6 +232,7 @@ func OrderItemOrderCreator(order models.Order) string {
 		}).Error("Error occurred while creating order item")
 		return ""
 	}
+	metrics.OrderCreated()
 
 	appLogger.Log.WithFields(logrus.Fields{
 		"event":   "order_item_order_creator_success",
@@ -251,3 +241,141 @@ func OrderItemOrderCreator(order models.Order) string {
 	}).Info("Successfully created order item")
 	return order.Order_id
 }
+
+func placeOrder(order *models.Order, uid string) {
+	status := models.ORDER_PLACED
+	placedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	order.Status = &status
+	order.Status_history = []models.OrderStatusChange{
+		{To: status, Changed_by: uid, Changed_at: placedAt},
+	}
+}
+
+type OrderTransitionRequest struct {
+	Status *string `json:"status" validate:"required"`
+	Reason string  `json:"reason"`
+}
+
+func TransitionOrder(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request OrderTransitionRequest
+		orderId := c.Param("order_id")
+
+		if err := c.BindJSON(&request); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "transition_order_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		order, err := stores.Orders.Get(ctx, orderId)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":    "transition_order_error",
+				"time":     time.Now().Format(time.RFC3339),
+				"order_id": orderId,
+				"error":    err,
+			}).Error("Error occurred while fetching the order")
+			c.JSON(http.StatusNotFound, gin.H{"error": "order was not found"})
+			return
+		}
+
+		from := order.CurrentStatus()
+		to := *request.Status
+
+		if !models.CanTransitionOrder(from, to) {
+			msg := fmt.Sprintf("order cannot move from %s to %s", from, to)
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":    "transition_order_rejected",
+				"time":     time.Now().Format(time.RFC3339),
+				"order_id": orderId,
+				"from":     from,
+				"to":       to,
+			}).Warn(msg)
+			c.JSON(http.StatusConflict, gin.H{"error": msg, "allowed": models.OrderTransitions[from]})
+			return
+		}
+
+		if role := c.GetString("role"); !models.CanMoveOrderTo(role, to) {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":    "transition_order_rejected",
+				"time":     time.Now().Format(time.RFC3339),
+				"order_id": orderId,
+				"to":       to,
+				"role":     role,
+			}).Warn("Role may not move the order to this status")
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("%s cannot move an order to %s", role, to), "allowed_roles": models.OrderTransitionRoles[to]})
+			return
+		}
+		if to == models.ORDER_VOIDED {
+			if request.Reason == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "a reason is required to void an order"})
+				return
+			}
+		}
+
+		changedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		change := models.OrderStatusChange{
+			From:       from,
+			To:         to,
+			Changed_by: c.GetString("uid"),
+			Changed_at: changedAt,
+			Reason:     request.Reason,
+		}
+
+		result, err := stores.Orders.Transition(ctx, order, change)
+		if err != nil {
+			msg := "order transition failed"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":    "transition_order_error",
+				"time":     time.Now().Format(time.RFC3339),
+				"order_id": orderId,
+				"error":    err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "order status was changed by someone else, reload and try again"})
+			return
+		}
+
+		if to == models.ORDER_CANCELLED || to == models.ORDER_VOIDED {
+			voidKitchenItems(ctx, stores.OrderItems, orderId)
+			restockOrder(ctx, stores, orderId, change.Changed_by)
+		}
+
+		if to == models.ORDER_CLOSED && order.Table_id != nil {
+			// the party has left, so the floor shows the table for bussing
+			stores.Tables.Update(ctx, *order.Table_id, store.Patch{Set: bson.M{"needs_cleaning": true, "updated_at": changedAt}})
+		}
+
+		order.Status = &to
+		order.Updated_at = changedAt
+		order.Status_history = append(order.Status_history, change)
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":      "transition_order_success",
+			"time":       time.Now().Format(time.RFC3339),
+			"order_id":   orderId,
+			"from":       from,
+			"to":         to,
+			"changed_by": change.Changed_by,
+		}).Info("Successfully moved order to a new status")
+		c.JSON(http.StatusOK, order)
+	}
+}
@@ -2,45 +2,93 @@ package controller
 
 import (
 	"context"
-	"golang-restaurant-management/database"
+	"fmt"
+	helper "golang-restaurant-management/helpers"
 	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/metrics"
 	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type OrderItemPack struct {
 	Table_id    *string
 	Order_items []models.OrderItem
+	Allergies   []models.AllergyDeclaration `validate:"dive"`
 }
 
-var orderItemCollection *mongo.Collection = database.OpenCollection(database.Client, "orderItem")
+type AllergyWarning struct {
+	Food_id   string  `json:"food_id"`
+	Food_name string  `json:"food_name"`
+	Allergen  string  `json:"allergen"`
+	Severity  string  `json:"severity"`
+	Guest     *string `json:"guest"`
+}
+
+// unavailableReason explains why a food cannot be sold, or is empty when it can.
+func unavailableReason(food models.Food) string {
+	if food.IsAvailable() {
+		return ""
+	}
+	if food.Unavailable_reason != nil {
+		return fmt.Sprintf("%s is not available: %s", valueOfString(food.Name), *food.Unavailable_reason)
+	}
+	return fmt.Sprintf("%s is not available", valueOfString(food.Name))
+}
+
+// allergyWarningsFor checks a food, as made with its modifiers, against the
+// allergies declared on the order. The first severe conflict is returned on
+// its own, since the item must then be refused.
+func allergyWarningsFor(food models.Food, modifiers []models.SelectedModifier, allergies []models.AllergyDeclaration) ([]AllergyWarning, *AllergyWarning) {
+	var warnings []AllergyWarning
+	for _, conflict := range models.ConflictingAllergies(food.AllergensFor(modifiers), allergies) {
+		warning := AllergyWarning{
+			Food_id:   food.Food_id,
+			Food_name: valueOfString(food.Name),
+			Allergen:  *conflict.Allergen,
+			Severity:  *conflict.Severity,
+			Guest:     conflict.Guest,
+		}
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":    "allergy_conflict",
+			"time":     time.Now().Format(time.RFC3339),
+			"food_id":  food.Food_id,
+			"allergen": warning.Allergen,
+			"severity": warning.Severity,
+		}).Warn("Ordered item conflicts with a declared allergy")
+
+		if *conflict.Severity == models.ALLERGY_SEVERE {
+			return warnings, &warning
+		}
+		warnings = append(warnings, warning)
+	}
+	return warnings, nil
+}
 
-func GetOrderItems() gin.HandlerFunc {
+// allergyAlerts are the allergens carried on the item so the kitchen screen
+// shows them next to the dish.
+func allergyAlerts(warnings []AllergyWarning) []string {
+	var alerts []string
+	for _, warning := range warnings {
+		alerts = append(alerts, warning.Allergen)
+	}
+	return alerts
+}
+
+func GetOrderItems(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		result, err := orderItemCollection.Find(context.TODO(), bson.M{})
+		allOrderItems, err := stores.OrderItems.All(ctx)
 		if err != nil {
-			appLogger.Log.WithFields(logrus.Fields{
-				"event": "get_order_items_error",
-				"time":  time.Now().Format(time.RFC3339),
-				"error": err,
-			}).Error("Error occurred while listing ordered items")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing ordered items"})
-			return
-		}
-
-		var allOrderItems []bson.M
-		if err = result.All(ctx, &allOrderItems); err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event": "get_order_items_error",
 				"time":  time.Now().Format(time.RFC3339),
@@ -57,136 +105,240 @@ func GetOrderItems() gin.HandlerFunc {
 	}
 }
 
-func GetOrderItemsByOrder() gin.HandlerFunc {
+func GetOrderItemsByOrder(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		orderId := c.Param("order_id")
 
-		allOrderItems, err := ItemsByOrder(orderId)
+		allOrderItems, err := ItemsByOrder(stores, orderId)
 		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
-				"event":   "get_order_items_by_order_error",
-				"time":    time.Now().Format(time.RFC3339),
+				"event":    "get_order_items_by_order_error",
+				"time":     time.Now().Format(time.RFC3339),
 				"order_id": orderId,
-				"error":   err,
+				"error":    err,
 			}).Error("Error occurred while listing order items by order ID")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing order items by order ID"})
 			return
 		}
 		appLogger.Log.WithFields(logrus.Fields{
-			"event":   "get_order_items_by_order_success",
-			"time":    time.Now().Format(time.RFC3339),
+			"event":    "get_order_items_by_order_success",
+			"time":     time.Now().Format(time.RFC3339),
 			"order_id": orderId,
 		}).Info("Successfully retrieved order items by order ID")
 		c.JSON(http.StatusOK, allOrderItems)
 	}
 }
 
-func ItemsByOrder(id string) (OrderItems []primitive.M, err error) {
+type OrderItemLine struct {
+	Order_item_id string                     `json:"order_item_id" bson:"order_item_id"`
+	Food_id       string                     `json:"food_id" bson:"food_id"`
+	Food_name     string                     `json:"food_name" bson:"food_name"`
+	Food_image    string                     `json:"food_image" bson:"food_image"`
+	Category      string                     `json:"category" bson:"category"`
+	Quantity      int                        `json:"quantity" bson:"quantity"`
+	Size          string                     `json:"size" bson:"size"`
+	Price         models.Money               `json:"price" bson:"price"`
+	Modifiers     []models.SelectedModifier  `json:"modifiers" bson:"modifiers"`
+	Pricing_rule  *models.AppliedPricingRule `json:"pricing_rule" bson:"pricing_rule"`
+	Discount      models.Money               `json:"discount" bson:"discount"`
+	Amount        models.Money               `json:"amount" bson:"amount"`
+	Tax           models.Money               `json:"tax" bson:"tax"`
+	Table_id      string                     `json:"table_id" bson:"table_id"`
+	Table_number  interface{}                `json:"table_number" bson:"table_number"`
+	Order_id      string                     `json:"order_id" bson:"order_id"`
+}
+
+type OrderSummary struct {
+	Order_id       string          `json:"order_id" bson:"order_id"`
+	Table_id       string          `json:"table_id" bson:"table_id"`
+	Table_number   interface{}     `json:"table_number" bson:"table_number"`
+	Total_count    int             `json:"total_count" bson:"total_count"`
+	Order_items    []OrderItemLine `json:"order_items" bson:"order_items"`
+	Subtotal       models.Money    `json:"subtotal" bson:"subtotal"`
+	Discount       models.Money    `json:"discount" bson:"discount"`
+	Service_charge models.Money    `json:"service_charge" bson:"service_charge"`
+	Tax            models.Money    `json:"tax" bson:"tax"`
+	Payment_due    models.Money    `json:"payment_due" bson:"payment_due"`
+}
+
+func (summary OrderSummary) lines() []helper.PriceLine {
+	lines := make([]helper.PriceLine, 0, len(summary.Order_items))
+	for _, item := range summary.Order_items {
+		lines = append(lines, helper.PriceLine{
+			Order_item_id: item.Order_item_id,
+			Food_id:       item.Food_id,
+			Category:      item.Category,
+			Quantity:      int64(item.Quantity),
+			Unit_price:    item.Price,
+			Modifiers:     models.ModifiersTotal(item.Modifiers),
+		})
+	}
+	return lines
+}
+
+// price works out the bill with the given discounts, updating each one's
+// Amount to what it takes off now. A discount the order no longer qualifies
+// for, say because the item it was for was voided, takes nothing off, and
+// stacked discounts never take a line below zero.
+func (summary *OrderSummary) price(tip models.Money, discounts []models.InvoiceDiscount) helper.OrderTotals {
+	lines := summary.lines()
+	for i := range discounts {
+		discounts[i].Amount = models.NewMoney(0)
+		scoped := lines
+		if len(discounts[i].Order_item_ids) > 0 {
+			scoped = nil
+			for _, line := range lines {
+				if containsAny(discounts[i].Order_item_ids, []string{line.Order_item_id}) {
+					scoped = append(scoped, line)
+				}
+			}
+		}
+		perLine, err := helper.DiscountLines(discounts[i].Promotion, scoped)
+		if err != nil {
+			continue
+		}
+		for j := range lines {
+			discount, ok := perLine[lines[j].Order_item_id]
+			if !ok {
+				continue
+			}
+			left := lines[j].Unit_price.Add(lines[j].Modifiers).Times(max(lines[j].Quantity, 1)).Sub(lines[j].Discount)
+			if discount.Amount > left.Amount {
+				discount = left
+			}
+			lines[j].Discount = lines[j].Discount.Add(discount)
+			discounts[i].Amount = discounts[i].Amount.Add(discount)
+		}
+	}
+
+	totals := helper.Pricing.PriceOrder(lines, tip)
+	for i, line := range totals.Lines {
+		summary.Order_items[i].Discount = line.Discount
+		summary.Order_items[i].Amount = line.Amount
+		summary.Order_items[i].Tax = line.Tax
+	}
+	summary.Subtotal = totals.Subtotal
+	summary.Discount = totals.Discount
+	summary.Service_charge = totals.Service_charge
+	summary.Tax = totals.Tax
+	summary.Payment_due = totals.Total
+	return totals
+}
+
+// ItemsByOrder prices an order's items from their locked-in unit prices, with
+// the food, menu and table details the bill shows.
+func ItemsByOrder(stores *store.Store, id string) (OrderItems []OrderSummary, err error) {
 	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	matchStage := bson.D{{"$match", bson.D{{"order_id", id}}}}
-	lookupStage := bson.D{{"$lookup", bson.D{{"from", "food"}, {"localField", "food_id"}, {"foreignField", "food_id"}, {"as", "food"}}}}
-	unwindStage := bson.D{{"$unwind", bson.D{{"path", "$food"}, {"preserveNullAndEmptyArrays", true}}}}
-
-	lookupOrderStage := bson.D{{"$lookup", bson.D{{"from", "order"}, {"localField", "order_id"}, {"foreignField", "order_id"}, {"as", "order"}}}}
-	unwindOrderStage := bson.D{{"$unwind", bson.D{{"path", "$order"}, {"preserveNullAndEmptyArrays", true}}}}
-
-	lookupTableStage := bson.D{{"$lookup", bson.D{{"from", "table"}, {"localField", "order.table_id"}, {"foreignField", "table_id"}, {"as", "table"}}}}
-	unwindTableStage := bson.D{{"$unwind", bson.D{{"path", "$table"}, {"preserveNullAndEmptyArrays", true}}}}
-
-	projectStage := bson.D{
-		{"$project", bson.D{
-			{"id", 0},
-			{"amount", "$food.price"},
-			{"total_count", 1},
-			{"food_name", "$food.name"},
-			{"food_image", "$food.food_image"},
-			{"table_number", "$table.table_number"},
-			{"table_id", "$table.table_id"},
-			{"order_id", "$order.order_id"},
-			{"price", "$food.price"},
-			{"quantity", 1},
-		}}}
-
-	groupStage := bson.D{{"$group", bson.D{{"_id", bson.D{{"order_id", "$order_id"}, {"table_id", "$table_id"}, {"table_number", "$table_number"}}}, {"payment_due", bson.D{{"$sum", "$amount"}}}, {"total_count", bson.D{{"$sum", 1}}}, {"order_items", bson.D{{"$push", "$$ROOT"}}}}}}
-
-	projectStage2 := bson.D{
-		{"$project", bson.D{
-			{"id", 0},
-			{"payment_due", 1},
-			{"total_count", 1},
-			{"table_number", "$_id.table_number"},
-			{"order_items", 1},
-		}}}
-
-	result, err := orderItemCollection.Aggregate(ctx, mongo.Pipeline{
-		matchStage,
-		lookupStage,
-		unwindStage,
-		lookupOrderStage,
-		unwindOrderStage,
-		lookupTableStage,
-		unwindTableStage,
-		projectStage,
-		groupStage,
-		projectStage2})
-
+	orderItems, err := stores.OrderItems.ForOrder(ctx, id)
 	if err != nil {
 		appLogger.Log.WithFields(logrus.Fields{
 			"event": "items_by_order_error",
 			"time":  time.Now().Format(time.RFC3339),
 			"error": err,
-		}).Error("Error occurred while aggregating order items")
+		}).Error("Error occurred while retrieving order items")
 		return nil, err
 	}
+	if len(orderItems) == 0 {
+		return nil, nil
+	}
 
-	if err = result.All(ctx, &OrderItems); err != nil {
-		appLogger.Log.WithFields(logrus.Fields{
-			"event": "items_by_order_error",
-			"time":  time.Now().Format(time.RFC3339),
-			"error": err,
-		}).Error("Error occurred while retrieving aggregated order items")
-		return nil, err
+	// an order or table that has since gone leaves those details blank
+	summary := OrderSummary{Order_id: id, Order_items: []OrderItemLine{}}
+	if order, err := stores.Orders.Get(ctx, id); err == nil && order.Table_id != nil {
+		if table, err := stores.Tables.Get(ctx, *order.Table_id); err == nil {
+			summary.Table_id = table.Table_id
+			if table.Table_number != nil {
+				summary.Table_number = *table.Table_number
+			}
+		}
+	}
+
+	foods := map[string]models.Food{}
+	menus := map[string]models.Menu{}
+	for _, orderItem := range orderItems {
+		foodId := valueOfString(orderItem.Food_id)
+		food, seen := foods[foodId]
+		if !seen {
+			food, _ = stores.Foods.Get(ctx, foodId)
+			foods[foodId] = food
+		}
+		menuId := valueOfString(food.Menu_id)
+		menu, seen := menus[menuId]
+		if !seen && menuId != "" {
+			menu, _ = stores.Menus.Get(ctx, menuId)
+			menus[menuId] = menu
+		}
+
+		// unit_price is locked in when the item is ordered, older items fall back to the menu price
+		line := OrderItemLine{
+			Order_item_id: orderItem.Order_item_id,
+			Food_id:       foodId,
+			Food_name:     valueOfString(food.Name),
+			Food_image:    valueOfString(food.Food_image),
+			Category:      menu.Category,
+			Quantity:      1,
+			Size:          valueOfString(orderItem.Size),
+			Modifiers:     orderItem.Modifiers,
+			Pricing_rule:  orderItem.Pricing_rule,
+			Table_id:      summary.Table_id,
+			Table_number:  summary.Table_number,
+			Order_id:      orderItem.Order_id,
+		}
+		if orderItem.Quantity != nil {
+			line.Quantity = *orderItem.Quantity
+		}
+		if orderItem.Unit_price != nil {
+			line.Price = *orderItem.Unit_price
+		} else if food.Price != nil {
+			line.Price = *food.Price
+		}
+		summary.Total_count += line.Quantity
+		summary.Order_items = append(summary.Order_items, line)
+	}
+	OrderItems = []OrderSummary{summary}
+
+	for i := range OrderItems {
+		OrderItems[i].price(models.NewMoney(0), nil)
 	}
 
 	appLogger.Log.WithFields(logrus.Fields{
-		"event":   "items_by_order_success",
-		"time":    time.Now().Format(time.RFC3339),
+		"event":    "items_by_order_success",
+		"time":     time.Now().Format(time.RFC3339),
 		"order_id": id,
 	}).Info("Successfully retrieved order items by order ID")
 	return OrderItems, nil
 }
 
-func GetOrderItem() gin.HandlerFunc {
+func GetOrderItem(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		orderItemId := c.Param("order_item_id")
-		var orderItem models.OrderItem
 
-		err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem)
+		orderItem, err := stores.OrderItems.Get(ctx, orderItemId)
 		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
-				"event":        "get_order_item_error",
-				"time":         time.Now().Format(time.RFC3339),
+				"event":         "get_order_item_error",
+				"time":          time.Now().Format(time.RFC3339),
 				"order_item_id": orderItemId,
-				"error":        err,
+				"error":         err,
 			}).Error("Error occurred while listing ordered item")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing ordered item"})
 			return
 		}
 		appLogger.Log.WithFields(logrus.Fields{
-			"event":        "get_order_item_success",
-			"time":         time.Now().Format(time.RFC3339),
+			"event":         "get_order_item_success",
+			"time":          time.Now().Format(time.RFC3339),
 			"order_item_id": orderItemId,
 		}).Info("Successfully retrieved order item")
 		c.JSON(http.StatusOK, orderItem)
 	}
 }
 
-func UpdateOrderItem() gin.HandlerFunc {
+func UpdateOrderItem(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -194,7 +346,6 @@ func UpdateOrderItem() gin.HandlerFunc {
 		var orderItem models.OrderItem
 
 		orderItemId := c.Param("order_item_id")
-		filter := bson.M{"order_item_id": orderItemId}
 
 		if err := c.BindJSON(&orderItem); err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
@@ -206,57 +357,221 @@ func UpdateOrderItem() gin.HandlerFunc {
 			return
 		}
 
-		var updateObj primitive.D
+		current, err := stores.OrderItems.Get(ctx, orderItemId)
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order item was not found"})
+			return
+		}
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":         "update_order_item_error",
+				"time":          time.Now().Format(time.RFC3339),
+				"order_item_id": orderItemId,
+				"error":         err,
+			}).Error("Error occurred while fetching order item")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while fetching order item"})
+			return
+		}
 
+		// a settled order already had its stock returned or used up, so a
+		// change now would move stock nothing ever reverses
+		order, err := stores.Orders.Get(ctx, current.Order_id)
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusConflict, gin.H{"error": "the item's order was not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while fetching the order"})
+			return
+		}
+		if !order.IsOpen() {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("order is %s, its items can no longer be changed", order.CurrentStatus())})
+			return
+		}
+
+		// prices are only lowered by hand through a manual discount on the
+		// invoice, which checks the role and keeps the reason
 		if orderItem.Unit_price != nil {
-			updateObj = append(updateObj, bson.E{"unit_price", *orderItem.Unit_price})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unit_price cannot be changed, apply a manual discount to the invoice instead"})
+			return
 		}
 
+		updateObj := bson.M{}
+
 		if orderItem.Quantity != nil {
-			updateObj = append(updateObj, bson.E{"quantity", *orderItem.Quantity})
+			if *orderItem.Quantity < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be at least 1"})
+				return
+			}
+			updateObj["quantity"] = *orderItem.Quantity
 		}
 
 		if orderItem.Food_id != nil {
-			updateObj = append(updateObj, bson.E{"food_id", *orderItem.Food_id})
+			updateObj["food_id"] = *orderItem.Food_id
 		}
 
-		orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{"updated_at", orderItem.Updated_at})
+		// a new food, size or set of modifiers is checked and priced against the food
+		if orderItem.Size != nil || orderItem.Food_id != nil || orderItem.Modifiers != nil {
+			foodChanged := orderItem.Food_id != nil && *orderItem.Food_id != valueOfString(current.Food_id)
+			if orderItem.Food_id == nil {
+				orderItem.Food_id = current.Food_id
+			}
+			if orderItem.Size == nil {
+				orderItem.Size = current.Size
+			}
 
-		upsert := true
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+			food, err := stores.Foods.Get(ctx, valueOfString(orderItem.Food_id))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "food was not found"})
+				return
+			}
+			// swapping in a food is selling it, so it must be on sale like a new item
+			if msg := unavailableReason(food); foodChanged && msg != "" {
+				c.JSON(http.StatusConflict, gin.H{"error": msg, "food_id": food.Food_id})
+				return
+			}
+
+			size := ""
+			if orderItem.Size != nil {
+				size = strings.ToUpper(*orderItem.Size)
+			}
+			unitPrice, err := helper.Pricing.UnitPrice(food, size)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj["size"] = size
+			// rules are matched at the time the item was ordered, not when it was corrected
+			pricingRules, err := activePricingRules(ctx, stores.PricingRules)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while loading pricing rules"})
+				return
+			}
+			menu, _ := stores.Menus.Get(ctx, valueOfString(food.Menu_id))
+			unitPrice, rule := models.BestPricingRule(pricingRules, food, menu, unitPrice, current.Created_at)
+			updateObj["unit_price"] = unitPrice
+			updateObj["pricing_rule"] = rule
+
+			if orderItem.Modifiers != nil || foodChanged {
+				selections := current.Modifiers
+				if orderItem.Modifiers != nil {
+					selections = orderItem.Modifiers
+				}
+				modifiers, err := food.ResolveModifiers(selections)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				updateObj["modifiers"] = modifiers
+
+				warnings, severe := allergyWarningsFor(food, modifiers, order.Allergies)
+				if severe != nil {
+					msg := fmt.Sprintf("%s contains %s, which a guest is severely allergic to", severe.Food_name, severe.Allergen)
+					c.JSON(http.StatusUnprocessableEntity, gin.H{"error": msg, "allergy_warning": *severe})
+					return
+				}
+				updateObj["allergy_alerts"] = allergyAlerts(warnings)
+			}
+		}
+
+		// a different food or quantity takes its portions and stock again
+		var restock []models.StockMovement
+		claimedPortions := map[string]int{}
+		committed := false
+		defer func() {
+			if !committed {
+				releasePortions(ctx, stores.Foods, claimedPortions)
+			}
+		}()
+		updated := current
+		if orderItem.Food_id != nil {
+			updated.Food_id = orderItem.Food_id
+		}
+		if orderItem.Quantity != nil {
+			updated.Quantity = orderItem.Quantity
+		}
+		if valueOfString(updated.Food_id) != valueOfString(current.Food_id) || quantityOf(updated) != quantityOf(current) {
+			before, err := stores.Foods.Get(ctx, valueOfString(current.Food_id))
+			if err != nil && err != store.ErrNotFound {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while fetching food"})
+				return
+			}
+			after, err := stores.Foods.Get(ctx, valueOfString(updated.Food_id))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "food was not found"})
+				return
+			}
+
+			claim := quantityOf(updated)
+			if before.Food_id == after.Food_id {
+				claim -= quantityOf(current)
+			}
+			if after.Remaining_portions != nil && claim > 0 {
+				if err := claimPortions(ctx, stores.Foods, after, claim); err != nil {
+					c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "food_id": after.Food_id})
+					return
+				}
+				claimedPortions[after.Food_id] += claim
+			}
+
+			// portions given back are only released once the change is saved
+			returned := map[string]int{}
+			if after.Remaining_portions != nil && claim < 0 {
+				returned[after.Food_id] = -claim
+			}
+			if before.Food_id != after.Food_id && before.Remaining_portions != nil {
+				returned[before.Food_id] += quantityOf(current)
+			}
+			defer func() {
+				if committed {
+					releasePortions(ctx, stores.Foods, returned)
+				}
+			}()
+
+			restock = redepletionFor(before, current, after, updated, c.GetString("uid"))
 		}
 
-		result, err := orderItemCollection.UpdateOne(
-			ctx,
-			filter,
-			bson.D{
-				{"$set", updateObj},
-			},
-			&opt,
-		)
+		orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj["updated_at"] = orderItem.Updated_at
+
+		result, err := stores.OrderItems.Update(ctx, orderItemId, store.Patch{Set: updateObj})
+		if err == nil && result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order item was not found"})
+			return
+		}
 		if err != nil {
 			msg := "Order item update failed"
 			appLogger.Log.WithFields(logrus.Fields{
-				"event":        "update_order_item_error",
-				"time":         time.Now().Format(time.RFC3339),
+				"event":         "update_order_item_error",
+				"time":          time.Now().Format(time.RFC3339),
 				"order_item_id": orderItemId,
-				"error":        err,
+				"error":         err,
 			}).Error(msg)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
+		committed = true
+		publishOrderItem(ctx, stores.OrderItems, "updated", orderItemId)
+
+		if err := recordStockMovements(ctx, stores, restock); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":         "deplete_stock_error",
+				"time":          time.Now().Format(time.RFC3339),
+				"order_item_id": orderItemId,
+				"error":         err,
+			}).Error("Error occurred while depleting stock for a corrected order item")
+		}
+
 		appLogger.Log.WithFields(logrus.Fields{
-			"event":        "update_order_item_success",
-			"time":         time.Now().Format(time.RFC3339),
+			"event":         "update_order_item_success",
+			"time":          time.Now().Format(time.RFC3339),
 			"order_item_id": orderItemId,
 		}).Info("Successfully updated order item")
 		c.JSON(http.StatusOK, result)
 	}
 }
 
-func CreateOrderItem() gin.HandlerFunc {
+func CreateOrderItem(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -274,11 +589,41 @@ func CreateOrderItem() gin.HandlerFunc {
 			return
 		}
 
+		if err := validate.Struct(orderItemPack); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		models.NormalizeAllergies(orderItemPack.Allergies)
+
 		order.Order_Date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 
-		orderItemsToBeInserted := []interface{}{}
+		orderItemsToBeInserted := []models.OrderItem{}
+		stockMovements := []models.StockMovement{}
+		claimedPortions := map[string]int{}
+		committed := false
+		defer func() {
+			if !committed {
+				releasePortions(ctx, stores.Foods, claimedPortions)
+			}
+		}()
+		allergyWarnings := []AllergyWarning{}
+		pricingRules, err := activePricingRules(ctx, stores.PricingRules)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_order_item_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while loading pricing rules")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while loading pricing rules"})
+			return
+		}
 		order.Table_id = orderItemPack.Table_id
-		order_id := OrderItemOrderCreator(order)
+		order.Allergies = orderItemPack.Allergies
+		placeOrder(&order, c.GetString("uid"))
+		// the order is only stored once every item has been checked and priced
+		order.ID = primitive.NewObjectID()
+		order.Order_id = order.ID.Hex()
+		order_id := order.Order_id
 
 		for _, orderItem := range orderItemPack.Order_items {
 			orderItem.Order_id = order_id
@@ -293,30 +638,151 @@ func CreateOrderItem() gin.HandlerFunc {
 				c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
 				return
 			}
+
+			food, err := stores.Foods.Get(ctx, *orderItem.Food_id)
+			if err != nil {
+				msg := "food was not found"
+				appLogger.Log.WithFields(logrus.Fields{
+					"event":   "create_order_item_error",
+					"time":    time.Now().Format(time.RFC3339),
+					"food_id": orderItem.Food_id,
+					"error":   err,
+				}).Error(msg)
+				c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+				return
+			}
+
+			menu, err := stores.Menus.Get(ctx, valueOfString(food.Menu_id))
+			if err == nil && !menu.IsActive(time.Now()) {
+				msg := fmt.Sprintf("%s is on the %s menu, which is not being served right now", valueOfString(food.Name), menu.Name)
+				c.JSON(http.StatusConflict, gin.H{"error": msg, "food_id": food.Food_id, "menu_id": menu.Menu_id})
+				return
+			}
+
+			if msg := unavailableReason(food); msg != "" {
+				c.JSON(http.StatusConflict, gin.H{"error": msg, "food_id": food.Food_id})
+				return
+			}
+
+			orderItem.Station = models.DEFAULT_STATION
+			if food.Station != nil && *food.Station != "" {
+				orderItem.Station = *food.Station
+			}
+			orderItem.Kitchen_status = models.KITCHEN_PENDING
+
+			size := ""
+			if orderItem.Size != nil {
+				size = strings.ToUpper(*orderItem.Size)
+				orderItem.Size = &size
+			}
+			unitPrice, err := helper.Pricing.UnitPrice(food, size)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			unitPrice, orderItem.Pricing_rule = models.BestPricingRule(pricingRules, food, menu, unitPrice, time.Now())
+
+			orderItem.Modifiers, err = food.ResolveModifiers(orderItem.Modifiers)
+			if err != nil {
+				appLogger.Log.WithFields(logrus.Fields{
+					"event":   "create_order_item_error",
+					"time":    time.Now().Format(time.RFC3339),
+					"food_id": orderItem.Food_id,
+					"error":   err,
+				}).Error("Invalid modifiers")
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			warnings, severe := allergyWarningsFor(food, orderItem.Modifiers, order.Allergies)
+			if severe != nil {
+				msg := fmt.Sprintf("%s contains %s, which a guest is severely allergic to", severe.Food_name, severe.Allergen)
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": msg, "allergy_warning": *severe})
+				return
+			}
+			allergyWarnings = append(allergyWarnings, warnings...)
+			orderItem.Allergy_alerts = allergyAlerts(warnings)
+
+			if food.Remaining_portions != nil {
+				if err := claimPortions(ctx, stores.Foods, food, *orderItem.Quantity); err != nil {
+					c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "food_id": food.Food_id})
+					return
+				}
+				claimedPortions[food.Food_id] += *orderItem.Quantity
+			}
+
 			orderItem.ID = primitive.NewObjectID()
 			orderItem.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 			orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 			orderItem.Order_item_id = orderItem.ID.Hex()
-			var num = toFixed(*orderItem.Unit_price, 2)
-			orderItem.Unit_price = &num
+			orderItem.Unit_price = &unitPrice
 			orderItemsToBeInserted = append(orderItemsToBeInserted, orderItem)
+			stockMovements = append(stockMovements, depletionFor(food, orderItem, c.GetString("uid"))...)
+		}
+
+		if OrderItemOrderCreator(stores.Orders, order) == "" {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while creating the order"})
+			return
 		}
 
-		insertedOrderItems, err := orderItemCollection.InsertMany(ctx, orderItemsToBeInserted)
+		insertedOrderItems, err := stores.OrderItems.InsertMany(ctx, orderItemsToBeInserted)
 		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
-				"event": "create_order_item_error",
-				"time":  time.Now().Format(time.RFC3339),
-				"error": err,
+				"event":    "create_order_item_error",
+				"time":     time.Now().Format(time.RFC3339),
+				"order_id": order_id,
+				"error":    err,
 			}).Error("Error occurred while inserting order items")
+			// an order without its items would hold the table open
+			if err := stores.Orders.Delete(ctx, order_id); err != nil {
+				appLogger.Log.WithFields(logrus.Fields{
+					"event":    "create_order_item_error",
+					"time":     time.Now().Format(time.RFC3339),
+					"order_id": order_id,
+					"error":    err,
+				}).Error("Error occurred while removing the order after its items failed")
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while inserting order items"})
 			return
 		}
+		committed = true
+		metrics.OrderItemsCreated(len(orderItemsToBeInserted))
+
+		for _, orderItem := range orderItemsToBeInserted {
+			kitchen.publish("created", orderItem)
+		}
+
+		// the items are already with the kitchen, so a stock error is logged rather than failing the order
+		if err := recordStockMovements(ctx, stores, stockMovements); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":    "deplete_stock_error",
+				"time":     time.Now().Format(time.RFC3339),
+				"order_id": order_id,
+				"error":    err,
+			}).Error("Error occurred while depleting stock for order items")
+		}
+
 		appLogger.Log.WithFields(logrus.Fields{
-			"event":   "create_order_item_success",
-			"time":    time.Now().Format(time.RFC3339),
+			"event":    "create_order_item_success",
+			"time":     time.Now().Format(time.RFC3339),
 			"order_id": order_id,
 		}).Info("Successfully created order items")
-		c.JSON(http.StatusOK, insertedOrderItems)
+		c.JSON(http.StatusOK, gin.H{"InsertedIDs": insertedOrderItems.InsertedIDs, "allergy_warnings": allergyWarnings})
+	}
+}
+
+// quantityOf is how many of the item were ordered; items from before
+// quantities were recorded count as one.
+func quantityOf(orderItem models.OrderItem) int {
+	if orderItem.Quantity == nil {
+		return 1
+	}
+	return *orderItem.Quantity
+}
+
+func valueOfString(value *string) string {
+	if value == nil {
+		return ""
 	}
+	return *value
 }
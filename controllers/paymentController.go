@@ -0,0 +1,366 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/metrics"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ItemShare struct {
+	Order_item_id string       `json:"order_item_id"`
+	Food_name     string       `json:"food_name"`
+	Amount        models.Money `json:"amount"`
+}
+
+type SplitView struct {
+	Invoice_id string         `json:"invoice_id"`
+	Total      models.Money   `json:"total"`
+	Balance    models.Money   `json:"balance"`
+	Shares     []models.Money `json:"shares,omitempty"`
+	Items      []ItemShare    `json:"items,omitempty"`
+}
+
+func invoiceSummary(stores *store.Store, invoice models.Invoice) (OrderSummary, error) {
+	summary := OrderSummary{}
+	allOrderItems, err := ItemsByOrder(stores, invoice.Order_id)
+	if err != nil {
+		return summary, err
+	}
+	if len(allOrderItems) > 0 {
+		summary = allOrderItems[0]
+	}
+	summary.price(valueOf(invoice.Tip), invoice.Discounts)
+	return summary, nil
+}
+
+// invoices created before totals were frozen are priced from their order items
+func invoiceTotal(invoice models.Invoice, summary OrderSummary) models.Money {
+	if invoice.Total != nil {
+		return *invoice.Total
+	}
+	return summary.Payment_due
+}
+
+func invoiceBalance(invoice models.Invoice, summary OrderSummary) models.Money {
+	return invoiceTotal(invoice, summary).Sub(valueOf(invoice.Amount_paid))
+}
+
+// itemsShare is what the given items cost including their tax and their slice
+// of the service charge.
+func itemsShare(summary OrderSummary, orderItemIds []string) ([]ItemShare, error) {
+	lines := map[string]OrderItemLine{}
+	for _, line := range summary.Order_items {
+		lines[line.Order_item_id] = line
+	}
+
+	var shares []ItemShare
+	for _, orderItemId := range orderItemIds {
+		line, ok := lines[orderItemId]
+		if !ok {
+			return nil, fmt.Errorf("order item %s is not on this invoice", orderItemId)
+		}
+		serviceShare := summary.Service_charge.Prorate(line.Amount, summary.Subtotal.Sub(summary.Discount))
+		shares = append(shares, ItemShare{
+			Order_item_id: orderItemId,
+			Food_name:     line.Food_name,
+			Amount:        line.Amount.Add(line.Tax).Add(serviceShare),
+		})
+	}
+	return shares, nil
+}
+
+func containsAny(values []string, candidates []string) bool {
+	for _, value := range values {
+		for _, candidate := range candidates {
+			if value == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func CreatePayment(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var payment models.Payment
+		invoiceId := c.Param("invoice_id")
+
+		if err := c.BindJSON(&payment); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_payment_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(payment)
+		if validationErr != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_payment_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": validationErr,
+			}).Error("Validation error")
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		invoice, err := stores.Invoices.Get(ctx, invoiceId)
+		if err != nil {
+			msg := "invoice was not found"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":      "create_payment_error",
+				"time":       time.Now().Format(time.RFC3339),
+				"invoice_id": invoiceId,
+				"error":      err,
+			}).Error(msg)
+			c.JSON(http.StatusNotFound, gin.H{"error": msg})
+			return
+		}
+
+		if invoice.Payment_status != nil && *invoice.Payment_status == models.PAYMENT_PAID {
+			c.JSON(http.StatusConflict, gin.H{"error": "invoice is already paid"})
+			return
+		}
+
+		summary, err := invoiceSummary(stores, invoice)
+		if err != nil {
+			msg := "error occurred while pricing the invoice"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":      "create_payment_error",
+				"time":       time.Now().Format(time.RFC3339),
+				"invoice_id": invoiceId,
+				"error":      err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		total := invoiceTotal(invoice, summary)
+		balance := invoiceBalance(invoice, summary)
+
+		if len(payment.Order_item_ids) > 0 {
+			if containsAny(invoice.Paid_items, payment.Order_item_ids) {
+				c.JSON(http.StatusConflict, gin.H{"error": "some of these items have already been paid for"})
+				return
+			}
+
+			shares, err := itemsShare(summary, payment.Order_item_ids)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			amount := models.NewMoney(0)
+			for _, share := range shares {
+				amount = amount.Add(share.Amount)
+			}
+			// rounding on the last items can leave a cent more than is owed
+			if amount.Amount > balance.Amount {
+				amount = balance
+			}
+			payment.Amount = &amount
+		}
+
+		if payment.Amount == nil || payment.Amount.Amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a positive amount or a list of order_item_ids is required"})
+			return
+		}
+
+		if payment.Amount.Amount > balance.Amount {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("amount %s is more than the balance of %s", payment.Amount, balance)})
+			return
+		}
+
+		tip := valueOf(payment.Tip)
+		if tip.Amount < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tip cannot be negative"})
+			return
+		}
+		payment.Tip = &tip
+
+		payment.ID = primitive.NewObjectID()
+		payment.Payment_id = payment.ID.Hex()
+		payment.Invoice_id = invoiceId
+		payment.Created_by = c.GetString("uid")
+		payment.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if _, err := stores.Payments.Insert(ctx, payment); err != nil {
+			msg := "payment was not recorded"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":      "create_payment_error",
+				"time":       time.Now().Format(time.RFC3339),
+				"invoice_id": invoiceId,
+				"error":      err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		amountPaid := valueOf(invoice.Amount_paid).Add(*payment.Amount)
+		tipsCollected := valueOf(invoice.Tips_collected).Add(tip)
+		status := models.PAYMENT_PARTIALLY_PAID
+		if amountPaid.Amount >= total.Amount {
+			status = models.PAYMENT_PAID
+		}
+
+		// the invoice only moves if no payment or discount changed it since we
+		// read it, so the total written here is never a stale one
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := stores.Invoices.RecordPayment(ctx, invoiceId, invoice.Version, payment.Order_item_ids, bson.M{
+			"total":          total,
+			"amount_paid":    amountPaid,
+			"tips_collected": tipsCollected,
+			"payment_status": status,
+			"payment_method": payment.Payment_method,
+			"updated_at":     updatedAt,
+		})
+		if err != nil || result.MatchedCount == 0 {
+			stores.Payments.Delete(ctx, payment.Payment_id)
+
+			if err != nil {
+				msg := "invoice balance update failed"
+				appLogger.Log.WithFields(logrus.Fields{
+					"event":      "create_payment_error",
+					"time":       time.Now().Format(time.RFC3339),
+					"invoice_id": invoiceId,
+					"error":      err,
+				}).Error(msg)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+				return
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "the invoice changed while the payment was recorded, reload and try again"})
+			return
+		}
+		metrics.PaymentRecorded(*payment.Payment_method, *payment.Amount, tip)
+		if status == models.PAYMENT_PAID {
+			metrics.InvoicePaid()
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":          "create_payment_success",
+			"time":           time.Now().Format(time.RFC3339),
+			"invoice_id":     invoiceId,
+			"payment_id":     payment.Payment_id,
+			"amount":         payment.Amount.String(),
+			"payment_status": status,
+		}).Info("Successfully recorded payment")
+		c.JSON(http.StatusOK, gin.H{
+			"payment":        payment,
+			"amount_paid":    amountPaid,
+			"balance":        total.Sub(amountPaid),
+			"payment_status": status,
+		})
+	}
+}
+
+func GetPayments(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		invoiceId := c.Param("invoice_id")
+
+		allPayments, err := stores.Payments.ForInvoice(ctx, invoiceId)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":      "get_payments_error",
+				"time":       time.Now().Format(time.RFC3339),
+				"invoice_id": invoiceId,
+				"error":      err,
+			}).Error("Error occurred while listing payments")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing payments"})
+			return
+		}
+		if allPayments == nil {
+			allPayments = []models.Payment{}
+		}
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":      "get_payments_success",
+			"time":       time.Now().Format(time.RFC3339),
+			"invoice_id": invoiceId,
+		}).Info("Successfully retrieved payments")
+		c.JSON(http.StatusOK, allPayments)
+	}
+}
+
+// SplitInvoice quotes the remaining balance either evenly (?ways=3) or per
+// unpaid order item, for the waiter to turn into payments.
+func SplitInvoice(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		invoiceId := c.Param("invoice_id")
+
+		invoice, err := stores.Invoices.Get(ctx, invoiceId)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":      "split_invoice_error",
+				"time":       time.Now().Format(time.RFC3339),
+				"invoice_id": invoiceId,
+				"error":      err,
+			}).Error("Error occurred while fetching the invoice")
+			c.JSON(http.StatusNotFound, gin.H{"error": "invoice was not found"})
+			return
+		}
+
+		summary, err := invoiceSummary(stores, invoice)
+		if err != nil {
+			msg := "error occurred while pricing the invoice"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":      "split_invoice_error",
+				"time":       time.Now().Format(time.RFC3339),
+				"invoice_id": invoiceId,
+				"error":      err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		view := SplitView{
+			Invoice_id: invoiceId,
+			Total:      invoiceTotal(invoice, summary),
+			Balance:    invoiceBalance(invoice, summary),
+		}
+
+		if c.Query("ways") != "" {
+			ways, err := strconv.Atoi(c.Query("ways"))
+			if err != nil || ways < 1 || ways > 50 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "ways must be a number between 1 and 50"})
+				return
+			}
+			view.Shares = view.Balance.Split(int64(ways))
+		} else {
+			var unpaid []string
+			for _, line := range summary.Order_items {
+				if !containsAny(invoice.Paid_items, []string{line.Order_item_id}) {
+					unpaid = append(unpaid, line.Order_item_id)
+				}
+			}
+			view.Items, _ = itemsShare(summary, unpaid)
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":      "split_invoice_success",
+			"time":       time.Now().Format(time.RFC3339),
+			"invoice_id": invoiceId,
+		}).Info("Successfully split invoice")
+		c.JSON(http.StatusOK, view)
+	}
+}
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"context"
+	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// activePricingRules loads the enabled rules once per request; whether each
+// one is in its window is decided when an item is priced.
+func activePricingRules(ctx context.Context, pricingRules store.PricingRuleStore) ([]models.PricingRule, error) {
+	return pricingRules.Enabled(ctx)
+}
+
+func GetPricingRules(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		rules, err := stores.PricingRules.List(ctx)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "get_pricing_rules_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while listing pricing rules")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing pricing rules"})
+			return
+		}
+
+		if rules == nil {
+			rules = []models.PricingRule{}
+		}
+		appLogger.Log.WithFields(logrus.Fields{
+			"event": "get_pricing_rules_success",
+			"time":  time.Now().Format(time.RFC3339),
+		}).Info("Successfully retrieved pricing rules")
+		c.JSON(http.StatusOK, rules)
+	}
+}
+
+func CreatePricingRule(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var rule models.PricingRule
+
+		if err := c.BindJSON(&rule); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_pricing_rule_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if rule.Kind != nil {
+			kind := strings.ToUpper(*rule.Kind)
+			rule.Kind = &kind
+		}
+		models.NormalizeSchedule(rule.Windows, nil)
+		validationErr := validate.Struct(rule)
+		if validationErr == nil {
+			validationErr = rule.Validate()
+		}
+		if validationErr != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_pricing_rule_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": validationErr,
+			}).Error("Validation error")
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		enabled := rule.IsEnabled()
+		rule.Enabled = &enabled
+		rule.Created_by = c.GetString("uid")
+		rule.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		rule.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		rule.ID = primitive.NewObjectID()
+		rule.Pricing_rule_id = rule.ID.Hex()
+
+		result, insertErr := stores.PricingRules.Insert(ctx, rule)
+		if insertErr != nil {
+			msg := "pricing rule was not created"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_pricing_rule_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": insertErr,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":           "create_pricing_rule_success",
+			"time":            time.Now().Format(time.RFC3339),
+			"pricing_rule_id": rule.Pricing_rule_id,
+		}).Info("Successfully created pricing rule")
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// UpdatePricingRule replaces the fields sent and re-validates the rule as a
+// whole, so e.g. switching kind to FIXED without a fixed_price is refused.
+func UpdatePricingRule(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ruleId := c.Param("pricing_rule_id")
+
+		rule, err := stores.PricingRules.Get(ctx, ruleId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "pricing rule was not found"})
+			return
+		}
+
+		if err := c.BindJSON(&rule); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "update_pricing_rule_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if rule.Kind != nil {
+			kind := strings.ToUpper(*rule.Kind)
+			rule.Kind = &kind
+		}
+		models.NormalizeSchedule(rule.Windows, nil)
+		validationErr := validate.Struct(rule)
+		if validationErr == nil {
+			validationErr = rule.Validate()
+		}
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		rule.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj := bson.M{
+			"name":           rule.Name,
+			"kind":           rule.Kind,
+			"percentage_off": rule.Percentage_off,
+			"fixed_price":    rule.Fixed_price,
+			"food_ids":       rule.Food_ids,
+			"menu_ids":       rule.Menu_ids,
+			"categories":     rule.Categories,
+			"windows":        rule.Windows,
+			"start_date":     rule.Start_date,
+			"end_date":       rule.End_date,
+			"priority":       rule.Priority,
+			"enabled":        rule.IsEnabled(),
+			"updated_at":     rule.Updated_at,
+		}
+
+		result, err := stores.PricingRules.Update(ctx, ruleId, store.Patch{Set: updateObj})
+		if err != nil {
+			msg := "pricing rule update failed"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":           "update_pricing_rule_error",
+				"time":            time.Now().Format(time.RFC3339),
+				"pricing_rule_id": ruleId,
+				"error":           err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":           "update_pricing_rule_success",
+			"time":            time.Now().Format(time.RFC3339),
+			"pricing_rule_id": ruleId,
+		}).Info("Successfully updated pricing rule")
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,650 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	helper "golang-restaurant-management/helpers"
+	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type DiscountRequest struct {
+	Code           *string       `json:"code"`
+	Percentage_off *float64      `json:"percentage_off" validate:"omitempty,gt=0,lte=100"`
+	Amount_off     *models.Money `json:"amount_off"`
+	Order_item_ids []string      `json:"order_item_ids"`
+	Reason         string        `json:"reason" validate:"max=500"`
+}
+
+// automaticDiscounts are the running promotions without a code that the
+// order qualifies for.
+func automaticDiscounts(ctx context.Context, promotionStore store.PromotionStore, summary OrderSummary, uid string, at time.Time) ([]models.InvoiceDiscount, error) {
+	promotions, err := promotionStore.Automatic(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	discounts := []models.InvoiceDiscount{}
+	for _, promotion := range promotions {
+		if promotion.Redeemable(at) != nil {
+			continue
+		}
+		if _, err := helper.DiscountLines(promotion, summary.lines()); err != nil {
+			continue
+		}
+		discounts = append(discounts, newDiscount(promotion, uid, at))
+	}
+	return discounts, nil
+}
+
+// discountForCode looks a code up and checks the order qualifies for it. The
+// use is only counted by redeemPromotion once the invoice is saved.
+func discountForCode(ctx context.Context, promotions store.PromotionStore, code string, summary OrderSummary, existing []models.InvoiceDiscount, uid string, at time.Time) (models.InvoiceDiscount, error) {
+	code = models.NormalizeCode(code)
+	promotion, err := promotions.ByCode(ctx, code)
+	if err != nil {
+		return models.InvoiceDiscount{}, fmt.Errorf("%s is not a valid code", code)
+	}
+	if err := promotion.Redeemable(at); err != nil {
+		return models.InvoiceDiscount{}, err
+	}
+	for _, discount := range existing {
+		if discount.Promotion.Promotion_id == promotion.Promotion_id {
+			return models.InvoiceDiscount{}, fmt.Errorf("%s is already on this invoice", code)
+		}
+	}
+	if _, err := helper.DiscountLines(promotion, summary.lines()); err != nil {
+		return models.InvoiceDiscount{}, err
+	}
+	return newDiscount(promotion, uid, at), nil
+}
+
+func newDiscount(promotion models.Promotion, uid string, at time.Time) models.InvoiceDiscount {
+	return models.InvoiceDiscount{
+		Discount_id: primitive.NewObjectID().Hex(),
+		Promotion:   promotion,
+		Applied_by:  uid,
+		Applied_at:  at,
+	}
+}
+
+// redeemPromotion counts one use, failing once max_uses is reached even when
+// two tills redeem the last use at the same moment.
+func redeemPromotion(ctx context.Context, promotions store.PromotionStore, promotion models.Promotion) error {
+	err := promotions.Redeem(ctx, promotion.Promotion_id)
+	if err == store.ErrNotFound {
+		return fmt.Errorf("%s has been used up", valueOfString(promotion.Name))
+	}
+	return err
+}
+
+func releasePromotions(ctx context.Context, promotions store.PromotionStore, discounts []models.InvoiceDiscount) {
+	for _, discount := range discounts {
+		if discount.Manual {
+			continue
+		}
+		promotions.Release(ctx, discount.Promotion.Promotion_id)
+	}
+}
+
+func redeemPromotions(ctx context.Context, promotions store.PromotionStore, discounts []models.InvoiceDiscount) error {
+	for i, discount := range discounts {
+		if discount.Manual {
+			continue
+		}
+		if err := redeemPromotion(ctx, promotions, discount.Promotion); err != nil {
+			releasePromotions(ctx, promotions, discounts[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+// priceInvoice freezes the invoice's totals with its discounts applied.
+func priceInvoice(invoice *models.Invoice, summary OrderSummary) helper.OrderTotals {
+	totals := summary.price(valueOf(invoice.Tip), invoice.Discounts)
+	invoice.Subtotal = &totals.Subtotal
+	invoice.Discount = &totals.Discount
+	invoice.Service_charge = &totals.Service_charge
+	invoice.Tax = &totals.Tax
+	invoice.Tip = &totals.Tip
+	invoice.Total = &totals.Total
+	return totals
+}
+
+// discountInvoice re-prices the invoice's discounts against its frozen
+// subtotal. The order items only decide what each discount takes off; items
+// added or voided since the invoice was created do not change the bill, and
+// the tax and service charge scale with the discounted subtotal they were
+// worked out on. Invoices from before totals were frozen are priced afresh.
+func discountInvoice(invoice *models.Invoice, summary OrderSummary) {
+	if invoice.Subtotal == nil {
+		priceInvoice(invoice, summary)
+		return
+	}
+	totals := summary.price(valueOf(invoice.Tip), invoice.Discounts)
+
+	subtotal := *invoice.Subtotal
+	discount := totals.Discount
+	if discount.Amount > subtotal.Amount {
+		discount = subtotal
+	}
+	discounted := subtotal.Sub(discount)
+
+	serviceCharge, tax := valueOf(invoice.Service_charge), valueOf(invoice.Tax)
+	if previous := subtotal.Sub(valueOf(invoice.Discount)); previous.Amount > 0 {
+		serviceCharge = serviceCharge.Prorate(discounted, previous)
+		tax = tax.Prorate(discounted, previous)
+	} else {
+		// a bill discounted to nothing has no rates left to scale, so take the items'
+		current := totals.Subtotal.Sub(totals.Discount)
+		serviceCharge = totals.Service_charge.Prorate(discounted, current)
+		tax = totals.Tax.Prorate(discounted, current)
+	}
+
+	total := discounted.Add(serviceCharge).Add(tax).Add(valueOf(invoice.Tip))
+	invoice.Discount = &discount
+	invoice.Service_charge = &serviceCharge
+	invoice.Tax = &tax
+	invoice.Total = &total
+}
+
+func auditDiscounts(ctx context.Context, audits store.DiscountAuditStore, invoiceId string, action string, by string, discounts []models.InvoiceDiscount) {
+	at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	for _, discount := range discounts {
+		audit := models.DiscountAudit{
+			ID:           primitive.NewObjectID(),
+			Invoice_id:   invoiceId,
+			Discount_id:  discount.Discount_id,
+			Promotion_id: discount.Promotion.Promotion_id,
+			Action:       action,
+			Manual:       discount.Manual,
+			Amount:       discount.Amount,
+			Reason:       discount.Reason,
+			By:           by,
+			At:           at,
+		}
+		audit.Audit_id = audit.ID.Hex()
+		if _, err := audits.Insert(ctx, audit); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":       "discount_audit_error",
+				"time":        time.Now().Format(time.RFC3339),
+				"invoice_id":  invoiceId,
+				"discount_id": discount.Discount_id,
+				"error":       err,
+			}).Error("Error occurred while recording the discount audit")
+		}
+
+		fields := logrus.Fields{
+			"event":       "discount_" + strings.ToLower(action),
+			"time":        time.Now().Format(time.RFC3339),
+			"invoice_id":  invoiceId,
+			"discount_id": discount.Discount_id,
+			"amount":      discount.Amount.String(),
+			"manual":      discount.Manual,
+			"by":          by,
+		}
+		if discount.Manual {
+			fields["reason"] = discount.Reason
+		}
+		appLogger.Log.WithFields(fields).Info("Invoice discount changed")
+	}
+}
+
+func GetPromotions(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		promotions, err := stores.Promotions.List(ctx)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "get_promotions_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while listing promotions")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing promotions"})
+			return
+		}
+
+		if promotions == nil {
+			promotions = []models.Promotion{}
+		}
+		appLogger.Log.WithFields(logrus.Fields{
+			"event": "get_promotions_success",
+			"time":  time.Now().Format(time.RFC3339),
+		}).Info("Successfully retrieved promotions")
+		c.JSON(http.StatusOK, promotions)
+	}
+}
+
+func CreatePromotion(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var promotion models.Promotion
+
+		if err := c.BindJSON(&promotion); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_promotion_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		normalizePromotion(&promotion)
+		validationErr := validate.Struct(promotion)
+		if validationErr == nil {
+			validationErr = promotion.Validate()
+		}
+		if validationErr != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_promotion_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": validationErr,
+			}).Error("Validation error")
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if promotion.Code != nil {
+			taken, err := stores.Promotions.CodeTaken(ctx, *promotion.Code, "")
+			if err != nil || taken {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("code %s is already in use", *promotion.Code)})
+				return
+			}
+		}
+
+		enabled := promotion.IsEnabled()
+		promotion.Enabled = &enabled
+		promotion.Uses = 0
+		promotion.Created_by = c.GetString("uid")
+		promotion.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		promotion.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		promotion.ID = primitive.NewObjectID()
+		promotion.Promotion_id = promotion.ID.Hex()
+
+		result, insertErr := stores.Promotions.Insert(ctx, promotion)
+		if insertErr == store.ErrDuplicate {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("code %s is already in use", *promotion.Code)})
+			return
+		}
+		if insertErr != nil {
+			msg := "promotion was not created"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_promotion_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": insertErr,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":        "create_promotion_success",
+			"time":         time.Now().Format(time.RFC3339),
+			"promotion_id": promotion.Promotion_id,
+		}).Info("Successfully created promotion")
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// UpdatePromotion changes a promotion for invoices from now on; invoices it
+// is already on keep the terms they were given.
+func UpdatePromotion(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		promotionId := c.Param("promotion_id")
+
+		promotion, err := stores.Promotions.Get(ctx, promotionId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "promotion was not found"})
+			return
+		}
+		code := promotion.Code
+
+		if err := c.BindJSON(&promotion); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "update_promotion_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		normalizePromotion(&promotion)
+		validationErr := validate.Struct(promotion)
+		if validationErr == nil {
+			validationErr = promotion.Validate()
+		}
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if promotion.Code != nil && (code == nil || *code != *promotion.Code) {
+			taken, err := stores.Promotions.CodeTaken(ctx, *promotion.Code, promotionId)
+			if err != nil || taken {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("code %s is already in use", *promotion.Code)})
+				return
+			}
+		}
+
+		promotion.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj := bson.M{
+			"name":           promotion.Name,
+			"kind":           promotion.Kind,
+			"code":           promotion.Code,
+			"percentage_off": promotion.Percentage_off,
+			"amount_off":     promotion.Amount_off,
+			"food_ids":       promotion.Food_ids,
+			"categories":     promotion.Categories,
+			"buy_quantity":   promotion.Buy_quantity,
+			"get_quantity":   promotion.Get_quantity,
+			"combo_food_ids": promotion.Combo_food_ids,
+			"combo_price":    promotion.Combo_price,
+			"min_subtotal":   promotion.Min_subtotal,
+			"max_uses":       promotion.Max_uses,
+			"starts_at":      promotion.Starts_at,
+			"expires_at":     promotion.Expires_at,
+			"enabled":        promotion.IsEnabled(),
+			"updated_at":     promotion.Updated_at,
+		}
+
+		// uses is left out so a redemption made while this was edited is kept
+		result, err := stores.Promotions.Update(ctx, promotionId, store.Patch{Set: updateObj})
+		if err == store.ErrDuplicate {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("code %s is already in use", *promotion.Code)})
+			return
+		}
+		if err != nil {
+			msg := "promotion update failed"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":        "update_promotion_error",
+				"time":         time.Now().Format(time.RFC3339),
+				"promotion_id": promotionId,
+				"error":        err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":        "update_promotion_success",
+			"time":         time.Now().Format(time.RFC3339),
+			"promotion_id": promotionId,
+		}).Info("Successfully updated promotion")
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func normalizePromotion(promotion *models.Promotion) {
+	if promotion.Kind != nil {
+		kind := strings.ToUpper(*promotion.Kind)
+		promotion.Kind = &kind
+	}
+	if promotion.Code != nil {
+		code := models.NormalizeCode(*promotion.Code)
+		promotion.Code = &code
+		if code == "" {
+			promotion.Code = nil
+		}
+	}
+}
+
+// ApplyDiscount puts a promotion code or a manual discount on an invoice and
+// re-prices it. Manual discounts need a reason and a manager, owner or
+// cashier; with order_item_ids they come off those items only. Discounts
+// can only change before any payment is taken.
+func ApplyDiscount(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		invoiceId := c.Param("invoice_id")
+		var request DiscountRequest
+
+		if err := c.BindJSON(&request); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "apply_discount_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		invoice, summary, ok := discountableInvoice(ctx, c, stores, invoiceId)
+		if !ok {
+			return
+		}
+
+		uid := c.GetString("uid")
+		appliedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		var discount models.InvoiceDiscount
+
+		if request.Code != nil && *request.Code != "" {
+			var err error
+			discount, err = discountForCode(ctx, stores.Promotions, *request.Code, summary, invoice.Discounts, uid, appliedAt)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		} else {
+			role := c.GetString("role")
+			if role != models.ROLE_OWNER && role != models.ROLE_MANAGER && role != models.ROLE_CASHIER {
+				c.JSON(http.StatusForbidden, gin.H{"error": "only a manager or cashier can give a manual discount"})
+				return
+			}
+			if strings.TrimSpace(request.Reason) == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "a reason is required for a manual discount"})
+				return
+			}
+			if (request.Percentage_off == nil) == (request.Amount_off == nil) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "a manual discount needs either percentage_off or amount_off"})
+				return
+			}
+			if request.Amount_off != nil && request.Amount_off.Amount <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "amount_off must be more than zero"})
+				return
+			}
+			for _, orderItemId := range request.Order_item_ids {
+				if _, err := itemsShare(summary, []string{orderItemId}); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+			}
+
+			name, kind := "Manual discount", models.PROMO_ORDER
+			discount = newDiscount(models.Promotion{
+				Name:           &name,
+				Kind:           &kind,
+				Percentage_off: request.Percentage_off,
+				Amount_off:     request.Amount_off,
+			}, uid, appliedAt)
+			discount.Manual = true
+			discount.Order_item_ids = request.Order_item_ids
+			discount.Reason = strings.TrimSpace(request.Reason)
+		}
+
+		invoice.Discounts = append(invoice.Discounts, discount)
+		discountInvoice(&invoice, summary)
+		discount = invoice.Discounts[len(invoice.Discounts)-1]
+
+		if err := redeemPromotions(ctx, stores.Promotions, []models.InvoiceDiscount{discount}); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if !saveInvoiceDiscounts(ctx, c, stores.Invoices, invoice) {
+			releasePromotions(ctx, stores.Promotions, []models.InvoiceDiscount{discount})
+			return
+		}
+		auditDiscounts(ctx, stores.DiscountAudits, invoiceId, models.DISCOUNT_APPLIED, uid, []models.InvoiceDiscount{discount})
+
+		c.JSON(http.StatusOK, gin.H{
+			"discount":       discount,
+			"discount_total": invoice.Discount,
+			"total":          invoice.Total,
+		})
+	}
+}
+
+// RemoveDiscount takes a discount back off an invoice, e.g. one applied to
+// the wrong table. A promotion's use is given back.
+func RemoveDiscount(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		invoiceId := c.Param("invoice_id")
+		discountId := c.Param("discount_id")
+
+		invoice, summary, ok := discountableInvoice(ctx, c, stores, invoiceId)
+		if !ok {
+			return
+		}
+
+		var removed []models.InvoiceDiscount
+		var kept []models.InvoiceDiscount
+		for _, discount := range invoice.Discounts {
+			if discount.Discount_id == discountId {
+				removed = append(removed, discount)
+			} else {
+				kept = append(kept, discount)
+			}
+		}
+		if len(removed) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "discount is not on this invoice"})
+			return
+		}
+
+		invoice.Discounts = kept
+		discountInvoice(&invoice, summary)
+		if !saveInvoiceDiscounts(ctx, c, stores.Invoices, invoice) {
+			return
+		}
+		releasePromotions(ctx, stores.Promotions, removed)
+		auditDiscounts(ctx, stores.DiscountAudits, invoiceId, models.DISCOUNT_REMOVED, c.GetString("uid"), removed)
+
+		c.JSON(http.StatusOK, gin.H{
+			"discount_total": invoice.Discount,
+			"total":          invoice.Total,
+		})
+	}
+}
+
+// GetDiscountAudit lists every discount applied to or removed from an
+// invoice, oldest first.
+func GetDiscountAudit(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		invoiceId := c.Param("invoice_id")
+
+		audit, err := stores.DiscountAudits.ForInvoice(ctx, invoiceId)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":      "get_discount_audit_error",
+				"time":       time.Now().Format(time.RFC3339),
+				"invoice_id": invoiceId,
+				"error":      err,
+			}).Error("Error occurred while listing the discount audit")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the discount audit"})
+			return
+		}
+
+		if audit == nil {
+			audit = []models.DiscountAudit{}
+		}
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":      "get_discount_audit_success",
+			"time":       time.Now().Format(time.RFC3339),
+			"invoice_id": invoiceId,
+		}).Info("Successfully retrieved the discount audit")
+		c.JSON(http.StatusOK, audit)
+	}
+}
+
+// discountableInvoice loads an invoice that has no payments yet, with its
+// order items as they were billed, answering the request itself when it cannot.
+func discountableInvoice(ctx context.Context, c *gin.Context, stores *store.Store, invoiceId string) (models.Invoice, OrderSummary, bool) {
+	invoice, err := stores.Invoices.Get(ctx, invoiceId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invoice was not found"})
+		return invoice, OrderSummary{}, false
+	}
+	if valueOf(invoice.Amount_paid).Amount > 0 || (invoice.Payment_status != nil && *invoice.Payment_status == models.PAYMENT_PAID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "discounts cannot change once payment has been taken"})
+		return invoice, OrderSummary{}, false
+	}
+
+	summary, err := invoiceSummary(stores, invoice)
+	if err != nil {
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":      "invoice_discount_error",
+			"time":       time.Now().Format(time.RFC3339),
+			"invoice_id": invoiceId,
+			"error":      err,
+		}).Error("Error occurred while pricing the invoice")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while pricing the invoice"})
+		return invoice, OrderSummary{}, false
+	}
+	// discounts are worked out on the items, which must still be the ones billed
+	if invoice.Subtotal != nil && summary.Subtotal.Amount != invoice.Subtotal.Amount {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("the order has changed since it was billed at %s, make a new invoice", *invoice.Subtotal)})
+		return invoice, OrderSummary{}, false
+	}
+	return invoice, summary, true
+}
+
+// saveInvoiceDiscounts writes the new discounts and totals, but only if the
+// invoice is still at the version it was read at and has nothing paid.
+func saveInvoiceDiscounts(ctx context.Context, c *gin.Context, invoices store.InvoiceStore, invoice models.Invoice) bool {
+	invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	patch := store.Patch{If: bson.M{"version": invoiceVersion(invoice), "amount_paid": nil}, Set: bson.M{
+		"discounts":      invoice.Discounts,
+		"discount":       invoice.Discount,
+		"service_charge": invoice.Service_charge,
+		"tax":            invoice.Tax,
+		"total":          invoice.Total,
+		"updated_at":     invoice.Updated_at,
+	}, Inc: bson.M{"version": int64(1)}}
+
+	result, err := invoices.Update(ctx, invoice.Invoice_id, patch)
+	if err != nil {
+		msg := "invoice discount update failed"
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":      "invoice_discount_error",
+			"time":       time.Now().Format(time.RFC3339),
+			"invoice_id": invoice.Invoice_id,
+			"error":      err,
+		}).Error(msg)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return false
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "the invoice changed at the same time, reload and try again"})
+		return false
+	}
+	return true
+}
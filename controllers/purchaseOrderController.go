@@ -0,0 +1,408 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReceivedLine struct {
+	Ingredient_id     string   `json:"ingredient_id" validate:"required"`
+	Received_quantity *float64 `json:"received_quantity" validate:"required,min=0"`
+}
+
+// Lines left out of a receipt are taken as delivered in full; short or
+// damaged deliveries list what actually arrived.
+type ReceivePurchaseOrderRequest struct {
+	Lines []ReceivedLine `json:"lines" validate:"dive"`
+}
+
+func checkPurchaseOrderLines(ctx context.Context, ingredients store.IngredientStore, lines []models.PurchaseOrderLine) error {
+	seen := map[string]bool{}
+	for _, line := range lines {
+		if seen[line.Ingredient_id] {
+			return fmt.Errorf("ingredient %s is listed more than once", line.Ingredient_id)
+		}
+		seen[line.Ingredient_id] = true
+
+		if line.Unit_cost.Amount < 0 {
+			return fmt.Errorf("unit_cost for ingredient %s cannot be negative", line.Ingredient_id)
+		}
+
+		if err := checkIngredient(ctx, ingredients, line.Ingredient_id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// movePurchaseOrder changes the status only if the order is still in one of
+// the statuses it was read in, so two people cannot both receive a delivery.
+func movePurchaseOrder(ctx context.Context, purchaseOrders store.PurchaseOrderStore, purchaseOrderId string, from []string, to string, set bson.M) (models.PurchaseOrder, int, error) {
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	set["status"] = to
+	set["updated_at"] = updatedAt
+
+	purchaseOrder, err := purchaseOrders.Move(ctx, purchaseOrderId, from, set)
+	if err == nil {
+		return purchaseOrder, http.StatusOK, nil
+	}
+	if err != store.ErrNotFound {
+		return purchaseOrder, http.StatusInternalServerError, err
+	}
+
+	purchaseOrder, findErr := purchaseOrders.Get(ctx, purchaseOrderId)
+	if findErr != nil {
+		return purchaseOrder, http.StatusNotFound, fmt.Errorf("purchase order was not found")
+	}
+	return purchaseOrder, http.StatusConflict, fmt.Errorf("purchase order is %s and cannot be moved to %s", purchaseOrder.Status, to)
+}
+
+func GetPurchaseOrders(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		purchaseOrders, err := stores.PurchaseOrders.List(ctx, c.Query("status"), c.Query("supplier_id"))
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "get_purchase_orders_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while listing purchase orders")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing purchase orders"})
+			return
+		}
+
+		if purchaseOrders == nil {
+			purchaseOrders = []models.PurchaseOrder{}
+		}
+		appLogger.Log.WithFields(logrus.Fields{
+			"event": "get_purchase_orders_success",
+			"time":  time.Now().Format(time.RFC3339),
+		}).Info("Successfully retrieved purchase orders")
+		c.JSON(http.StatusOK, purchaseOrders)
+	}
+}
+
+func GetPurchaseOrder(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		purchaseOrderId := c.Param("purchase_order_id")
+
+		purchaseOrder, err := stores.PurchaseOrders.Get(ctx, purchaseOrderId)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":             "get_purchase_order_error",
+				"time":              time.Now().Format(time.RFC3339),
+				"purchase_order_id": purchaseOrderId,
+				"error":             err,
+			}).Error("Error occurred while fetching the purchase order")
+			c.JSON(http.StatusNotFound, gin.H{"error": "purchase order was not found"})
+			return
+		}
+		c.JSON(http.StatusOK, purchaseOrder)
+	}
+}
+
+func CreatePurchaseOrder(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var purchaseOrder models.PurchaseOrder
+
+		if err := c.BindJSON(&purchaseOrder); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_purchase_order_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(purchaseOrder)
+		if validationErr != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_purchase_order_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": validationErr,
+			}).Error("Validation error")
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if _, err := stores.Suppliers.Get(ctx, *purchaseOrder.Supplier_id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "supplier was not found"})
+			return
+		}
+		if err := checkPurchaseOrderLines(ctx, stores.Ingredients, purchaseOrder.Lines); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		for i := range purchaseOrder.Lines {
+			purchaseOrder.Lines[i].Received_quantity = nil
+		}
+		purchaseOrder.Status = models.PO_DRAFT
+		purchaseOrder.ComputeTotal()
+		purchaseOrder.Created_by = c.GetString("uid")
+		purchaseOrder.Sent_at = nil
+		purchaseOrder.Received_at = nil
+		purchaseOrder.Received_by = ""
+		purchaseOrder.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		purchaseOrder.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		purchaseOrder.ID = primitive.NewObjectID()
+		purchaseOrder.Purchase_order_id = purchaseOrder.ID.Hex()
+
+		if _, err := stores.PurchaseOrders.Insert(ctx, purchaseOrder); err != nil {
+			msg := "purchase order was not created"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_purchase_order_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":             "create_purchase_order_success",
+			"time":              time.Now().Format(time.RFC3339),
+			"purchase_order_id": purchaseOrder.Purchase_order_id,
+			"supplier_id":       *purchaseOrder.Supplier_id,
+		}).Info("Successfully created purchase order")
+		c.JSON(http.StatusOK, purchaseOrder)
+	}
+}
+
+func UpdatePurchaseOrder(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var purchaseOrder models.PurchaseOrder
+		purchaseOrderId := c.Param("purchase_order_id")
+
+		if err := c.BindJSON(&purchaseOrder); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "update_purchase_order_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		updateObj := bson.M{}
+		if purchaseOrder.Supplier_id != nil {
+			if _, err := stores.Suppliers.Get(ctx, *purchaseOrder.Supplier_id); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "supplier was not found"})
+				return
+			}
+			updateObj["supplier_id"] = purchaseOrder.Supplier_id
+		}
+		if purchaseOrder.Lines != nil {
+			if err := validate.Var(purchaseOrder.Lines, "min=1,dive"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := checkPurchaseOrderLines(ctx, stores.Ingredients, purchaseOrder.Lines); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			for i := range purchaseOrder.Lines {
+				purchaseOrder.Lines[i].Received_quantity = nil
+			}
+			purchaseOrder.ComputeTotal()
+			updateObj["lines"] = purchaseOrder.Lines
+			updateObj["total"] = purchaseOrder.Total
+		}
+		if purchaseOrder.Notes != nil {
+			updateObj["notes"] = purchaseOrder.Notes
+		}
+
+		// only drafts can be edited; the supplier already has the sent version
+		updated, status, err := movePurchaseOrder(ctx, stores.PurchaseOrders, purchaseOrderId, []string{models.PO_DRAFT}, models.PO_DRAFT, updateObj)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":             "update_purchase_order_error",
+				"time":              time.Now().Format(time.RFC3339),
+				"purchase_order_id": purchaseOrderId,
+				"error":             err,
+			}).Error("Purchase order update failed")
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":             "update_purchase_order_success",
+			"time":              time.Now().Format(time.RFC3339),
+			"purchase_order_id": purchaseOrderId,
+		}).Info("Successfully updated purchase order")
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+func SendPurchaseOrder(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		purchaseOrderId := c.Param("purchase_order_id")
+		sentAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		purchaseOrder, status, err := movePurchaseOrder(ctx, stores.PurchaseOrders, purchaseOrderId, []string{models.PO_DRAFT}, models.PO_SENT, bson.M{"sent_at": sentAt})
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":             "send_purchase_order_success",
+			"time":              time.Now().Format(time.RFC3339),
+			"purchase_order_id": purchaseOrderId,
+			"supplier_id":       *purchaseOrder.Supplier_id,
+			"total":             purchaseOrder.Total.String(),
+			"sent_by":           c.GetString("uid"),
+		}).Info("Purchase order sent to supplier")
+		c.JSON(http.StatusOK, purchaseOrder)
+	}
+}
+
+func CancelPurchaseOrder(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		purchaseOrderId := c.Param("purchase_order_id")
+
+		purchaseOrder, status, err := movePurchaseOrder(ctx, stores.PurchaseOrders, purchaseOrderId, []string{models.PO_DRAFT, models.PO_SENT}, models.PO_CANCELLED, bson.M{})
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":             "cancel_purchase_order_success",
+			"time":              time.Now().Format(time.RFC3339),
+			"purchase_order_id": purchaseOrderId,
+			"cancelled_by":      c.GetString("uid"),
+		}).Info("Purchase order cancelled")
+		c.JSON(http.StatusOK, purchaseOrder)
+	}
+}
+
+// ReceivePurchaseOrder books the delivery into stock and records what each
+// ingredient cost, which recipe costing reads later.
+func ReceivePurchaseOrder(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request ReceivePurchaseOrderRequest
+		purchaseOrderId := c.Param("purchase_order_id")
+		uid := c.GetString("uid")
+
+		if err := c.ShouldBindJSON(&request); err != nil && c.Request.ContentLength > 0 {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "receive_purchase_order_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		purchaseOrder, err := stores.PurchaseOrders.Get(ctx, purchaseOrderId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "purchase order was not found"})
+			return
+		}
+
+		received := map[string]float64{}
+		for _, line := range purchaseOrder.Lines {
+			received[line.Ingredient_id] = line.Quantity
+		}
+		for _, line := range request.Lines {
+			if _, ok := received[line.Ingredient_id]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("ingredient %s is not on this purchase order", line.Ingredient_id)})
+				return
+			}
+			received[line.Ingredient_id] = *line.Received_quantity
+		}
+
+		lines := purchaseOrder.Lines
+		for i := range lines {
+			quantity := received[lines[i].Ingredient_id]
+			lines[i].Received_quantity = &quantity
+		}
+
+		receivedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		purchaseOrder, status, err := movePurchaseOrder(ctx, stores.PurchaseOrders, purchaseOrderId, []string{models.PO_SENT}, models.PO_RECEIVED, bson.M{
+			"lines":       lines,
+			"received_at": receivedAt,
+			"received_by": uid,
+		})
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		var movements []models.StockMovement
+		costs := map[string]models.Money{}
+		for _, line := range purchaseOrder.Lines {
+			if *line.Received_quantity > 0 {
+				movements = append(movements, models.StockMovement{
+					Ingredient_id: line.Ingredient_id,
+					Change:        *line.Received_quantity,
+					Reason:        models.STOCK_RECEIPT,
+					Note:          "purchase order " + purchaseOrderId,
+					Created_by:    uid,
+				})
+			}
+			costs[line.Ingredient_id] = *line.Unit_cost
+		}
+
+		err = recordStockMovements(ctx, stores, movements)
+		if err == nil {
+			err = stores.Ingredients.SetUnitCosts(ctx, costs)
+		}
+		if err != nil {
+			msg := "purchase order was marked received but stock could not be updated"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":             "receive_purchase_order_error",
+				"time":              time.Now().Format(time.RFC3339),
+				"purchase_order_id": purchaseOrderId,
+				"error":             err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":             "receive_purchase_order_success",
+			"time":              time.Now().Format(time.RFC3339),
+			"purchase_order_id": purchaseOrderId,
+			"supplier_id":       *purchaseOrder.Supplier_id,
+			"received_by":       uid,
+		}).Info("Purchase order received into stock")
+		c.JSON(http.StatusOK, purchaseOrder)
+	}
+}
@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"context"
+	helper "golang-restaurant-management/helpers"
+	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type FoodMargin struct {
+	Food_id                 string       `json:"food_id"`
+	Name                    string       `json:"name"`
+	Price                   models.Money `json:"price"`
+	Plate_cost              models.Money `json:"plate_cost"`
+	Gross_margin            models.Money `json:"gross_margin"`
+	Gross_margin_percentage float64      `json:"gross_margin_percentage"`
+	Food_cost_percentage    float64      `json:"food_cost_percentage"`
+	Below_target            bool         `json:"below_target"`
+	Has_recipe              bool         `json:"has_recipe"`
+	Missing_costs           []string     `json:"missing_costs"`
+}
+
+type MenuMargin struct {
+	Menu_id                 string       `json:"menu_id"`
+	Name                    string       `json:"name"`
+	Category                string       `json:"category"`
+	Foods                   []FoodMargin `json:"foods"`
+	Total_price             models.Money `json:"total_price"`
+	Total_plate_cost        models.Money `json:"total_plate_cost"`
+	Gross_margin_percentage float64      `json:"gross_margin_percentage"`
+	Food_cost_percentage    float64      `json:"food_cost_percentage"`
+	Below_target            int          `json:"below_target"`
+}
+
+type UnpricedFood struct {
+	Food_id string `json:"food_id"`
+	Name    string `json:"name"`
+	Menu_id string `json:"menu_id"`
+}
+
+type MarginReport struct {
+	Target_margin_percentage float64        `json:"target_margin_percentage"`
+	Menus                    []MenuMargin   `json:"menus"`
+	Below_target             []FoodMargin   `json:"below_target"`
+	Unpriced                 []UnpricedFood `json:"unpriced"`
+}
+
+// GetMarginReport costs every food's recipe at the ingredients' last purchase
+// price. Menu totals treat the menu as one plate of each dish, so they show
+// the mix as priced rather than as sold. Foods without a recipe are listed
+// but never flagged, since there is nothing to cost them against. Foods
+// without a price have no margin at all and are listed under unpriced.
+func GetMarginReport(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		targetMargin := helper.Pricing.Target_margin
+		if raw := c.Query("target_margin"); raw != "" {
+			parsed, err := strconv.ParseFloat(raw, 64)
+			if err != nil || parsed < 0 || parsed >= 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "target_margin must be a fraction such as 0.7"})
+				return
+			}
+			targetMargin = parsed
+		}
+
+		var menuIds []string
+		if menuId := c.Query("menu_id"); menuId != "" {
+			menuIds = append(menuIds, menuId)
+		}
+
+		var foods []models.Food
+		var ingredients []models.Ingredient
+
+		menus, err := stores.Menus.Find(ctx, menuIds...)
+		if err == nil {
+			foods, err = stores.Foods.ForMenus(ctx, menuIds...)
+		}
+		if err == nil {
+			ingredients, err = stores.Ingredients.Costed(ctx)
+		}
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "get_margin_report_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while building the margin report")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while building the margin report"})
+			return
+		}
+
+		unitCosts := map[string]models.Money{}
+		for _, ingredient := range ingredients {
+			unitCosts[ingredient.Ingredient_id] = *ingredient.Unit_cost
+		}
+
+		report := MarginReport{
+			Target_margin_percentage: targetMargin * 100,
+			Menus:                    []MenuMargin{},
+			Below_target:             []FoodMargin{},
+			Unpriced:                 []UnpricedFood{},
+		}
+		menuIndex := map[string]int{}
+		for _, menu := range menus {
+			menuIndex[menu.Menu_id] = len(report.Menus)
+			report.Menus = append(report.Menus, MenuMargin{
+				Menu_id:          menu.Menu_id,
+				Name:             menu.Name,
+				Category:         menu.Category,
+				Foods:            []FoodMargin{},
+				Total_price:      models.NewMoney(0),
+				Total_plate_cost: models.NewMoney(0),
+			})
+		}
+
+		for _, food := range foods {
+			if food.Price == nil {
+				report.Unpriced = append(report.Unpriced, UnpricedFood{
+					Food_id: food.Food_id,
+					Name:    valueOfString(food.Name),
+					Menu_id: valueOfString(food.Menu_id),
+				})
+				continue
+			}
+			costing := helper.CostPlate(*food.Price, food.Recipe, unitCosts)
+			margin := FoodMargin{
+				Food_id:                 food.Food_id,
+				Name:                    valueOfString(food.Name),
+				Price:                   costing.Price,
+				Plate_cost:              costing.Plate_cost,
+				Gross_margin:            costing.Gross_margin,
+				Gross_margin_percentage: costing.Gross_margin_percentage,
+				Food_cost_percentage:    costing.Food_cost_percentage,
+				Has_recipe:              len(food.Recipe) > 0,
+				Missing_costs:           costing.Missing_costs,
+			}
+			if margin.Missing_costs == nil {
+				margin.Missing_costs = []string{}
+			}
+			margin.Below_target = margin.Has_recipe && margin.Gross_margin_percentage < report.Target_margin_percentage
+			if margin.Below_target {
+				report.Below_target = append(report.Below_target, margin)
+			}
+
+			index, ok := menuIndex[valueOfString(food.Menu_id)]
+			if !ok {
+				continue
+			}
+			menu := &report.Menus[index]
+			menu.Foods = append(menu.Foods, margin)
+			if margin.Has_recipe {
+				menu.Total_price = menu.Total_price.Add(margin.Price)
+				menu.Total_plate_cost = menu.Total_plate_cost.Add(margin.Plate_cost)
+			}
+			if margin.Below_target {
+				menu.Below_target++
+			}
+		}
+
+		for i := range report.Menus {
+			menu := &report.Menus[i]
+			menu.Gross_margin_percentage = helper.Percentage(menu.Total_price.Sub(menu.Total_plate_cost), menu.Total_price)
+			menu.Food_cost_percentage = helper.Percentage(menu.Total_plate_cost, menu.Total_price)
+			sort.Slice(menu.Foods, func(a, b int) bool {
+				return menu.Foods[a].Gross_margin_percentage < menu.Foods[b].Gross_margin_percentage
+			})
+		}
+		sort.Slice(report.Below_target, func(a, b int) bool {
+			return report.Below_target[a].Gross_margin_percentage < report.Below_target[b].Gross_margin_percentage
+		})
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":        "get_margin_report_success",
+			"time":         time.Now().Format(time.RFC3339),
+			"below_target": len(report.Below_target),
+			"unpriced":     len(report.Unpriced),
+		}).Info("Successfully built the margin report")
+		c.JSON(http.StatusOK, report)
+	}
+}
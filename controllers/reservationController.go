@@ -0,0 +1,428 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	errSlotTaken = errors.New("the table is already booked for that time")
+	errTableBusy = errors.New("the table is being booked by someone else, try again")
+)
+
+// availableTables returns the tables big enough for the party that have no
+// booking overlapping [start, end), smallest tables first.
+func availableTables(ctx context.Context, stores *store.Store, partySize int, start time.Time, end time.Time, excludeId string) ([]models.Table, error) {
+	tables, err := stores.Tables.Seating(ctx, partySize)
+	if err != nil {
+		return nil, err
+	}
+
+	booked, err := stores.Reservations.BookedTables(ctx, start, end, excludeId)
+	if err != nil {
+		return nil, err
+	}
+
+	taken := map[string]bool{}
+	for _, tableId := range booked {
+		taken[tableId] = true
+	}
+
+	available := []models.Table{}
+	for _, table := range tables {
+		if !taken[table.Table_id] {
+			available = append(available, table)
+		}
+	}
+	return available, nil
+}
+
+// bookTable runs write while holding the reservation's table, so the check
+// for an overlapping booking and the write cannot interleave with another
+// host booking, rebooking or seating the same table.
+func bookTable(ctx context.Context, stores *store.Store, reservation models.Reservation, write func() error) error {
+	release, err := holdTable(ctx, stores.Tables, *reservation.Table_id)
+	if err == store.ErrNotFound {
+		return errTableBusy
+	}
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	clash, err := stores.Reservations.Clashes(ctx, reservation)
+	if err != nil {
+		return err
+	}
+	if clash {
+		return errSlotTaken
+	}
+	return write()
+}
+
+func reservationWindow(reservation *models.Reservation) {
+	if reservation.Duration_minutes == nil {
+		duration := models.DEFAULT_RESERVATION_MINUTES
+		reservation.Duration_minutes = &duration
+	}
+	reservation.End_time = reservation.Start_time.Add(time.Duration(*reservation.Duration_minutes) * time.Minute)
+}
+
+func GetAvailability(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		partySize, err := strconv.Atoi(c.Query("party_size"))
+		if err != nil || partySize < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "party_size must be a positive number"})
+			return
+		}
+
+		start, err := time.Parse(time.RFC3339, c.Query("start_time"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_time must be an RFC3339 time"})
+			return
+		}
+
+		duration, err := strconv.Atoi(c.DefaultQuery("duration_minutes", strconv.Itoa(models.DEFAULT_RESERVATION_MINUTES)))
+		if err != nil || duration < 15 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "duration_minutes must be at least 15"})
+			return
+		}
+
+		tables, err := availableTables(ctx, stores, partySize, start, start.Add(time.Duration(duration)*time.Minute), "")
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "get_availability_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while searching for available tables")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while searching for available tables"})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":      "get_availability_success",
+			"time":       time.Now().Format(time.RFC3339),
+			"party_size": partySize,
+			"start_time": start.Format(time.RFC3339),
+		}).Info("Successfully searched for available tables")
+		c.JSON(http.StatusOK, tables)
+	}
+}
+
+func GetReservations(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := store.ReservationFilter{Status: c.Query("status"), Table_id: c.Query("table_id")}
+		if date := c.Query("date"); date != "" {
+			day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "date must look like 2006-01-02"})
+				return
+			}
+			filter.From = day
+			filter.To = day.AddDate(0, 0, 1)
+		}
+
+		allReservations, err := stores.Reservations.List(ctx, filter)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "get_reservations_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while listing reservations")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing reservations"})
+			return
+		}
+		if allReservations == nil {
+			allReservations = []models.Reservation{}
+		}
+		appLogger.Log.WithFields(logrus.Fields{
+			"event": "get_reservations_success",
+			"time":  time.Now().Format(time.RFC3339),
+		}).Info("Successfully retrieved reservations")
+		c.JSON(http.StatusOK, allReservations)
+	}
+}
+
+func GetReservation(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		reservationId := c.Param("reservation_id")
+
+		reservation, err := stores.Reservations.Get(ctx, reservationId)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":          "get_reservation_error",
+				"time":           time.Now().Format(time.RFC3339),
+				"reservation_id": reservationId,
+				"error":          err,
+			}).Error("Error occurred while fetching the reservation")
+			c.JSON(http.StatusNotFound, gin.H{"error": "reservation was not found"})
+			return
+		}
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":          "get_reservation_success",
+			"time":           time.Now().Format(time.RFC3339),
+			"reservation_id": reservationId,
+		}).Info("Successfully retrieved reservation")
+		c.JSON(http.StatusOK, reservation)
+	}
+}
+
+func CreateReservation(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var reservation models.Reservation
+
+		if err := c.BindJSON(&reservation); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_reservation_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(reservation)
+		if validationErr != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_reservation_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": validationErr,
+			}).Error("Validation error")
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if reservation.Start_time.Before(time.Now().Add(-15 * time.Minute)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_time is in the past"})
+			return
+		}
+		reservationWindow(&reservation)
+
+		candidates, err := availableTables(ctx, stores, *reservation.Party_size, *reservation.Start_time, reservation.End_time, "")
+		if err != nil {
+			msg := "error occurred while searching for available tables"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_reservation_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		if reservation.Table_id != nil {
+			var requested []models.Table
+			for _, table := range candidates {
+				if table.Table_id == *reservation.Table_id {
+					requested = append(requested, table)
+				}
+			}
+			candidates = requested
+		}
+
+		if len(candidates) == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "no table that fits the party is free at that time"})
+			return
+		}
+
+		status := models.RESERVATION_BOOKED
+		reservation.Status = &status
+		reservation.Created_by = c.GetString("uid")
+		reservation.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		reservation.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		// if another host grabs a table between the search and the insert, move on to the next one
+		for _, table := range candidates {
+			tableId := table.Table_id
+			reservation.Table_id = &tableId
+			reservation.ID = primitive.NewObjectID()
+			reservation.Reservation_id = reservation.ID.Hex()
+
+			err := bookTable(ctx, stores, reservation, func() error {
+				_, err := stores.Reservations.Insert(ctx, reservation)
+				return err
+			})
+			if err == nil {
+				appLogger.Log.WithFields(logrus.Fields{
+					"event":          "create_reservation_success",
+					"time":           time.Now().Format(time.RFC3339),
+					"reservation_id": reservation.Reservation_id,
+					"table_id":       tableId,
+				}).Info("Successfully created reservation")
+				c.JSON(http.StatusOK, reservation)
+				return
+			}
+
+			if err != errSlotTaken && err != errTableBusy {
+				msg := "reservation was not created"
+				appLogger.Log.WithFields(logrus.Fields{
+					"event": "create_reservation_error",
+					"time":  time.Now().Format(time.RFC3339),
+					"error": err,
+				}).Error(msg)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+				return
+			}
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":    "create_reservation_conflict",
+				"time":     time.Now().Format(time.RFC3339),
+				"table_id": tableId,
+				"error":    err,
+			}).Warn("Table was booked concurrently, trying the next one")
+		}
+
+		c.JSON(http.StatusConflict, gin.H{"error": errSlotTaken.Error()})
+	}
+}
+
+func UpdateReservation(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var changes models.Reservation
+		reservationId := c.Param("reservation_id")
+
+		if err := c.BindJSON(&changes); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "update_reservation_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		existing, err := stores.Reservations.Get(ctx, reservationId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "reservation was not found"})
+			return
+		}
+
+		updated := existing
+		if changes.Party_size != nil {
+			updated.Party_size = changes.Party_size
+		}
+		if changes.Start_time != nil {
+			updated.Start_time = changes.Start_time
+		}
+		if changes.Duration_minutes != nil {
+			updated.Duration_minutes = changes.Duration_minutes
+		}
+		if changes.Table_id != nil {
+			updated.Table_id = changes.Table_id
+		}
+		if changes.Customer_name != nil {
+			updated.Customer_name = changes.Customer_name
+		}
+		if changes.Customer_phone != nil {
+			updated.Customer_phone = changes.Customer_phone
+		}
+		if changes.Customer_email != nil {
+			updated.Customer_email = changes.Customer_email
+		}
+		if changes.Notes != nil {
+			updated.Notes = changes.Notes
+		}
+		if changes.Status != nil {
+			updated.Status = changes.Status
+		}
+		reservationWindow(&updated)
+
+		validationErr := validate.Struct(updated)
+		if validationErr != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "update_reservation_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": validationErr,
+			}).Error("Validation error")
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		reopened := changes.Status != nil && *changes.Status == models.RESERVATION_BOOKED && (existing.Status == nil || *existing.Status != models.RESERVATION_BOOKED)
+		rebooked := reopened || changes.Party_size != nil || changes.Start_time != nil || changes.Duration_minutes != nil || changes.Table_id != nil
+		if rebooked {
+			tables, err := availableTables(ctx, stores, *updated.Party_size, *updated.Start_time, updated.End_time, reservationId)
+			if err != nil {
+				msg := "error occurred while searching for available tables"
+				appLogger.Log.WithFields(logrus.Fields{
+					"event":          "update_reservation_error",
+					"time":           time.Now().Format(time.RFC3339),
+					"reservation_id": reservationId,
+					"error":          err,
+				}).Error(msg)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+				return
+			}
+
+			fits := false
+			for _, table := range tables {
+				if updated.Table_id != nil && table.Table_id == *updated.Table_id {
+					fits = true
+				}
+			}
+			if !fits {
+				c.JSON(http.StatusConflict, gin.H{"error": "the table does not fit the party or is booked at that time"})
+				return
+			}
+		}
+
+		updated.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		var result store.UpdateResult
+		replace := func() error {
+			result, err = stores.Reservations.Replace(ctx, updated)
+			return err
+		}
+		if rebooked {
+			err = bookTable(ctx, stores, updated, replace)
+		} else {
+			err = replace()
+		}
+		if err == errSlotTaken || err == errTableBusy {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			msg := "reservation update failed"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":          "update_reservation_error",
+				"time":           time.Now().Format(time.RFC3339),
+				"reservation_id": reservationId,
+				"error":          err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":          "update_reservation_success",
+			"time":           time.Now().Format(time.RFC3339),
+			"reservation_id": reservationId,
+		}).Info("Successfully updated reservation")
+		c.JSON(http.StatusOK, result)
+	}
+}
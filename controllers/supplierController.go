@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"context"
+	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func GetSuppliers(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		suppliers, err := stores.Suppliers.List(ctx)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "get_suppliers_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while listing suppliers")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing suppliers"})
+			return
+		}
+
+		if suppliers == nil {
+			suppliers = []models.Supplier{}
+		}
+		appLogger.Log.WithFields(logrus.Fields{
+			"event": "get_suppliers_success",
+			"time":  time.Now().Format(time.RFC3339),
+		}).Info("Successfully retrieved suppliers")
+		c.JSON(http.StatusOK, suppliers)
+	}
+}
+
+func GetSupplier(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		supplierId := c.Param("supplier_id")
+
+		supplier, err := stores.Suppliers.Get(ctx, supplierId)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":       "get_supplier_error",
+				"time":        time.Now().Format(time.RFC3339),
+				"supplier_id": supplierId,
+				"error":       err,
+			}).Error("Error occurred while fetching the supplier")
+			c.JSON(http.StatusNotFound, gin.H{"error": "supplier was not found"})
+			return
+		}
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":       "get_supplier_success",
+			"time":        time.Now().Format(time.RFC3339),
+			"supplier_id": supplierId,
+		}).Info("Successfully retrieved supplier")
+		c.JSON(http.StatusOK, supplier)
+	}
+}
+
+func CreateSupplier(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var supplier models.Supplier
+
+		if err := c.BindJSON(&supplier); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_supplier_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(supplier)
+		if validationErr != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_supplier_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": validationErr,
+			}).Error("Validation error")
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		supplier.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		supplier.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		supplier.ID = primitive.NewObjectID()
+		supplier.Supplier_id = supplier.ID.Hex()
+
+		result, insertErr := stores.Suppliers.Insert(ctx, supplier)
+		if insertErr != nil {
+			msg := "supplier was not created"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_supplier_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": insertErr,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":       "create_supplier_success",
+			"time":        time.Now().Format(time.RFC3339),
+			"supplier_id": supplier.Supplier_id,
+		}).Info("Successfully created supplier")
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdateSupplier(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var supplier models.Supplier
+		supplierId := c.Param("supplier_id")
+
+		if err := c.BindJSON(&supplier); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "update_supplier_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validate.StructPartial(supplier, "Email"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		updateObj := bson.M{}
+		if supplier.Name != nil {
+			updateObj["name"] = supplier.Name
+		}
+		if supplier.Contact_name != nil {
+			updateObj["contact_name"] = supplier.Contact_name
+		}
+		if supplier.Email != nil {
+			updateObj["email"] = supplier.Email
+		}
+		if supplier.Phone != nil {
+			updateObj["phone"] = supplier.Phone
+		}
+		supplier.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj["updated_at"] = supplier.Updated_at
+
+		result, err := stores.Suppliers.Update(ctx, supplierId, store.Patch{Set: updateObj})
+		if err != nil {
+			msg := "supplier update failed"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":       "update_supplier_error",
+				"time":        time.Now().Format(time.RFC3339),
+				"supplier_id": supplierId,
+				"error":       err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "supplier was not found"})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":       "update_supplier_success",
+			"time":        time.Now().Format(time.RFC3339),
+			"supplier_id": supplierId,
+		}).Info("Successfully updated supplier")
+		c.JSON(http.StatusOK, result)
+	}
+}
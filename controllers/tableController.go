@@ -2,9 +2,10 @@ package controller
 
 import (
 	"context"
-	"golang-restaurant-management/database"
+	"fmt"
 	appLogger "golang-restaurant-management/logger"
 	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
 	"net/http"
 	"time"
 
@@ -12,38 +13,38 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var tableCollection *mongo.Collection = database.OpenCollection(database.Client, "table")
-
-func GetTables() gin.HandlerFunc {
+func GetTables(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		result, err := tableCollection.Find(context.TODO(), bson.M{})
+		allTables, err := stores.Tables.List(ctx)
 		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event": "get_tables_error",
 				"time":  time.Now().Format(time.RFC3339),
 				"error": err,
-			}).Error("Error occurred while listing table items")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing table items"})
+			}).Error("Error occurred while retrieving table items")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while retrieving table items"})
 			return
 		}
 
-		var allTables []bson.M
-		if err = result.All(ctx, &allTables); err != nil {
+		states, err := tableStates(ctx, stores)
+		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event": "get_tables_error",
 				"time":  time.Now().Format(time.RFC3339),
 				"error": err,
-			}).Error("Error occurred while retrieving table items")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while retrieving table items"})
+			}).Error("Error occurred while working out table status")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while working out table status"})
 			return
 		}
+		for i := range allTables {
+			allTables[i].Status = states[allTables[i].Table_id].statusOf(allTables[i])
+		}
+
 		appLogger.Log.WithFields(logrus.Fields{
 			"event": "get_tables_success",
 			"time":  time.Now().Format(time.RFC3339),
@@ -52,15 +53,14 @@ func GetTables() gin.HandlerFunc {
 	}
 }
 
-func GetTable() gin.HandlerFunc {
+func GetTable(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		tableId := c.Param("table_id")
-		var table models.Table
 
-		err := tableCollection.FindOne(ctx, bson.M{"table_id": tableId}).Decode(&table)
+		table, err := stores.Tables.Get(ctx, tableId)
 		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event":   "get_table_error",
@@ -71,6 +71,20 @@ func GetTable() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while fetching the table"})
 			return
 		}
+
+		states, err := tableStates(ctx, stores)
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":    "get_table_error",
+				"time":     time.Now().Format(time.RFC3339),
+				"table_id": tableId,
+				"error":    err,
+			}).Error("Error occurred while working out table status")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while working out table status"})
+			return
+		}
+		table.Status = states[tableId].statusOf(table)
+
 		appLogger.Log.WithFields(logrus.Fields{
 			"event":   "get_table_success",
 			"time":    time.Now().Format(time.RFC3339),
@@ -80,7 +94,7 @@ func GetTable() gin.HandlerFunc {
 	}
 }
 
-func CreateTable() gin.HandlerFunc {
+func CreateTable(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -108,13 +122,20 @@ func CreateTable() gin.HandlerFunc {
 			return
 		}
 
+		if table.Server_id != nil {
+			if err := checkServer(ctx, stores.Users, *table.Server_id); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
 		table.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		table.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 
 		table.ID = primitive.NewObjectID()
 		table.Table_id = table.ID.Hex()
 
-		result, insertErr := tableCollection.InsertOne(ctx, table)
+		result, insertErr := stores.Tables.Insert(ctx, table)
 		if insertErr != nil {
 			msg := "Table item was not created"
 			appLogger.Log.WithFields(logrus.Fields{
@@ -134,7 +155,7 @@ func CreateTable() gin.HandlerFunc {
 	}
 }
 
-func UpdateTable() gin.HandlerFunc {
+func UpdateTable(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -152,34 +173,51 @@ func UpdateTable() gin.HandlerFunc {
 			return
 		}
 
-		var updateObj primitive.D
+		updateObj := bson.M{}
 
 		if table.Number_of_guests != nil {
-			updateObj = append(updateObj, bson.E{"number_of_guests", table.Number_of_guests})
+			updateObj["number_of_guests"] = table.Number_of_guests
 		}
 
 		if table.Table_number != nil {
-			updateObj = append(updateObj, bson.E{"table_number", table.Table_number})
+			updateObj["table_number"] = table.Table_number
 		}
 
-		table.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{"updated_at", table.Updated_at})
+		if table.Section != nil {
+			updateObj["section"] = table.Section
+		}
+
+		if table.Position_x != nil {
+			updateObj["position_x"] = table.Position_x
+		}
+
+		if table.Position_y != nil {
+			updateObj["position_y"] = table.Position_y
+		}
 
-		upsert := true
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		if table.Server_id != nil {
+			// an empty server_id takes the table off everyone's section
+			if *table.Server_id != "" {
+				if err := checkServer(ctx, stores.Users, *table.Server_id); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+			}
+			updateObj["server_id"] = table.Server_id
 		}
 
-		filter := bson.M{"table_id": tableId}
+		if table.Needs_cleaning != nil {
+			updateObj["needs_cleaning"] = table.Needs_cleaning
+		}
 
-		result, err := tableCollection.UpdateOne(
-			ctx,
-			filter,
-			bson.D{
-				{"$set", updateObj},
-			},
-			&opt,
-		)
+		table.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj["updated_at"] = table.Updated_at
+
+		result, err := stores.Tables.Update(ctx, tableId, store.Patch{Set: updateObj})
+		if err == nil && result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "table was not found"})
+			return
+		}
 		if err != nil {
 			msg := "Table item update failed"
 			appLogger.Log.WithFields(logrus.Fields{
@@ -199,3 +237,73 @@ func UpdateTable() gin.HandlerFunc {
 		c.JSON(http.StatusOK, result)
 	}
 }
+
+// MarkTableClean clears the cleaning flag closing an order sets, so the
+// floor can hand the table back out without a manager editing it.
+func MarkTableClean(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		tableId := c.Param("table_id")
+
+		cleanedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := stores.Tables.Update(ctx, tableId, store.Patch{
+			If:  bson.M{"needs_cleaning": true},
+			Set: bson.M{"needs_cleaning": false, "updated_at": cleanedAt},
+		})
+		if err == nil && result.MatchedCount == 0 {
+			// a table that was already clean is fine, a missing one is not
+			_, err = stores.Tables.Get(ctx, tableId)
+			if err == store.ErrNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "table was not found"})
+				return
+			}
+		}
+		if err != nil {
+			msg := "table could not be marked clean"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":    "mark_table_clean_error",
+				"time":     time.Now().Format(time.RFC3339),
+				"table_id": tableId,
+				"error":    err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":      "mark_table_clean_success",
+			"time":       time.Now().Format(time.RFC3339),
+			"table_id":   tableId,
+			"cleaned_by": c.GetString("uid"),
+		}).Info("Table marked clean")
+		c.JSON(http.StatusOK, gin.H{"table_id": tableId, "needs_cleaning": false})
+	}
+}
+
+// checkServer makes sure tables are only assigned to staff who wait tables.
+func checkServer(ctx context.Context, users store.UserStore, serverId string) error {
+	server, err := users.Get(ctx, serverId)
+	if err != nil {
+		return fmt.Errorf("server %s was not found", serverId)
+	}
+	if server.Role == nil || (*server.Role != models.ROLE_WAITER && *server.Role != models.ROLE_MANAGER && *server.Role != models.ROLE_OWNER) {
+		return fmt.Errorf("user %s does not wait tables", serverId)
+	}
+	return nil
+}
+
+// a table claim left by a request that died frees the table after this
+const tableClaimMinutes = 1
+
+// holdTable claims the table so only this request seats or books it until
+// release is called. It returns store.ErrNotFound while someone else holds it.
+func holdTable(ctx context.Context, tables store.TableStore, tableId string) (release func(), err error) {
+	claimId := primitive.NewObjectID().Hex()
+	now := time.Now()
+	if err := tables.Claim(ctx, tableId, claimId, now, now.Add(-tableClaimMinutes*time.Minute)); err != nil {
+		return nil, err
+	}
+	return func() { tables.Release(ctx, tableId, claimId) }, nil
+}
@@ -2,10 +2,10 @@ package controller
 
 import (
 	"context"
-	"golang-restaurant-management/database"
 	helper "golang-restaurant-management/helpers"
 	appLogger "golang-restaurant-management/logger"
 	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
 	"net/http"
 	"strconv"
 	"time"
@@ -14,13 +14,10 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
-
-func GetUsers() gin.HandlerFunc {
+func GetUsers(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -38,28 +35,8 @@ func GetUsers() gin.HandlerFunc {
 		startIndex := (page - 1) * recordPerPage
 		startIndex, err = strconv.Atoi(c.Query("startIndex"))
 
-		matchStage := bson.D{{"$match", bson.D{{}}}}
-		projectStage := bson.D{
-			{"$project", bson.D{
-				{"_id", 0},
-				{"total_count", 1},
-				{"user_items", bson.D{{"$slice", []interface{}{"$data", startIndex, recordPerPage}}}},
-			}}}
-
-		result, err := userCollection.Aggregate(ctx, mongo.Pipeline{
-			matchStage, projectStage})
+		allUsers, err := stores.Users.Page(ctx, startIndex, recordPerPage)
 		if err != nil {
-			appLogger.Log.WithFields(logrus.Fields{
-				"event": "get_users_error",
-				"time":  time.Now().Format(time.RFC3339),
-				"error": err,
-			}).Error("Error occurred while listing user items")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing user items"})
-			return
-		}
-
-		var allUsers []bson.M
-		if err = result.All(ctx, &allUsers); err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event": "get_users_error",
 				"time":  time.Now().Format(time.RFC3339),
@@ -72,19 +49,21 @@ func GetUsers() gin.HandlerFunc {
 			"event": "get_users_success",
 			"time":  time.Now().Format(time.RFC3339),
 		}).Info("Successfully retrieved user items")
-		c.JSON(http.StatusOK, allUsers[0])
+		c.JSON(http.StatusOK, allUsers)
 	}
 }
 
-func GetUser() gin.HandlerFunc {
+func GetUser(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		userId := c.Param("user_id")
-		var user models.User
-
-		err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+		user, err := stores.Users.Get(ctx, userId)
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user was not found"})
+			return
+		}
 		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event":   "get_user_error",
@@ -100,11 +79,13 @@ func GetUser() gin.HandlerFunc {
 			"time":    time.Now().Format(time.RFC3339),
 			"user_id": userId,
 		}).Info("Successfully retrieved user")
+		// a manager reading the owner must not get the owner's session
+		user.Password, user.Token, user.Refresh_Token = nil, nil, nil
 		c.JSON(http.StatusOK, user)
 	}
 }
 
-func SignUp() gin.HandlerFunc {
+func SignUp(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -132,7 +113,7 @@ func SignUp() gin.HandlerFunc {
 			return
 		}
 
-		count, err := userCollection.CountDocuments(ctx, bson.M{"email": user.Email})
+		count, err := stores.Users.Count(ctx, "email", user.Email)
 		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event": "sign_up_error",
@@ -142,11 +123,19 @@ func SignUp() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking for the email"})
 			return
 		}
+		if count > 0 {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "sign_up_error",
+				"time":  time.Now().Format(time.RFC3339),
+			}).Error("This email already exists")
+			c.JSON(http.StatusConflict, gin.H{"error": "this email already exists"})
+			return
+		}
 
 		password := HashPassword(*user.Password)
 		user.Password = &password
 
-		count, err = userCollection.CountDocuments(ctx, bson.M{"phone": user.Phone})
+		count, err = stores.Users.Count(ctx, "phone", user.Phone)
 		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event": "sign_up_error",
@@ -161,21 +150,30 @@ func SignUp() gin.HandlerFunc {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event": "sign_up_error",
 				"time":  time.Now().Format(time.RFC3339),
-			}).Error("This email or phone number already exists")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "this email or phone number already exists"})
+			}).Error("This phone number already exists")
+			c.JSON(http.StatusConflict, gin.H{"error": "this phone number already exists"})
 			return
 		}
 
+		// everyone signs up as a waiter, whatever the email; the owner is only
+		// promoted by the role migration on start, never by a public request
+		role := models.ROLE_WAITER
+		user.Role = &role
+
 		user.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		user.ID = primitive.NewObjectID()
 		user.User_id = user.ID.Hex()
 
-		token, refreshToken, _ := helper.GenerateAllTokens(*user.Email, *user.First_name, *user.Last_name, user.User_id)
+		token, refreshToken, _ := helper.GenerateAllTokens(*user.Email, *user.First_name, *user.Last_name, user.User_id, *user.Role)
 		user.Token = &token
 		user.Refresh_Token = &refreshToken
 
-		resultInsertionNumber, insertErr := userCollection.InsertOne(ctx, user)
+		resultInsertionNumber, insertErr := stores.Users.Insert(ctx, user)
+		if insertErr == store.ErrDuplicate {
+			c.JSON(http.StatusConflict, gin.H{"error": "this email already exists"})
+			return
+		}
 		if insertErr != nil {
 			msg := "User item was not created"
 			appLogger.Log.WithFields(logrus.Fields{
@@ -195,13 +193,12 @@ func SignUp() gin.HandlerFunc {
 	}
 }
 
-func Login() gin.HandlerFunc {
+func Login(stores *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		var user models.User
-		var foundUser models.User
 
 		if err := c.BindJSON(&user); err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
@@ -213,7 +210,12 @@ func Login() gin.HandlerFunc {
 			return
 		}
 
-		err := userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&foundUser)
+		email := ""
+		if user.Email != nil {
+			email = *user.Email
+		}
+
+		foundUser, err := stores.Users.ByEmail(ctx, email)
 		if err != nil {
 			appLogger.Log.WithFields(logrus.Fields{
 				"event":   "login_error",
@@ -236,8 +238,15 @@ func Login() gin.HandlerFunc {
 			return
 		}
 
-		token, refreshToken, _ := helper.GenerateAllTokens(*foundUser.Email, *foundUser.First_name, *foundUser.Last_name, foundUser.User_id)
-		helper.UpdateAllTokens(token, refreshToken, foundUser.User_id)
+		role := ""
+		if foundUser.Role != nil {
+			role = *foundUser.Role
+		}
+
+		token, refreshToken, _ := helper.GenerateAllTokens(*foundUser.Email, *foundUser.First_name, *foundUser.Last_name, foundUser.User_id, role)
+		helper.UpdateAllTokens(stores.Users, token, refreshToken, foundUser.User_id)
+		foundUser.Token = &token
+		foundUser.Refresh_Token = &refreshToken
 
 		appLogger.Log.WithFields(logrus.Fields{
 			"event":   "login_success",
@@ -248,6 +257,200 @@ func Login() gin.HandlerFunc {
 	}
 }
 
+type RefreshRequest struct {
+	Refresh_token string `json:"refresh_token" validate:"required"`
+}
+
+func RefreshToken(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request RefreshRequest
+
+		if err := c.BindJSON(&request); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "refresh_token_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		foundUser, msg := helper.ValidateRefreshToken(stores.Users, request.Refresh_token)
+		if msg != "" {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "refresh_token_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": msg,
+			}).Warn("Refresh token was rejected")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+
+		role := ""
+		if foundUser.Role != nil {
+			role = *foundUser.Role
+		}
+
+		token, refreshToken, _ := helper.GenerateAllTokens(*foundUser.Email, *foundUser.First_name, *foundUser.Last_name, foundUser.User_id, role)
+		helper.UpdateAllTokens(stores.Users, token, refreshToken, foundUser.User_id)
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":   "refresh_token_success",
+			"time":    time.Now().Format(time.RFC3339),
+			"user_id": foundUser.User_id,
+		}).Info("Successfully rotated tokens")
+		c.JSON(http.StatusOK, gin.H{"token": token, "refresh_token": refreshToken})
+	}
+}
+
+func Logout(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revokeTokens(c, stores.Users, c.GetString("uid"), "logout")
+	}
+}
+
+func RevokeUserTokens(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+		user, err := stores.Users.Get(ctx, userId)
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user was not found"})
+			return
+		}
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":   "revoke_user_tokens_error",
+				"time":    time.Now().Format(time.RFC3339),
+				"user_id": userId,
+				"error":   err,
+			}).Error("Error occurred while fetching user")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while fetching user"})
+			return
+		}
+
+		// a manager cannot sign the owner out
+		if role := c.GetString("role"); user.Role != nil && models.Outranks(*user.Role, role) {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":      "revoke_user_tokens_rejected",
+				"time":       time.Now().Format(time.RFC3339),
+				"user_id":    userId,
+				"revoked_by": c.GetString("uid"),
+			}).Warn("Tried to revoke the tokens of a higher role")
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot revoke the tokens of a user with a higher role"})
+			return
+		}
+
+		revokeTokens(c, stores.Users, userId, "revoke_user_tokens")
+	}
+}
+
+func revokeTokens(c *gin.Context, users store.UserStore, userId string, event string) {
+	err := helper.RevokeAllTokens(users, userId)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user was not found"})
+		return
+	}
+	if err != nil {
+		msg := "tokens could not be revoked"
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":   event + "_error",
+			"time":    time.Now().Format(time.RFC3339),
+			"user_id": userId,
+			"error":   err,
+		}).Error(msg)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	appLogger.Log.WithFields(logrus.Fields{
+		"event":      event + "_success",
+		"time":       time.Now().Format(time.RFC3339),
+		"user_id":    userId,
+		"revoked_by": c.GetString("uid"),
+	}).Info("Successfully revoked tokens")
+	c.JSON(http.StatusOK, gin.H{"message": "tokens revoked"})
+}
+
+func UpdateUserRole(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var user models.User
+		userId := c.Param("user_id")
+
+		if err := c.BindJSON(&user); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "update_user_role_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if user.Role == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role is required"})
+			return
+		}
+
+		validationErr := validate.Var(*user.Role, "eq=OWNER|eq=MANAGER|eq=WAITER|eq=KITCHEN|eq=CASHIER")
+		if validationErr != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":   "update_user_role_error",
+				"time":    time.Now().Format(time.RFC3339),
+				"user_id": userId,
+				"error":   validationErr,
+			}).Error("Validation error")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role must be one of OWNER, MANAGER, WAITER, KITCHEN, CASHIER"})
+			return
+		}
+
+		if userId == c.GetString("uid") && *user.Role != models.ROLE_OWNER {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "owners cannot demote themselves"})
+			return
+		}
+
+		user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		result, err := stores.Users.Update(ctx, userId, store.Patch{Set: bson.M{"role": user.Role, "updated_at": user.Updated_at}})
+		if err != nil {
+			msg := "user role update failed"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":   "update_user_role_error",
+				"time":    time.Now().Format(time.RFC3339),
+				"user_id": userId,
+				"error":   err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user was not found"})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":      "update_user_role_success",
+			"time":       time.Now().Format(time.RFC3339),
+			"user_id":    userId,
+			"role":       *user.Role,
+			"changed_by": c.GetString("uid"),
+		}).Info("Successfully updated user role")
+		c.JSON(http.StatusOK, result)
+	}
+}
+
 func HashPassword(password string) string {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	if err != nil {
@@ -0,0 +1,529 @@
+package controller
+
+import (
+	"context"
+	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultSeatingMinutes = 60
+	minimumWaitMinutes    = 5
+	seatingHistoryLimit   = 500
+)
+
+type tableSlot struct {
+	table   models.Table
+	freeAt  time.Time
+	seating time.Duration
+}
+
+type SeatPartyRequest struct {
+	Table_id *string `json:"table_id"`
+}
+
+func openOrdersByTable(ctx context.Context, orderStore store.OrderStore) (map[string]models.Order, error) {
+	orders, err := orderStore.WithStatus(ctx, 0, models.OPEN_ORDER_STATUSES...)
+	if err != nil {
+		return nil, err
+	}
+
+	open := map[string]models.Order{}
+	for _, order := range orders {
+		if order.Table_id == nil {
+			continue
+		}
+		if current, ok := open[*order.Table_id]; !ok || order.Created_at.Before(current.Created_at) {
+			open[*order.Table_id] = order
+		}
+	}
+	return open, nil
+}
+
+// seatingDurations learns how long parties stay, from placing the order to
+// closing it, per table and across the room, over the most recent closed orders.
+func seatingDurations(ctx context.Context, orderStore store.OrderStore) (map[string]time.Duration, time.Duration, error) {
+	orders, err := orderStore.WithStatus(ctx, seatingHistoryLimit, models.ORDER_CLOSED)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	totals := map[string]time.Duration{}
+	counts := map[string]int{}
+	var overall time.Duration
+	var overallCount int
+
+	for _, order := range orders {
+		if order.Table_id == nil {
+			continue
+		}
+		for _, change := range order.Status_history {
+			if change.To != models.ORDER_CLOSED || !change.Changed_at.After(order.Created_at) {
+				continue
+			}
+			duration := change.Changed_at.Sub(order.Created_at)
+			totals[*order.Table_id] += duration
+			counts[*order.Table_id]++
+			overall += duration
+			overallCount++
+		}
+	}
+
+	averages := map[string]time.Duration{}
+	for tableId, total := range totals {
+		averages[tableId] = total / time.Duration(counts[tableId])
+	}
+
+	fallback := defaultSeatingMinutes * time.Minute
+	if overallCount > 0 {
+		fallback = overall / time.Duration(overallCount)
+	}
+	return averages, fallback, nil
+}
+
+func tableSlots(ctx context.Context, stores *store.Store, now time.Time) ([]*tableSlot, map[string]models.Order, error) {
+	tables, err := stores.Tables.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	open, err := openOrdersByTable(ctx, stores.Orders)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	averages, fallback, err := seatingDurations(ctx, stores.Orders)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var slots []*tableSlot
+	for _, table := range tables {
+		seating, ok := averages[table.Table_id]
+		if !ok {
+			seating = fallback
+		}
+
+		freeAt := now
+		if order, occupied := open[table.Table_id]; occupied {
+			freeAt = order.Created_at.Add(seating)
+			// parties that overstay are expected to leave soon, not never
+			if earliest := now.Add(minimumWaitMinutes * time.Minute); freeAt.Before(earliest) {
+				freeAt = earliest
+			}
+		}
+		slots = append(slots, &tableSlot{table: table, freeAt: freeAt, seating: seating})
+	}
+	return slots, open, nil
+}
+
+// quoteWaits hands parties the first table that fits them in queue order.
+// Each seating pushes that table's next free time out by its usual stay, so
+// parties further back are quoted behind the ones ahead of them. A party no
+// table can hold gets -1.
+func quoteWaits(slots []*tableSlot, partySizes []int, now time.Time) []int {
+	quotes := make([]int, len(partySizes))
+
+	for i, partySize := range partySizes {
+		var best *tableSlot
+		for _, slot := range slots {
+			if slot.table.Number_of_guests == nil || *slot.table.Number_of_guests < partySize {
+				continue
+			}
+			if best == nil || slot.freeAt.Before(best.freeAt) ||
+				(slot.freeAt.Equal(best.freeAt) && *slot.table.Number_of_guests < *best.table.Number_of_guests) {
+				best = slot
+			}
+		}
+
+		if best == nil {
+			quotes[i] = -1
+			continue
+		}
+
+		seatedAt := best.freeAt
+		if seatedAt.Before(now) {
+			seatedAt = now
+		}
+		quotes[i] = int(math.Ceil(seatedAt.Sub(now).Minutes()))
+		best.freeAt = seatedAt.Add(best.seating)
+	}
+	return quotes
+}
+
+func GetWaitlist(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		now := time.Now()
+		entries, err := stores.Waitlist.Waiting(ctx)
+		if err == nil {
+			var slots []*tableSlot
+			slots, _, err = tableSlots(ctx, stores, now)
+			if err == nil {
+				partySizes := make([]int, len(entries))
+				for i, entry := range entries {
+					partySizes[i] = *entry.Party_size
+				}
+				for i, quote := range quoteWaits(slots, partySizes, now) {
+					entries[i].Quoted_wait_minutes = quote
+				}
+			}
+		}
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "get_waitlist_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while listing the waitlist")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the waitlist"})
+			return
+		}
+
+		if entries == nil {
+			entries = []models.WaitlistEntry{}
+		}
+		appLogger.Log.WithFields(logrus.Fields{
+			"event": "get_waitlist_success",
+			"time":  time.Now().Format(time.RFC3339),
+		}).Info("Successfully retrieved the waitlist")
+		c.JSON(http.StatusOK, entries)
+	}
+}
+
+func CreateWaitlistEntry(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var entry models.WaitlistEntry
+
+		if err := c.BindJSON(&entry); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_waitlist_entry_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(entry)
+		if validationErr != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_waitlist_entry_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": validationErr,
+			}).Error("Validation error")
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		now := time.Now()
+		entries, err := stores.Waitlist.Waiting(ctx)
+		var slots []*tableSlot
+		if err == nil {
+			slots, _, err = tableSlots(ctx, stores, now)
+		}
+		if err != nil {
+			msg := "error occurred while estimating the wait"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_waitlist_entry_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		partySizes := make([]int, 0, len(entries)+1)
+		for _, waiting := range entries {
+			partySizes = append(partySizes, *waiting.Party_size)
+		}
+		partySizes = append(partySizes, *entry.Party_size)
+		quotes := quoteWaits(slots, partySizes, now)
+
+		if quotes[len(quotes)-1] < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no table is large enough for this party"})
+			return
+		}
+
+		status := models.WAITLIST_WAITING
+		entry.Status = &status
+		entry.Quoted_wait_minutes = quotes[len(quotes)-1]
+		entry.Table_id = nil
+		entry.Order_id = nil
+		entry.Seated_at = nil
+		entry.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		entry.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		entry.ID = primitive.NewObjectID()
+		entry.Waitlist_id = entry.ID.Hex()
+
+		if _, err := stores.Waitlist.Insert(ctx, entry); err != nil {
+			msg := "waitlist entry was not created"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "create_waitlist_entry_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":               "create_waitlist_entry_success",
+			"time":                time.Now().Format(time.RFC3339),
+			"waitlist_id":         entry.Waitlist_id,
+			"party_size":          *entry.Party_size,
+			"quoted_wait_minutes": entry.Quoted_wait_minutes,
+		}).Info("Successfully added party to the waitlist")
+		c.JSON(http.StatusOK, entry)
+	}
+}
+
+func UpdateWaitlistEntry(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var entry models.WaitlistEntry
+		waitlistId := c.Param("waitlist_id")
+
+		if err := c.BindJSON(&entry); err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "update_waitlist_entry_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		updateObj := bson.M{}
+		if entry.Party_name != nil {
+			updateObj["party_name"] = entry.Party_name
+		}
+		if entry.Party_size != nil {
+			if *entry.Party_size < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "party_size must be at least 1"})
+				return
+			}
+			updateObj["party_size"] = entry.Party_size
+		}
+		if entry.Phone != nil {
+			updateObj["phone"] = entry.Phone
+		}
+		if entry.Notes != nil {
+			updateObj["notes"] = entry.Notes
+		}
+		if entry.Status != nil {
+			// seating goes through POST /waitlist/:waitlist_id/seat so an order gets opened
+			if *entry.Status != models.WAITLIST_LEFT && *entry.Status != models.WAITLIST_WAITING {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "status can only be set to WAITING or LEFT"})
+				return
+			}
+			updateObj["status"] = entry.Status
+		}
+
+		entry.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj["updated_at"] = entry.Updated_at
+
+		current, err := stores.Waitlist.Get(ctx, waitlistId)
+		if err == store.ErrNotFound || (current.Status != nil && *current.Status == models.WAITLIST_SEATED) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "waitlist entry was not found or is already seated"})
+			return
+		}
+
+		var result store.UpdateResult
+		if err == nil {
+			result, err = stores.Waitlist.Update(ctx, waitlistId, store.Patch{If: bson.M{"status": current.Status}, Set: updateObj})
+		}
+		if err != nil {
+			msg := "waitlist entry update failed"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":       "update_waitlist_entry_error",
+				"time":        time.Now().Format(time.RFC3339),
+				"waitlist_id": waitlistId,
+				"error":       err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "party was seated in the meantime"})
+			return
+		}
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":       "update_waitlist_entry_success",
+			"time":        time.Now().Format(time.RFC3339),
+			"waitlist_id": waitlistId,
+		}).Info("Successfully updated waitlist entry")
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func SeatParty(stores *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request SeatPartyRequest
+		waitlistId := c.Param("waitlist_id")
+
+		if err := c.ShouldBindJSON(&request); err != nil && c.Request.ContentLength > 0 {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event": "seat_party_error",
+				"time":  time.Now().Format(time.RFC3339),
+				"error": err,
+			}).Error("Error occurred while binding JSON")
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		entry, err := stores.Waitlist.Get(ctx, waitlistId)
+		if err != nil || entry.Status == nil || *entry.Status != models.WAITLIST_WAITING {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no waiting party with this id"})
+			return
+		}
+
+		now := time.Now()
+		slots, open, err := tableSlots(ctx, stores, now)
+		if err != nil {
+			msg := "error occurred while checking table availability"
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":       "seat_party_error",
+				"time":        time.Now().Format(time.RFC3339),
+				"waitlist_id": waitlistId,
+				"error":       err,
+			}).Error(msg)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		// without a table from the host, take the smallest free table that fits
+		sort.Slice(slots, func(i, j int) bool {
+			return valueOrZero(slots[i].table.Number_of_guests) < valueOrZero(slots[j].table.Number_of_guests)
+		})
+
+		var table *models.Table
+		for _, slot := range slots {
+			if request.Table_id != nil && slot.table.Table_id != *request.Table_id {
+				continue
+			}
+			if _, occupied := open[slot.table.Table_id]; occupied || slot.table.NeedsCleaning() {
+				continue
+			}
+			if valueOrZero(slot.table.Number_of_guests) < *entry.Party_size {
+				continue
+			}
+			// the party would still be there when a booking for the table arrives
+			booked, err := stores.Reservations.BookedTables(ctx, now, now.Add(slot.seating), "")
+			if err != nil {
+				appLogger.Log.WithFields(logrus.Fields{
+					"event":       "seat_party_error",
+					"time":        time.Now().Format(time.RFC3339),
+					"waitlist_id": waitlistId,
+					"error":       err,
+				}).Error("Error occurred while checking reservations")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking reservations"})
+				return
+			}
+			if containsAny(booked, []string{slot.table.Table_id}) {
+				continue
+			}
+			candidate := slot.table
+			table = &candidate
+			break
+		}
+
+		if table == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "no free table fits this party"})
+			return
+		}
+
+		seatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		// hold the table, then make sure nobody seated it since it was picked
+		release, err := holdTable(ctx, stores.Tables, table.Table_id)
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusConflict, gin.H{"error": "table is being seated or booked by someone else"})
+			return
+		}
+		if err != nil {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":       "seat_party_error",
+				"time":        time.Now().Format(time.RFC3339),
+				"waitlist_id": waitlistId,
+				"table_id":    table.Table_id,
+				"error":       err,
+			}).Error("Error occurred while claiming the table")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while claiming the table"})
+			return
+		}
+		defer release()
+
+		open, err = openOrdersByTable(ctx, stores.Orders)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking table availability"})
+			return
+		}
+		if _, occupied := open[table.Table_id]; occupied {
+			c.JSON(http.StatusConflict, gin.H{"error": "table was seated in the meantime"})
+			return
+		}
+
+		claim, err := stores.Waitlist.Update(ctx, waitlistId, store.Patch{
+			If:  bson.M{"status": models.WAITLIST_WAITING},
+			Set: bson.M{"status": models.WAITLIST_SEATED, "table_id": table.Table_id, "seated_at": seatedAt, "updated_at": seatedAt},
+		})
+		if err != nil || claim.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "party was seated or left in the meantime"})
+			return
+		}
+
+		var order models.Order
+		order.Order_Date = seatedAt
+		order.Table_id = &table.Table_id
+		placeOrder(&order, c.GetString("uid"))
+		orderId := OrderItemOrderCreator(stores.Orders, order)
+
+		if orderId == "" {
+			stores.Waitlist.Update(ctx, waitlistId, store.Patch{
+				Set: bson.M{"status": models.WAITLIST_WAITING, "table_id": nil, "seated_at": nil},
+			})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "order was not created for the table"})
+			return
+		}
+
+		stores.Waitlist.Update(ctx, waitlistId, store.Patch{Set: bson.M{"order_id": orderId}})
+
+		appLogger.Log.WithFields(logrus.Fields{
+			"event":       "seat_party_success",
+			"time":        time.Now().Format(time.RFC3339),
+			"waitlist_id": waitlistId,
+			"table_id":    table.Table_id,
+			"order_id":    orderId,
+			"waited":      now.Sub(entry.Created_at).Round(time.Minute).String(),
+		}).Info("Successfully seated party from the waitlist")
+		c.JSON(http.StatusOK, gin.H{"waitlist_id": waitlistId, "table_id": table.Table_id, "order_id": orderId})
+	}
+}
+
+func valueOrZero(value *int) int {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
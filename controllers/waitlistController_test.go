@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func slot(seats int, freeIn time.Duration, now time.Time) *tableSlot {
+	return &tableSlot{
+		table:   models.Table{Table_id: primitive.NewObjectID().Hex(), Number_of_guests: &seats},
+		freeAt:  now.Add(freeIn),
+		seating: time.Hour,
+	}
+}
+
+func TestQuoteWaits(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 19, 0, 0, 0, time.UTC)
+	room := func() []*tableSlot {
+		return []*tableSlot{slot(2, 0, now), slot(4, 30*time.Minute, now)}
+	}
+
+	tests := []struct {
+		name       string
+		slots      []*tableSlot
+		partySizes []int
+		want       []int
+	}{
+		{"free table", room(), []int{2}, []int{0}},
+		{"second party waits for the next table", room(), []int{2, 2}, []int{0, 30}},
+		{"big party waits for a big table", room(), []int{4}, []int{30}},
+		{"queue order is kept", room(), []int{4, 3, 2}, []int{30, 90, 0}},
+		{"nothing holds the party", room(), []int{6, 2}, []int{-1, 0}},
+		{"a table free in the past is free now", []*tableSlot{slot(2, -time.Hour, now)}, []int{2, 2}, []int{0, 60}},
+		{"ties go to the smaller table", []*tableSlot{slot(4, 0, now), slot(2, 0, now)}, []int{2, 4}, []int{0, 0}},
+		{"part minutes round up", []*tableSlot{slot(2, 90*time.Second, now)}, []int{2}, []int{2}},
+		{"no tables", nil, []int{2}, []int{-1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := quoteWaits(test.slots, test.partySizes, now)
+			if len(got) != len(test.want) {
+				t.Fatalf("expected %v, got %v", test.want, got)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("expected %v, got %v", test.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestTableSlotsLearnFromClosedOrders(t *testing.T) {
+	stores := store.NewMemory()
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	tableIds := map[string]string{}
+	for _, name := range []string{"regular", "quiet", "overstayed"} {
+		seats := 4
+		table := models.Table{ID: primitive.NewObjectID(), Number_of_guests: &seats}
+		table.Table_id = table.ID.Hex()
+		if _, err := stores.Tables.Insert(ctx, table); err != nil {
+			t.Fatal(err)
+		}
+		tableIds[name] = table.Table_id
+	}
+
+	order := func(tableName string, status string, createdAgo time.Duration, stayed time.Duration) {
+		tableId := tableIds[tableName]
+		created := now.Add(-createdAgo)
+		order := models.Order{ID: primitive.NewObjectID(), Table_id: &tableId, Status: &status, Created_at: created}
+		order.Order_id = order.ID.Hex()
+		if status == models.ORDER_CLOSED {
+			order.Status_history = []models.OrderStatusChange{{From: models.ORDER_SERVED, To: models.ORDER_CLOSED, Changed_at: created.Add(stayed)}}
+		}
+		if _, err := stores.Orders.Insert(ctx, order); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the regular table turns over in 40 minutes, the room in 50 on average
+	order("regular", models.ORDER_CLOSED, 5*time.Hour, 30*time.Minute)
+	order("regular", models.ORDER_CLOSED, 4*time.Hour, 50*time.Minute)
+	order("overstayed", models.ORDER_CLOSED, 3*time.Hour, 70*time.Minute)
+	order("regular", models.ORDER_PLACED, 10*time.Minute, 0)
+	order("overstayed", models.ORDER_SERVED, 2*time.Hour, 0)
+
+	slots, open, err := tableSlots(ctx, stores, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(open) != 2 {
+		t.Fatalf("expected two occupied tables, got %d", len(open))
+	}
+
+	tests := []struct {
+		table   string
+		freeIn  time.Duration
+		seating time.Duration
+	}{
+		{"regular", 30 * time.Minute, 40 * time.Minute},
+		{"quiet", 0, 50 * time.Minute},
+		{"overstayed", minimumWaitMinutes * time.Minute, 70 * time.Minute},
+	}
+	for _, test := range tests {
+		var found *tableSlot
+		for _, slot := range slots {
+			if slot.table.Table_id == tableIds[test.table] {
+				found = slot
+			}
+		}
+		if found == nil {
+			t.Fatalf("%s: no slot", test.table)
+		}
+		if got := found.freeAt.Sub(now); got != test.freeIn {
+			t.Errorf("%s: expected free in %s, got %s", test.table, test.freeIn, got)
+		}
+		if found.seating != test.seating {
+			t.Errorf("%s: expected a %s stay, got %s", test.table, test.seating, found.seating)
+		}
+	}
+}
+
+func TestSeatingDurationsDefaultWithoutHistory(t *testing.T) {
+	averages, fallback, err := seatingDurations(context.Background(), store.NewMemory().Orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(averages) != 0 || fallback != defaultSeatingMinutes*time.Minute {
+		t.Fatalf("expected the default stay, got %v and %s", averages, fallback)
+	}
+}
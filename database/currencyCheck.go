@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// storedMoneyFields are the top-level amounts of each collection. Every
+// document with money has at least one of them, so checking these is enough
+// to notice data written under another currency.
+var storedMoneyFields = map[string][]string{
+	"food":          {"price"},
+	"ingredient":    {"unit_cost"},
+	"orderItem":     {"unit_price"},
+	"invoice":       {"subtotal", "discount", "service_charge", "tax", "tip", "total", "amount_paid", "tips_collected"},
+	"payment":       {"amount", "tip"},
+	"pricingRule":   {"fixed_price"},
+	"promotion":     {"amount_off", "combo_price", "min_subtotal"},
+	"purchaseOrder": {"total"},
+}
+
+// ForeignCurrencyFields lists the collection.field pairs holding amounts in a
+// currency other than the given one. Nothing converts between currencies, so
+// the service must not start on such data after CURRENCY is changed.
+func ForeignCurrencyFields(client *mongo.Client, currency string) ([]string, error) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var foreign []string
+	for collectionName, fields := range storedMoneyFields {
+		collection := OpenCollection(client, collectionName)
+
+		for _, field := range fields {
+			filter := bson.M{field + ".currency": bson.M{"$exists": true, "$nin": bson.A{currency, ""}}}
+			err := collection.FindOne(ctx, filter).Err()
+			if err == mongo.ErrNoDocuments {
+				continue
+			}
+			if err != nil {
+				return foreign, err
+			}
+			foreign = append(foreign, collectionName+"."+field)
+		}
+	}
+	return foreign, nil
+}
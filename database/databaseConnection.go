@@ -3,38 +3,70 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
+	"golang-restaurant-management/config"
+	"golang-restaurant-management/metrics"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-func DBinstance() *mongo.Client {
-	MongoDb := "mongodb://mongo:27017"
-	fmt.Print(MongoDb)
+const (
+	minConnectBackoff = time.Second
+	maxConnectBackoff = 15 * time.Second
+	pingTimeout       = 2 * time.Second
+)
+
+// Connect builds the client and pings MongoDB until it answers, backing off
+// between attempts, and gives up once timeout has passed. onRetry is told
+// about every failed attempt so startup does not look hung. The client is
+// handed back to be passed to whatever needs the database.
+func Connect(timeout time.Duration, onRetry func(attempt int, err error)) (*mongo.Client, error) {
+	var ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	client, err := mongo.NewClient(options.Client().ApplyURI(MongoDb))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.Settings.Mongo.Url).SetMonitor(metrics.MongoMonitor()))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 
-	defer cancel()
-
-	err = client.Connect(ctx)
+	backoff := minConnectBackoff
+	for attempt := 1; ; attempt++ {
+		err := Ping(ctx, client)
+		if err == nil {
+			return client, nil
+		}
+		if onRetry != nil {
+			onRetry(attempt, err)
+		}
 
-	if err != nil {
-		log.Fatal(err)
+		select {
+		case <-ctx.Done():
+			client.Disconnect(context.Background())
+			return nil, fmt.Errorf("mongo unreachable after %d attempts: %w", attempt, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
 	}
-	fmt.Println("connected to mongodb")
-	return client
 }
 
-var Client *mongo.Client = DBinstance()
+// Ping checks that the primary answers, waiting at most a couple of seconds.
+func Ping(ctx context.Context, client *mongo.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	return client.Ping(ctx, readpref.Primary())
+}
+
+// Disconnect closes the pooled connections once requests have drained.
+func Disconnect(client *mongo.Client, timeout time.Duration) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return client.Disconnect(ctx)
+}
 
 func OpenCollection(client *mongo.Client, collectionName string) *mongo.Collection {
-	var collection *mongo.Collection = client.Database("restaurant").Collection(collectionName)
+	var collection *mongo.Collection = client.Database(config.Settings.Mongo.Database).Collection(collectionName)
 
 	return collection
 }
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexes creates the indexes the handlers rely on for correctness
+// rather than speed. Creating an index that already exists is a no-op, so it
+// runs on every start.
+//
+// Promotion codes are unique among the promotions that have one; those
+// without a code are applied automatically and are left out of the index.
+// Emails are unique so two sign ups racing on one address cannot both win.
+func EnsureIndexes(client *mongo.Client) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := OpenCollection(client, "promotion").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().
+			SetName("code_unique").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"code": bson.M{"$type": "string"}}),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = OpenCollection(client, "user").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetName("email_unique").SetUnique(true),
+	})
+	return err
+}
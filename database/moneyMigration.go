@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MoneyMigrationResult struct {
+	Collection string
+	Field      string
+	Modified   int64
+}
+
+var moneyFields = map[string][]string{
+	"food":      {"price"},
+	"orderItem": {"unit_price"},
+	"invoice":   {"subtotal", "service_charge", "tax", "tip", "total"},
+}
+
+// MigrateMoneyFields rewrites amounts stored as plain numbers into
+// {amount: <minor units>, currency: <code>} documents. Already migrated
+// documents are skipped, so it is safe to run on every start.
+func MigrateMoneyFields(client *mongo.Client) ([]MoneyMigrationResult, error) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var results []MoneyMigrationResult
+	for collectionName, fields := range moneyFields {
+		collection := OpenCollection(client, collectionName)
+
+		for _, field := range fields {
+			filter := bson.M{field: bson.M{"$type": bson.A{"double", "int", "long", "decimal"}}}
+			update := bson.A{
+				bson.M{"$set": bson.M{field: bson.M{
+					"amount": bson.M{"$toLong": bson.M{"$round": bson.A{
+						bson.M{"$multiply": bson.A{bson.M{"$toDecimal": "$" + field}, models.MINOR_UNITS}},
+						0,
+					}}},
+					"currency": models.DEFAULT_CURRENCY,
+				}}},
+			}
+
+			result, err := collection.UpdateMany(ctx, filter, update)
+			if err != nil {
+				return results, err
+			}
+			results = append(results, MoneyMigrationResult{Collection: collectionName, Field: field, Modified: result.ModifiedCount})
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MigrateOrderStatuses gives orders saved before statuses existed one, so
+// the floor and the waitlist count them like any other order: CLOSED when
+// their invoice is paid, PLACED otherwise, for staff to close or cancel.
+// Only orders without a status are touched, so it is safe to run on every
+// start. It reports how many orders were closed and how many left open.
+func MigrateOrderStatuses(client *mongo.Client) (int64, int64, error) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	orders := OpenCollection(client, "order")
+	invoices := OpenCollection(client, "invoice")
+	legacy := bson.M{"status": bson.M{"$in": bson.A{nil, ""}}}
+
+	orderIds, err := orders.Distinct(ctx, "order_id", legacy)
+	if err != nil || len(orderIds) == 0 {
+		return 0, 0, err
+	}
+	paid, err := invoices.Distinct(ctx, "order_id", bson.M{
+		"order_id":       bson.M{"$in": orderIds},
+		"payment_status": models.PAYMENT_PAID,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	migratedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	// an empty history, rather than a made up closing time, keeps these out of
+	// the seating durations the waitlist learns from
+	closed, err := orders.UpdateMany(ctx,
+		bson.M{"status": legacy["status"], "order_id": bson.M{"$in": paid}},
+		bson.M{"$set": bson.M{"status": models.ORDER_CLOSED, "status_history": bson.A{}, "updated_at": migratedAt}},
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	placed, err := orders.UpdateMany(ctx, legacy,
+		bson.M{"$set": bson.M{"status": models.ORDER_PLACED, "status_history": bson.A{}, "updated_at": migratedAt}},
+	)
+	if err != nil {
+		return closed.ModifiedCount, 0, err
+	}
+	return closed.ModifiedCount, placed.ModifiedCount, nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type legacyOrderItem struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Quantity   string             `bson:"quantity"`
+	Unit_price *models.Money      `bson:"unit_price"`
+	Food_id    *string            `bson:"food_id"`
+}
+
+// MigrateOrderItemQuantities moves the S/M/L that used to be stored in
+// quantity into size, sets quantity to one unit and bakes the size into the
+// unit price, which used to be scaled when the order was priced. Only items
+// whose quantity is still a string are touched, so it is safe to run on
+// every start.
+func MigrateOrderItemQuantities(client *mongo.Client, sizeMultiplier func(size string) float64) (int64, error) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	orderItems := OpenCollection(client, "orderItem")
+	foods := OpenCollection(client, "food")
+
+	cursor, err := orderItems.Find(ctx, bson.M{"quantity": bson.M{"$type": "string"}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var migrated int64
+	for cursor.Next(ctx) {
+		var item legacyOrderItem
+		if err := cursor.Decode(&item); err != nil {
+			return migrated, err
+		}
+
+		update := bson.M{"quantity": 1, "size": item.Quantity}
+
+		base := item.Unit_price
+		if base == nil && item.Food_id != nil {
+			var food models.Food
+			if err := foods.FindOne(ctx, bson.M{"food_id": item.Food_id}).Decode(&food); err == nil {
+				base = food.Price
+			}
+		}
+		if base != nil {
+			update["unit_price"] = base.MulRate(sizeMultiplier(item.Quantity))
+		}
+
+		result, err := orderItems.UpdateOne(ctx,
+			bson.M{"_id": item.ID, "quantity": bson.M{"$type": "string"}},
+			bson.M{"$set": update},
+		)
+		if err != nil {
+			return migrated, err
+		}
+		migrated += result.ModifiedCount
+	}
+	return migrated, cursor.Err()
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MigrateUserRoles gives accounts created before roles existed the waiter
+// role, then makes the configured owner email OWNER if that account exists.
+// Both steps only touch users that need them, so it is safe to run on every
+// start. It reports how many roles were backfilled and whether the owner
+// was promoted.
+func MigrateUserRoles(client *mongo.Client, ownerEmail string) (int64, bool, error) {
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	users := OpenCollection(client, "user")
+
+	backfilled, err := users.UpdateMany(ctx,
+		bson.M{"$or": bson.A{bson.M{"role": nil}, bson.M{"role": ""}}},
+		bson.M{"$set": bson.M{"role": models.ROLE_WAITER}},
+	)
+	if err != nil {
+		return 0, false, err
+	}
+	if ownerEmail == "" {
+		return backfilled.ModifiedCount, false, nil
+	}
+
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	promoted, err := users.UpdateOne(ctx,
+		bson.M{
+			"$expr": bson.M{"$eq": bson.A{bson.M{"$toLower": "$email"}, ownerEmail}},
+			"role":  bson.M{"$ne": models.ROLE_OWNER},
+		},
+		bson.M{"$set": bson.M{"role": models.ROLE_OWNER, "updated_at": updatedAt}},
+	)
+	if err != nil {
+		return backfilled.ModifiedCount, false, err
+	}
+	return backfilled.ModifiedCount, promoted.ModifiedCount > 0, nil
+}
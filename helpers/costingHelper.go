@@ -0,0 +1,44 @@
+package helper
+
+import (
+	"golang-restaurant-management/models"
+	"math"
+)
+
+type PlateCosting struct {
+	Price                   models.Money
+	Plate_cost              models.Money
+	Gross_margin            models.Money
+	Gross_margin_percentage float64
+	Food_cost_percentage    float64
+	Missing_costs           []string
+}
+
+// CostPlate prices one portion of a recipe at the ingredients' last purchase
+// cost. Ingredients that have never been bought are listed in Missing_costs
+// and count as free, so the plate cost is a lower bound until they are.
+func CostPlate(price models.Money, recipe []models.RecipeLine, unitCosts map[string]models.Money) PlateCosting {
+	costing := PlateCosting{Price: price, Plate_cost: models.NewMoney(0)}
+
+	for _, line := range recipe {
+		unitCost, ok := unitCosts[line.Ingredient_id]
+		if !ok {
+			costing.Missing_costs = append(costing.Missing_costs, line.Ingredient_id)
+			continue
+		}
+		costing.Plate_cost = costing.Plate_cost.Add(unitCost.MulRate(line.Quantity))
+	}
+
+	costing.Gross_margin = price.Sub(costing.Plate_cost)
+	costing.Gross_margin_percentage = Percentage(costing.Gross_margin, price)
+	costing.Food_cost_percentage = Percentage(costing.Plate_cost, price)
+	return costing
+}
+
+// Percentage is part of whole in percent, rounded to two decimals.
+func Percentage(part models.Money, whole models.Money) float64 {
+	if whole.Amount == 0 {
+		return 0
+	}
+	return math.Round(float64(part.Amount)/float64(whole.Amount)*10000) / 100
+}
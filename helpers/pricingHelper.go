@@ -0,0 +1,131 @@
+package helper
+
+import (
+	"fmt"
+	"golang-restaurant-management/config"
+	"golang-restaurant-management/models"
+	"strings"
+)
+
+type PricingConfig struct {
+	Tax_rate            float64
+	Category_tax_rates  map[string]float64
+	Service_charge_rate float64
+	Size_multipliers    map[string]float64
+	Target_margin       float64
+}
+
+type PriceLine struct {
+	Order_item_id string
+	Food_id       string
+	Category      string
+	Quantity      int64
+	Unit_price    models.Money
+	Modifiers     models.Money
+	Discount      models.Money
+}
+
+// PricedLine.Amount is after the line's discount, which is shown alongside.
+type PricedLine struct {
+	Order_item_id string
+	Discount      models.Money
+	Amount        models.Money
+	Tax           models.Money
+}
+
+type OrderTotals struct {
+	Subtotal       models.Money
+	Discount       models.Money
+	Service_charge models.Money
+	Tax            models.Money
+	Tip            models.Money
+	Total          models.Money
+	Lines          []PricedLine
+}
+
+var Pricing = LoadPricingConfig()
+
+func LoadPricingConfig() PricingConfig {
+	settings := config.Settings.Pricing
+	return PricingConfig{
+		Tax_rate:            settings.Tax_rate,
+		Category_tax_rates:  settings.Category_tax_rates,
+		Service_charge_rate: settings.Service_charge_rate,
+		Size_multipliers:    settings.Size_multipliers,
+		Target_margin:       settings.Target_margin,
+	}
+}
+
+func (config PricingConfig) TaxRate(category string) float64 {
+	if rate, ok := config.Category_tax_rates[strings.ToUpper(category)]; ok {
+		return rate
+	}
+	return config.Tax_rate
+}
+
+func (config PricingConfig) SizeMultiplier(size string) float64 {
+	if multiplier, ok := config.Size_multipliers[strings.ToUpper(size)]; ok {
+		return multiplier
+	}
+	return 1
+}
+
+// UnitPrice is the price of one item in the given size. Foods with their own
+// size prices only come in those sizes; others scale the base price by the
+// configured size multiplier.
+func (config PricingConfig) UnitPrice(food models.Food, size string) (models.Money, error) {
+	size = strings.ToUpper(size)
+	if size == "" {
+		return *food.Price, nil
+	}
+	if price, ok := food.Size_prices[size]; ok {
+		return price, nil
+	}
+	if multiplier, ok := config.Size_multipliers[size]; ok && len(food.Size_prices) == 0 {
+		return food.Price.MulRate(multiplier), nil
+	}
+	return models.Money{}, fmt.Errorf("%s is not offered in size %s", *food.Name, size)
+}
+
+// PriceOrder rounds every line to the minor unit before summing, so the
+// lines on the bill always add up to the subtotal. Discounts come off each
+// line before tax and the service charge, and never take a line below zero.
+func (config PricingConfig) PriceOrder(lines []PriceLine, tip models.Money) OrderTotals {
+	totals := OrderTotals{
+		Subtotal: models.NewMoney(0),
+		Discount: models.NewMoney(0),
+		Tax:      models.NewMoney(0),
+	}
+
+	for _, line := range lines {
+		quantity := line.Quantity
+		if quantity < 1 {
+			quantity = 1
+		}
+		// modifiers such as extra cheese cost the same whatever the size
+		gross := line.Unit_price.Add(line.Modifiers).Times(quantity)
+		discount := line.Discount
+		if discount.Amount > gross.Amount {
+			discount = gross
+		}
+		amount := gross.Sub(discount)
+		tax := amount.MulRate(config.TaxRate(line.Category))
+
+		totals.Lines = append(totals.Lines, PricedLine{
+			Order_item_id: line.Order_item_id,
+			Discount:      discount,
+			Amount:        amount,
+			Tax:           tax,
+		})
+		totals.Subtotal = totals.Subtotal.Add(gross)
+		totals.Discount = totals.Discount.Add(discount)
+		totals.Tax = totals.Tax.Add(tax)
+	}
+
+	discounted := totals.Subtotal.Sub(totals.Discount)
+	totals.Service_charge = discounted.MulRate(config.Service_charge_rate)
+	totals.Tip = tip
+	totals.Total = discounted.Add(totals.Service_charge).Add(totals.Tax).Add(totals.Tip)
+
+	return totals
+}
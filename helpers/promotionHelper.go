@@ -0,0 +1,167 @@
+package helper
+
+import (
+	"fmt"
+	"golang-restaurant-management/models"
+	"sort"
+)
+
+type unitShare struct {
+	Order_item_id string
+	Price         models.Money
+}
+
+// DiscountLines works out what a promotion takes off each line, keyed by
+// order item id, or why the order does not qualify. Lines are taken at their
+// full price, so stacked promotions are each worked out on their own and
+// PriceOrder caps the sum at the line amount.
+func DiscountLines(promotion models.Promotion, lines []PriceLine) (map[string]models.Money, error) {
+	discounts := map[string]models.Money{}
+
+	subtotal := models.NewMoney(0)
+	var covered []PriceLine
+	for _, line := range lines {
+		subtotal = subtotal.Add(lineAmount(line))
+		if promotion.Covers(line.Food_id, line.Category) {
+			covered = append(covered, line)
+		}
+	}
+	if promotion.Min_subtotal != nil && subtotal.Amount < promotion.Min_subtotal.Amount {
+		return nil, fmt.Errorf("%s needs an order of at least %s", *promotion.Name, promotion.Min_subtotal)
+	}
+
+	switch *promotion.Kind {
+	case models.PROMO_ORDER:
+		var shares []unitShare
+		base := models.NewMoney(0)
+		for _, line := range covered {
+			shares = append(shares, unitShare{line.Order_item_id, lineAmount(line)})
+			base = base.Add(lineAmount(line))
+		}
+		if base.Amount == 0 {
+			return nil, fmt.Errorf("nothing on the order qualifies for %s", *promotion.Name)
+		}
+		spread(discounts, percentOrAmount(promotion, base), shares)
+
+	case models.PROMO_ITEM:
+		if len(covered) == 0 {
+			return nil, fmt.Errorf("nothing on the order qualifies for %s", *promotion.Name)
+		}
+		for _, line := range covered {
+			perUnit := percentOrAmount(promotion, unitAmount(line))
+			discounts[line.Order_item_id] = discounts[line.Order_item_id].Add(perUnit.Times(quantityOf(line)))
+		}
+
+	case models.PROMO_BUY_X_GET_Y:
+		units := expandUnits(covered)
+		group := *promotion.Buy_quantity + *promotion.Get_quantity
+		free := len(units) / group * *promotion.Get_quantity
+		if free == 0 {
+			return nil, fmt.Errorf("%s needs %d qualifying items", *promotion.Name, group)
+		}
+		rate := 1.0
+		if promotion.Percentage_off != nil {
+			rate = *promotion.Percentage_off / 100
+		}
+		// units are sorted dearest first, so the cheapest ones go free
+		for _, unit := range units[len(units)-free:] {
+			discounts[unit.Order_item_id] = discounts[unit.Order_item_id].Add(unit.Price.MulRate(rate))
+		}
+
+	case models.PROMO_COMBO:
+		needed := map[string]int{}
+		for _, foodId := range promotion.Combo_food_ids {
+			needed[foodId]++
+		}
+		available := map[string][]unitShare{}
+		for _, line := range lines {
+			if needed[line.Food_id] > 0 {
+				available[line.Food_id] = append(available[line.Food_id], expandUnits([]PriceLine{line})...)
+			}
+		}
+		for foodId := range available {
+			units := available[foodId]
+			sort.SliceStable(units, func(a, b int) bool { return units[a].Price.Amount > units[b].Price.Amount })
+		}
+
+		combos := -1
+		for foodId, count := range needed {
+			if made := len(available[foodId]) / count; combos < 0 || made < combos {
+				combos = made
+			}
+		}
+		if combos <= 0 {
+			return nil, fmt.Errorf("the order does not contain the full %s", *promotion.Name)
+		}
+		for i := 0; i < combos; i++ {
+			var shares []unitShare
+			regular := models.NewMoney(0)
+			for foodId, count := range needed {
+				for _, unit := range available[foodId][i*count : (i+1)*count] {
+					shares = append(shares, unit)
+					regular = regular.Add(unit.Price)
+				}
+			}
+			if saving := regular.Sub(*promotion.Combo_price); saving.Amount > 0 {
+				sort.SliceStable(shares, func(a, b int) bool { return shares[a].Order_item_id < shares[b].Order_item_id })
+				spread(discounts, saving, shares)
+			}
+		}
+	}
+	return discounts, nil
+}
+
+func unitAmount(line PriceLine) models.Money {
+	return line.Unit_price.Add(line.Modifiers)
+}
+
+func lineAmount(line PriceLine) models.Money {
+	return unitAmount(line).Times(quantityOf(line))
+}
+
+func quantityOf(line PriceLine) int64 {
+	if line.Quantity < 1 {
+		return 1
+	}
+	return line.Quantity
+}
+
+// percentOrAmount is the promotion's discount on base, never more than base.
+func percentOrAmount(promotion models.Promotion, base models.Money) models.Money {
+	if promotion.Percentage_off != nil {
+		return base.MulRate(*promotion.Percentage_off / 100)
+	}
+	if promotion.Amount_off.Amount > base.Amount {
+		return base
+	}
+	return *promotion.Amount_off
+}
+
+func expandUnits(lines []PriceLine) []unitShare {
+	var units []unitShare
+	for _, line := range lines {
+		for i := int64(0); i < quantityOf(line); i++ {
+			units = append(units, unitShare{line.Order_item_id, unitAmount(line)})
+		}
+	}
+	sort.SliceStable(units, func(a, b int) bool { return units[a].Price.Amount > units[b].Price.Amount })
+	return units
+}
+
+// spread shares amount out in proportion to each share's price, giving the
+// rounding remainder to the last so the parts add up exactly.
+func spread(discounts map[string]models.Money, amount models.Money, shares []unitShare) {
+	whole := models.NewMoney(0)
+	for _, share := range shares {
+		whole = whole.Add(share.Price)
+	}
+	left := amount
+	for i, share := range shares {
+		part := amount.Prorate(share.Price, whole)
+		if i == len(shares)-1 {
+			part = left
+		}
+		left = left.Sub(part)
+		discounts[share.Order_item_id] = discounts[share.Order_item_id].Add(part)
+	}
+}
@@ -0,0 +1,134 @@
+package helper
+
+import (
+	"golang-restaurant-management/models"
+	"strings"
+	"testing"
+)
+
+func line(orderItemId string, foodId string, quantity int64, unitPrice int64) PriceLine {
+	return PriceLine{Order_item_id: orderItemId, Food_id: foodId, Quantity: quantity, Unit_price: models.NewMoney(unitPrice)}
+}
+
+func buyXGetY(buy int, get int, percentageOff *float64, foodIds ...string) models.Promotion {
+	name, kind := "buy and get", models.PROMO_BUY_X_GET_Y
+	return models.Promotion{Name: &name, Kind: &kind, Buy_quantity: &buy, Get_quantity: &get, Percentage_off: percentageOff, Food_ids: foodIds}
+}
+
+func combo(price int64, foodIds ...string) models.Promotion {
+	name, kind := "meal deal", models.PROMO_COMBO
+	comboPrice := models.NewMoney(price)
+	return models.Promotion{Name: &name, Kind: &kind, Combo_food_ids: foodIds, Combo_price: &comboPrice}
+}
+
+func TestDiscountLines(t *testing.T) {
+	half := 50.0
+	withModifier := line("a", "burger", 1, 1000)
+	withModifier.Modifiers = models.NewMoney(200)
+	minimum := combo(1200, "burger", "fries")
+	minimumSubtotal := models.NewMoney(5000)
+	minimum.Min_subtotal = &minimumSubtotal
+
+	tests := []struct {
+		name      string
+		promotion models.Promotion
+		lines     []PriceLine
+		want      map[string]int64
+		err       string
+	}{
+		{
+			"cheapest unit goes free",
+			buyXGetY(2, 1, nil),
+			[]PriceLine{line("a", "burger", 2, 1000), line("b", "fries", 1, 400)},
+			map[string]int64{"b": 400}, "",
+		},
+		{
+			"one free per full group",
+			buyXGetY(2, 1, nil),
+			[]PriceLine{line("a", "burger", 7, 300)},
+			map[string]int64{"a": 600}, "",
+		},
+		{
+			"get y at a percentage off",
+			buyXGetY(1, 1, &half),
+			[]PriceLine{line("a", "burger", 1, 1000), line("b", "fries", 1, 401)},
+			map[string]int64{"b": 201}, "",
+		},
+		{
+			"modifiers are part of the unit price",
+			buyXGetY(1, 1, nil),
+			[]PriceLine{withModifier, line("b", "burger", 1, 1100)},
+			map[string]int64{"b": 1100}, "",
+		},
+		{
+			"only listed foods count",
+			buyXGetY(1, 1, nil, "burger"),
+			[]PriceLine{line("a", "burger", 2, 1000), line("b", "fries", 1, 100)},
+			map[string]int64{"a": 1000}, "",
+		},
+		{
+			"not enough units",
+			buyXGetY(2, 1, nil, "burger"),
+			[]PriceLine{line("a", "burger", 2, 1000), line("b", "fries", 1, 100)},
+			nil, "needs 3 qualifying items",
+		},
+		{
+			"combo saving is spread by price",
+			combo(1200, "burger", "fries", "drink"),
+			[]PriceLine{line("a", "burger", 1, 1000), line("b", "fries", 1, 400), line("c", "drink", 1, 300)},
+			map[string]int64{"a": 294, "b": 118, "c": 88}, "",
+		},
+		{
+			"as many combos as the scarcest food allows",
+			combo(1200, "burger", "fries"),
+			[]PriceLine{line("a", "burger", 3, 1000), line("b", "fries", 2, 400)},
+			map[string]int64{"a": 286, "b": 114}, "",
+		},
+		{
+			"a food listed twice needs two units",
+			combo(1500, "burger", "burger", "fries"),
+			[]PriceLine{line("a", "burger", 1, 1000), line("b", "fries", 1, 400)},
+			nil, "does not contain the full meal deal",
+		},
+		{
+			"combo dearer than buying separately saves nothing",
+			combo(2000, "burger", "fries"),
+			[]PriceLine{line("a", "burger", 1, 1000), line("b", "fries", 1, 400)},
+			map[string]int64{}, "",
+		},
+		{
+			"incomplete combo",
+			combo(1200, "burger", "fries"),
+			[]PriceLine{line("a", "burger", 2, 1000)},
+			nil, "does not contain the full meal deal",
+		},
+		{
+			"below the minimum subtotal",
+			minimum,
+			[]PriceLine{line("a", "burger", 1, 1000), line("b", "fries", 1, 400)},
+			nil, "needs an order of at least 50.00",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			discounts, err := DiscountLines(test.promotion, test.lines)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error mentioning %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(discounts) != len(test.want) {
+				t.Fatalf("expected discounts %v, got %v", test.want, discounts)
+			}
+			for orderItemId, amount := range test.want {
+				if discounts[orderItemId].Amount != amount {
+					t.Fatalf("expected discounts %v, got %v", test.want, discounts)
+				}
+			}
+		})
+	}
+}
@@ -3,16 +3,14 @@ package helper
 import (
 	"context"
 	"fmt"
-	"golang-restaurant-management/database"
+	"golang-restaurant-management/config"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
 	"log"
-	"os"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type SignedDetails struct {
@@ -20,33 +18,46 @@ type SignedDetails struct {
 	First_name string
 	Last_name  string
 	Uid        string
+	Role       string
 	jwt.StandardClaims
 }
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
+var SECRET_KEY string = config.Settings.Auth.Secret_key
 
-var SECRET_KEY string = os.Getenv("SECRET_KEY")
+// Stream tickets let a browser EventSource, which cannot set headers, open the
+// kitchen stream. They carry their own audience so neither kind of token can
+// stand in for the other.
+const (
+	STREAM_TICKET_AUDIENCE = "kitchen_stream"
+	STREAM_TICKET_LIFETIME = time.Minute
+)
 
-func GenerateAllTokens(email string, firstName string, lastName string, uid string) (signedToken string, signedRefreshToken string, err error) {
+func GenerateAllTokens(email string, firstName string, lastName string, uid string, role string) (signedToken string, signedRefreshToken string, err error) {
 	claims := &SignedDetails{
 		Email:      email,
 		First_name: firstName,
 		Last_name:  lastName,
 		Uid:        uid,
+		Role:       role,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(24)).Unix(),
+			ExpiresAt: time.Now().Local().Add(config.Settings.Auth.Token_lifetime.Duration).Unix(),
 		},
 	}
 
 	refreshClaims := &SignedDetails{
+		Uid: uid,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(168)).Unix(),
+			ExpiresAt: time.Now().Local().Add(config.Settings.Auth.Refresh_token_lifetime.Duration).Unix(),
 		},
 	}
 
 	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(SECRET_KEY))
+	if err != nil {
+		log.Panic(err)
+		return
+	}
 
+	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(SECRET_KEY))
 	if err != nil {
 		log.Panic(err)
 		return
@@ -56,32 +67,33 @@ func GenerateAllTokens(email string, firstName string, lastName string, uid stri
 
 }
 
-func UpdateAllTokens(signedToken string, signedRefreshToken string, userId string) {
+func GenerateStreamTicket(uid string) (ticket string, expiresAt time.Time, err error) {
+	expiresAt = time.Now().Add(STREAM_TICKET_LIFETIME)
+	claims := &SignedDetails{
+		Uid: uid,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  STREAM_TICKET_AUDIENCE,
+			ExpiresAt: expiresAt.Unix(),
+		},
+	}
+
+	ticket, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+	return ticket, expiresAt, err
+}
+
+func UpdateAllTokens(users store.UserStore, signedToken string, signedRefreshToken string, userId string) {
 
 	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 
-	var updateObj primitive.D
+	updateObj := bson.M{}
 
-	updateObj = append(updateObj, bson.E{"token", signedToken})
-	updateObj = append(updateObj, bson.E{"refresh_token", signedRefreshToken})
+	updateObj["token"] = signedToken
+	updateObj["refresh_token"] = signedRefreshToken
 
 	Updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	updateObj = append(updateObj, bson.E{"updated_at", Updated_at})
+	updateObj["updated_at"] = Updated_at
 
-	upsert := true
-	filter := bson.M{"user_id": userId}
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
-	}
-
-	_, err := userCollection.UpdateOne(
-		ctx,
-		filter,
-		bson.D{
-			{"$set", updateObj},
-		},
-		&opt,
-	)
+	_, err := users.Update(ctx, userId, store.Patch{Set: updateObj, Upsert: true})
 	defer cancel()
 
 	if err != nil {
@@ -92,8 +104,20 @@ func UpdateAllTokens(signedToken string, signedRefreshToken string, userId strin
 
 }
 
-func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
+func RevokeAllTokens(users store.UserStore, userId string) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	Updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 
+	result, err := users.Update(ctx, userId, store.Patch{Set: bson.M{"token": "", "refresh_token": "", "updated_at": Updated_at}})
+	if err == nil && result.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+func parseToken(signedToken string) (claims *SignedDetails, msg string) {
 	token, err := jwt.ParseWithClaims(
 		signedToken,
 		&SignedDetails{},
@@ -103,21 +127,119 @@ func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
 	)
 
 	//the token is invalid
+	if err != nil {
+		msg = err.Error()
+		return
+	}
 
 	claims, ok := token.Claims.(*SignedDetails)
-	if !ok {
+	if !ok || !token.Valid {
 		msg = fmt.Sprintf("the token is invalid")
-		msg = err.Error()
 		return
 	}
 
 	//the token is expired
 	if claims.ExpiresAt < time.Now().Local().Unix() {
 		msg = fmt.Sprint("token is expired")
-		msg = err.Error()
 		return
 	}
 
 	return claims, msg
+}
+
+// A token is only honoured while it is the one stored on the user, so logging
+// in again, refreshing or logging out cuts off every older copy.
+func findTokenOwner(users store.UserStore, uid string) (user models.User, msg string) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	user, err := users.Get(ctx, uid)
+	if err != nil {
+		msg = fmt.Sprint("the token owner was not found")
+	}
+	return user, msg
+}
+
+func ValidateToken(users store.UserStore, signedToken string) (claims *SignedDetails, msg string) {
+	claims, msg = parseToken(signedToken)
+	if msg != "" {
+		return nil, msg
+	}
+
+	user, msg := findTokenOwner(users, claims.Uid)
+	if msg != "" {
+		return nil, msg
+	}
+
+	if user.Token == nil || *user.Token != signedToken {
+		return nil, fmt.Sprint("token has been revoked")
+	}
+
+	//the stored role wins so role changes apply without a new login
+	claims.Role = ""
+	if user.Role != nil {
+		claims.Role = *user.Role
+	}
+
+	return claims, msg
+}
+
+// ValidateStreamTicket honours a ticket only while its owner is still signed
+// in, and takes the role from the stored user like ValidateToken does.
+func ValidateStreamTicket(users store.UserStore, ticket string) (claims *SignedDetails, msg string) {
+	claims, msg = parseToken(ticket)
+	if msg != "" {
+		return nil, msg
+	}
+
+	if claims.Audience != STREAM_TICKET_AUDIENCE || claims.Uid == "" {
+		return nil, fmt.Sprint("the ticket is invalid")
+	}
+
+	user, msg := findTokenOwner(users, claims.Uid)
+	if msg != "" {
+		return nil, msg
+	}
+
+	if user.Token == nil || *user.Token == "" {
+		return nil, fmt.Sprint("ticket has been revoked")
+	}
+
+	if user.Email != nil {
+		claims.Email = *user.Email
+	}
+	if user.First_name != nil {
+		claims.First_name = *user.First_name
+	}
+	if user.Last_name != nil {
+		claims.Last_name = *user.Last_name
+	}
+	claims.Role = ""
+	if user.Role != nil {
+		claims.Role = *user.Role
+	}
+
+	return claims, msg
+}
+
+func ValidateRefreshToken(users store.UserStore, signedRefreshToken string) (user models.User, msg string) {
+	claims, msg := parseToken(signedRefreshToken)
+	if msg != "" {
+		return user, msg
+	}
+
+	if claims.Uid == "" {
+		return user, fmt.Sprint("the token is invalid")
+	}
+
+	user, msg = findTokenOwner(users, claims.Uid)
+	if msg != "" {
+		return user, msg
+	}
+
+	if user.Refresh_Token == nil || *user.Refresh_Token != signedRefreshToken {
+		return user, fmt.Sprint("refresh token has been revoked")
+	}
 
+	return user, msg
 }
@@ -0,0 +1,164 @@
+package helper
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func init() {
+	SECRET_KEY = "a-test-secret-that-is-long-enough"
+}
+
+func signedInUser(t *testing.T, users store.UserStore, role string) (models.User, string, string) {
+	t.Helper()
+	email := primitive.NewObjectID().Hex() + "@example.com"
+	user := models.User{ID: primitive.NewObjectID(), Email: &email, Role: &role}
+	user.User_id = user.ID.Hex()
+	if _, err := users.Insert(context.Background(), user); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	token, refreshToken, err := GenerateAllTokens(email, "Ada", "Lovelace", user.User_id, role)
+	if err != nil {
+		t.Fatalf("generate tokens: %v", err)
+	}
+	UpdateAllTokens(users, token, refreshToken, user.User_id)
+	return user, token, refreshToken
+}
+
+func TestValidateToken(t *testing.T) {
+	users := store.NewMemory().Users
+	user, token, refreshToken := signedInUser(t, users, "WAITER")
+	_, newer, _ := signedInUser(t, users, "WAITER")
+	other, _, _ := GenerateAllTokens("x@example.com", "X", "Y", primitive.NewObjectID().Hex(), "OWNER")
+	SECRET_KEY = "a-different-secret-that-is-long-enough"
+	forged, _, _ := GenerateAllTokens("x@example.com", "X", "Y", user.User_id, "OWNER")
+	SECRET_KEY = "a-test-secret-that-is-long-enough"
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"current token", token, ""},
+		{"refresh token used as access token", refreshToken, "token has been revoked"},
+		{"another user's token", newer, ""},
+		{"unknown user", other, "the token owner was not found"},
+		{"wrong signature", forged, "signature is invalid"},
+		{"garbage", "not-a-token", "token contains an invalid number of segments"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims, msg := ValidateToken(users, test.token)
+			if msg != test.want {
+				t.Fatalf("expected %q, got %q", test.want, msg)
+			}
+			if test.want == "" && claims == nil {
+				t.Fatal("expected claims for a valid token")
+			}
+		})
+	}
+}
+
+func TestValidateTokenUsesTheStoredRole(t *testing.T) {
+	users := store.NewMemory().Users
+	user, token, _ := signedInUser(t, users, "WAITER")
+
+	if _, err := users.Update(context.Background(), user.User_id, store.Patch{Set: map[string]interface{}{"role": "MANAGER"}}); err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+	claims, msg := ValidateToken(users, token)
+	if msg != "" || claims.Role != "MANAGER" {
+		t.Fatalf("expected the stored MANAGER role, got %v %q", claims, msg)
+	}
+}
+
+func TestRevokedTokensAreRefused(t *testing.T) {
+	users := store.NewMemory().Users
+	user, token, refreshToken := signedInUser(t, users, "WAITER")
+
+	if err := RevokeAllTokens(users, user.User_id); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, msg := ValidateToken(users, token); msg != "token has been revoked" {
+		t.Fatalf("expected the access token to be revoked, got %q", msg)
+	}
+	if _, msg := ValidateRefreshToken(users, refreshToken); msg != "refresh token has been revoked" {
+		t.Fatalf("expected the refresh token to be revoked, got %q", msg)
+	}
+	if err := RevokeAllTokens(users, primitive.NewObjectID().Hex()); err != store.ErrNotFound {
+		t.Fatalf("expected ErrNotFound for an unknown user, got %v", err)
+	}
+}
+
+func TestLoggingInAgainCutsOffTheOldToken(t *testing.T) {
+	users := store.NewMemory().Users
+	user, token, _ := signedInUser(t, users, "WAITER")
+
+	// a changed name keeps the new token distinct within the same second
+	fresh, freshRefresh, _ := GenerateAllTokens(*user.Email, "Augusta", "Lovelace", user.User_id, "WAITER")
+	UpdateAllTokens(users, fresh, freshRefresh, user.User_id)
+
+	if _, msg := ValidateToken(users, token); msg != "token has been revoked" {
+		t.Fatalf("expected the old token to be revoked, got %q", msg)
+	}
+	if _, msg := ValidateToken(users, fresh); msg != "" {
+		t.Fatalf("expected the new token to work, got %q", msg)
+	}
+}
+
+func TestStreamTicketsAndTokensAreNotInterchangeable(t *testing.T) {
+	users := store.NewMemory().Users
+	user, token, refreshToken := signedInUser(t, users, "KITCHEN")
+	ticket, _, err := GenerateStreamTicket(user.User_id)
+	if err != nil {
+		t.Fatalf("generate ticket: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		validate func(string) string
+		value    string
+		want     string
+	}{
+		{"ticket", streamTicketMessage(users), ticket, ""},
+		{"access token as ticket", streamTicketMessage(users), token, "the ticket is invalid"},
+		{"refresh token as ticket", streamTicketMessage(users), refreshToken, "the ticket is invalid"},
+		{"ticket as access token", accessTokenMessage(users), ticket, "token has been revoked"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if msg := test.validate(test.value); msg != test.want {
+				t.Fatalf("expected %q, got %q", test.want, msg)
+			}
+		})
+	}
+
+	claims, _ := ValidateStreamTicket(users, ticket)
+	if claims.Role != "KITCHEN" || claims.Email != *user.Email {
+		t.Fatalf("expected the stored user on the ticket claims, got %+v", claims)
+	}
+	if err := RevokeAllTokens(users, user.User_id); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, msg := ValidateStreamTicket(users, ticket); msg != "ticket has been revoked" {
+		t.Fatalf("expected the ticket to stop working after sign out, got %q", msg)
+	}
+}
+
+func streamTicketMessage(users store.UserStore) func(string) string {
+	return func(value string) string {
+		_, msg := ValidateStreamTicket(users, value)
+		return msg
+	}
+}
+
+func accessTokenMessage(users store.UserStore) func(string) string {
+	return func(value string) string {
+		_, msg := ValidateToken(users, value)
+		return msg
+	}
+}
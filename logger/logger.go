@@ -1,19 +1,52 @@
 package logger
 
 import (
-	"net"
+	"golang-restaurant-management/config"
+	"os"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 var Log *logrus.Logger
 
-func Init() {
+var sink *Sink
+
+// Init never fails: if Logstash is down the service still starts and logs to
+// the fallback until it comes back.
+func Init(settings config.LogConfig) {
 	Log = logrus.New()
-	conn, err := net.Dial("tcp", "logstash:5000")
+	Log.Formatter = &logrus.JSONFormatter{}
+
+	fallback, err := openFallback(settings.Fallback)
 	if err != nil {
-		Log.Fatalf("Failed to connect to Logstash: %v", err)
+		fallback = os.Stdout
 	}
-	Log.Out = conn
-	Log.Formatter = &logrus.JSONFormatter{}
+	sink = NewSink(settings.Address, fallback, settings.Queue_size)
+	Log.Out = sink
+
+	if err != nil {
+		Log.WithFields(logrus.Fields{
+			"event":    "log_fallback_error",
+			"time":     time.Now().Format(time.RFC3339),
+			"fallback": settings.Fallback,
+			"error":    err,
+		}).Warn("Log fallback file could not be opened, using stdout")
+	}
+}
+
+// Stats reports how the log sink is doing, for health checks and metrics.
+func Stats() SinkStats {
+	if sink == nil {
+		return SinkStats{}
+	}
+	return sink.Stats()
+}
+
+// Close ships the queued entries before the process exits.
+func Close() error {
+	if sink == nil {
+		return nil
+	}
+	return sink.Close(5 * time.Second)
 }
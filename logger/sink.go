@@ -0,0 +1,297 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	minBackoff   = time.Second
+	maxBackoff   = 30 * time.Second
+	dialTimeout  = 2 * time.Second
+	writeTimeout = 5 * time.Second
+	maxBatch     = 64 << 10
+)
+
+// Sink ships log entries to Logstash without ever holding up the request that
+// logged them. Entries wait in a bounded queue and are dropped, and counted,
+// when it is full. While Logstash cannot be reached they go to the fallback
+// writer instead, and the sink redials with exponential backoff.
+type Sink struct {
+	address  string
+	queue    chan []byte
+	done     chan struct{}
+	closing  sync.RWMutex
+	closed   bool
+	fallback io.Writer
+	// fallbackMu orders fallback writes from the shipper and from late
+	// entries that arrive after Close.
+	fallbackMu sync.Mutex
+
+	// owned by the shipping goroutine
+	conn          net.Conn
+	down          bool
+	backoff       time.Duration
+	nextDial      time.Time
+	batch         bytes.Buffer
+	batchEntries  uint64
+	reportedDrops uint64
+
+	connected atomic.Bool
+	shipped   atomic.Uint64
+	fellBack  atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+type SinkStats struct {
+	Address   string `json:"address"`
+	Connected bool   `json:"connected"`
+	Queued    int    `json:"queued"`
+	Shipped   uint64 `json:"shipped"`
+	Fallback  uint64 `json:"fallback"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+// NewSink starts shipping to address, or only to fallback when address is
+// empty. queueSize bounds how many entries may wait to be shipped.
+func NewSink(address string, fallback io.Writer, queueSize int) *Sink {
+	sink := &Sink{
+		address:  address,
+		queue:    make(chan []byte, queueSize),
+		done:     make(chan struct{}),
+		fallback: fallback,
+		backoff:  minBackoff,
+	}
+	go sink.run()
+	return sink
+}
+
+// Write queues one entry. logrus reuses its buffer, so the entry is copied.
+func (s *Sink) Write(p []byte) (int, error) {
+	entry := append([]byte(nil), p...)
+
+	s.closing.RLock()
+	defer s.closing.RUnlock()
+	if s.closed {
+		s.writeFallback(entry)
+		return len(p), nil
+	}
+
+	select {
+	case s.queue <- entry:
+	default:
+		s.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// Close ships what is still queued and hangs up, giving up after timeout.
+// Entries written afterwards go straight to the fallback.
+func (s *Sink) Close(timeout time.Duration) error {
+	s.closing.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.closing.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(timeout):
+		return errors.New("log sink: timed out shipping queued entries")
+	}
+}
+
+func (s *Sink) Stats() SinkStats {
+	return SinkStats{
+		Address:   s.address,
+		Connected: s.connected.Load(),
+		Queued:    len(s.queue),
+		Shipped:   s.shipped.Load(),
+		Fallback:  s.fellBack.Load(),
+		Dropped:   s.dropped.Load(),
+	}
+}
+
+func (s *Sink) run() {
+	defer close(s.done)
+
+	for entry := range s.queue {
+		s.add(entry)
+		// take whatever else is already waiting so it goes out in one write
+	drain:
+		for s.batch.Len() < maxBatch {
+			select {
+			case next, ok := <-s.queue:
+				if !ok {
+					break drain
+				}
+				s.add(next)
+			default:
+				break drain
+			}
+		}
+		s.flush()
+	}
+
+	s.flush()
+	if s.conn != nil {
+		s.conn.Close()
+		s.connected.Store(false)
+	}
+}
+
+func (s *Sink) add(entry []byte) {
+	s.batch.Write(entry)
+	s.batchEntries++
+}
+
+func (s *Sink) flush() {
+	if s.batch.Len() == 0 {
+		return
+	}
+	s.reportDrops()
+
+	if s.conn != nil && s.peerClosed() {
+		s.disconnect(errors.New("logstash closed the connection"))
+	}
+	if s.dial() {
+		s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		_, err := s.conn.Write(s.batch.Bytes())
+		if err == nil {
+			s.shipped.Add(s.batchEntries)
+			s.reset()
+			return
+		}
+		s.disconnect(err)
+	}
+
+	// a batch that failed part way is written out whole, a few entries may
+	// then appear in both places
+	s.writeFallback(s.batch.Bytes())
+	s.fellBack.Add(s.batchEntries)
+	s.reset()
+}
+
+func (s *Sink) reset() {
+	s.batch.Reset()
+	s.batchEntries = 0
+}
+
+// dial reports whether there is a connection to write to, redialing once the
+// backoff since the last failure has passed.
+func (s *Sink) dial() bool {
+	if s.conn != nil {
+		return true
+	}
+	if s.address == "" || time.Now().Before(s.nextDial) {
+		return false
+	}
+
+	conn, err := net.DialTimeout("tcp", s.address, dialTimeout)
+	if err != nil {
+		if !s.down {
+			s.down = true
+			s.notice("warning", "log_sink_unreachable", "Logstash is unreachable, writing logs to the fallback", map[string]interface{}{
+				"address": s.address,
+				"error":   err.Error(),
+			})
+		}
+		s.nextDial = time.Now().Add(s.backoff)
+		s.backoff = min(s.backoff*2, maxBackoff)
+		return false
+	}
+
+	s.conn = conn
+	s.backoff = minBackoff
+	s.connected.Store(true)
+	if s.down {
+		s.down = false
+		s.notice("info", "log_sink_connected", "Reconnected to Logstash", map[string]interface{}{"address": s.address})
+	}
+	return true
+}
+
+// peerClosed spots a connection Logstash has already hung up on, which would
+// otherwise swallow the next write without an error. Logstash never sends
+// anything, so a read that does not time out means the connection is gone.
+func (s *Sink) peerClosed() bool {
+	s.conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	var buf [1]byte
+	_, err := s.conn.Read(buf[:])
+	var netErr net.Error
+	return err != nil && !(errors.As(err, &netErr) && netErr.Timeout())
+}
+
+func (s *Sink) disconnect(err error) {
+	s.conn.Close()
+	s.conn = nil
+	s.connected.Store(false)
+	s.down = true
+	// Logstash may just have restarted, so the next batch tries straight away
+	s.nextDial = time.Time{}
+	s.notice("warning", "log_sink_disconnected", "Lost the connection to Logstash, writing logs to the fallback", map[string]interface{}{
+		"address": s.address,
+		"error":   err.Error(),
+	})
+}
+
+// reportDrops adds a summary of entries dropped since the last batch, so the
+// loss shows up in the same place as the logs.
+func (s *Sink) reportDrops() {
+	dropped := s.dropped.Load()
+	if dropped == s.reportedDrops {
+		return
+	}
+	s.batch.Write(formatEntry("warning", "log_entries_dropped", "Log queue was full, entries were dropped", map[string]interface{}{
+		"dropped":       dropped - s.reportedDrops,
+		"total_dropped": dropped,
+	}))
+	s.batchEntries++
+	s.reportedDrops = dropped
+}
+
+// notice tells whoever reads the fallback why logs are landing there.
+func (s *Sink) notice(level string, event string, msg string, fields map[string]interface{}) {
+	s.writeFallback(formatEntry(level, event, msg, fields))
+}
+
+func (s *Sink) writeFallback(p []byte) {
+	s.fallbackMu.Lock()
+	defer s.fallbackMu.Unlock()
+	s.fallback.Write(p)
+}
+
+// formatEntry writes a line the way logrus.JSONFormatter does.
+func formatEntry(level string, event string, msg string, fields map[string]interface{}) []byte {
+	line := map[string]interface{}{
+		"level": level,
+		"msg":   msg,
+		"event": event,
+		"time":  time.Now().Format(time.RFC3339),
+	}
+	for key, value := range fields {
+		line[key] = value
+	}
+	data, _ := json.Marshal(line)
+	return append(data, '\n')
+}
+
+// openFallback resolves "stdout", "stderr" or a file path, appending to files.
+func openFallback(target string) (io.Writer, error) {
+	switch target {
+	case "", "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	}
+	return os.OpenFile(target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
@@ -0,0 +1,201 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer is a fallback the test can read while the sink writes to it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// blockingWriter holds up the first write until released, so the queue
+// behind it can be filled.
+type blockingWriter struct {
+	lockedBuffer
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.entered) })
+	<-w.release
+	return w.lockedBuffer.Write(p)
+}
+
+func TestSinkShipsToLogstash(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		var lines []string
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		received <- lines
+	}()
+
+	fallback := &lockedBuffer{}
+	sink := NewSink(listener.Addr().String(), fallback, 10)
+	for _, entry := range []string{"one", "two", "three"} {
+		sink.Write([]byte(entry + "\n"))
+	}
+	if err := sink.Close(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := <-received
+	if strings.Join(lines, ",") != "one,two,three" {
+		t.Fatalf("expected the three entries in order, got %v", lines)
+	}
+	if stats := sink.Stats(); stats.Shipped != 3 || stats.Fallback != 0 || stats.Connected {
+		t.Fatalf("expected three shipped and a closed connection, got %+v", stats)
+	}
+	if fallback.String() != "" {
+		t.Fatalf("expected nothing on the fallback, got %q", fallback.String())
+	}
+}
+
+func TestSinkFallsBackWhenLogstashIsDown(t *testing.T) {
+	// a port nobody listens on any more
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	tests := []struct {
+		name       string
+		address    string
+		wantNotice bool
+	}{
+		{"unreachable", address, true},
+		{"no address", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fallback := &lockedBuffer{}
+			sink := NewSink(test.address, fallback, 10)
+			sink.Write([]byte("one\n"))
+			sink.Write([]byte("two\n"))
+			if err := sink.Close(5 * time.Second); err != nil {
+				t.Fatal(err)
+			}
+
+			written := fallback.String()
+			if !strings.Contains(written, "one\n") || !strings.Contains(written, "two\n") {
+				t.Fatalf("expected both entries on the fallback, got %q", written)
+			}
+			if strings.Contains(written, "log_sink_unreachable") != test.wantNotice {
+				t.Fatalf("expected an unreachable notice %v, got %q", test.wantNotice, written)
+			}
+			if stats := sink.Stats(); stats.Fallback != 2 || stats.Shipped != 0 {
+				t.Fatalf("expected two entries on the fallback, got %+v", stats)
+			}
+		})
+	}
+}
+
+func TestSinkDropsWhenTheQueueIsFull(t *testing.T) {
+	fallback := &blockingWriter{entered: make(chan struct{}), release: make(chan struct{})}
+	sink := NewSink("", fallback, 1)
+
+	// the shipper takes the first entry and blocks writing it out
+	sink.Write([]byte("first\n"))
+	<-fallback.entered
+	for _, entry := range []string{"queued", "dropped", "dropped", "dropped"} {
+		sink.Write([]byte(entry + "\n"))
+	}
+	if stats := sink.Stats(); stats.Dropped != 3 || stats.Queued != 1 {
+		t.Fatalf("expected one queued and three dropped, got %+v", stats)
+	}
+
+	close(fallback.release)
+	if err := sink.Close(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	written := fallback.String()
+	if !strings.Contains(written, "queued\n") || strings.Contains(written, "dropped\n") {
+		t.Fatalf("expected the queued entry but none of the dropped ones, got %q", written)
+	}
+	if !strings.Contains(written, `"event":"log_entries_dropped"`) || !strings.Contains(written, `"dropped":3`) {
+		t.Fatalf("expected a summary of the dropped entries, got %q", written)
+	}
+}
+
+func TestSinkWritesToTheFallbackAfterClose(t *testing.T) {
+	fallback := &lockedBuffer{}
+	sink := NewSink("", fallback, 10)
+	if err := sink.Close(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	sink.Write([]byte("late\n"))
+	if fallback.String() != "late\n" {
+		t.Fatalf("expected the late entry on the fallback, got %q", fallback.String())
+	}
+}
+
+func TestOpenFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	tests := []struct {
+		target string
+		want   *os.File
+	}{
+		{"", os.Stdout},
+		{"stdout", os.Stdout},
+		{"stderr", os.Stderr},
+	}
+	for _, test := range tests {
+		writer, err := openFallback(test.target)
+		if err != nil || writer != test.want {
+			t.Fatalf("%q: expected %s, got %v %v", test.target, test.want.Name(), writer, err)
+		}
+	}
+
+	for _, entry := range []string{"one\n", "two\n"} {
+		writer, err := openFallback(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(entry))
+		writer.(*os.File).Close()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "one\ntwo\n" {
+		t.Fatalf("expected the file to be appended to, got %q %v", data, err)
+	}
+}
@@ -1,37 +1,174 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
+	"golang-restaurant-management/config"
+	controller "golang-restaurant-management/controllers"
 	"golang-restaurant-management/database"
+	helper "golang-restaurant-management/helpers"
 	"golang-restaurant-management/logger"
+	"golang-restaurant-management/metrics"
 	"golang-restaurant-management/middleware"
 	"golang-restaurant-management/routes"
+	"golang-restaurant-management/store"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
-	"go.mongodb.org/mongo-driver/mongo"
-)
-
-var (
-	foodCollection *mongo.Collection = database.OpenCollection(database.Client, "food")
 )
 
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8000"
+	settings := config.Settings
+	port := strconv.Itoa(settings.Port)
+
+	logger.Init(settings.Log)
+	defer logger.Close()
+
+	if err := settings.RequireSecrets(); err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"event": "config_error",
+			"time":  time.Now().Format(time.RFC3339),
+			"error": err,
+		}).Error("Refusing to start without a usable secret key")
+		logger.Close()
+		os.Exit(1)
+	}
+
+	client, err := database.Connect(settings.Mongo.Connect_timeout.Duration, func(attempt int, err error) {
+		logger.Log.WithFields(logrus.Fields{
+			"event":   "mongo_connect_retry",
+			"time":    time.Now().Format(time.RFC3339),
+			"attempt": attempt,
+			"error":   err,
+		}).Warn("MongoDB is not reachable yet, retrying")
+	})
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"event": "mongo_connect_error",
+			"time":  time.Now().Format(time.RFC3339),
+			"error": err,
+		}).Error("Could not connect to MongoDB, giving up")
+		logger.Close()
+		os.Exit(1)
+	}
+	logger.Log.WithFields(logrus.Fields{
+		"event": "mongo_connected",
+		"time":  time.Now().Format(time.RFC3339),
+	}).Info("Connected to MongoDB")
+
+	migrated, err := database.MigrateMoneyFields(client)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"event": "money_migration_error",
+			"time":  time.Now().Format(time.RFC3339),
+			"error": err,
+		}).Error("Error occurred while migrating money fields")
+	}
+	for _, result := range migrated {
+		if result.Modified > 0 {
+			logger.Log.WithFields(logrus.Fields{
+				"event":      "money_migration_success",
+				"time":       time.Now().Format(time.RFC3339),
+				"collection": result.Collection,
+				"field":      result.Field,
+				"modified":   result.Modified,
+			}).Info("Migrated money field to minor units")
+		}
+	}
+
+	foreign, err := database.ForeignCurrencyFields(client, settings.Pricing.Currency)
+	if err != nil || len(foreign) > 0 {
+		logger.Log.WithFields(logrus.Fields{
+			"event":    "currency_mismatch",
+			"time":     time.Now().Format(time.RFC3339),
+			"currency": settings.Pricing.Currency,
+			"fields":   foreign,
+			"error":    err,
+		}).Error("Refusing to start: stored amounts are not all in the configured currency")
+		logger.Close()
+		os.Exit(1)
+	}
+
+	resized, err := database.MigrateOrderItemQuantities(client, helper.Pricing.SizeMultiplier)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"event": "quantity_migration_error",
+			"time":  time.Now().Format(time.RFC3339),
+			"error": err,
+		}).Error("Error occurred while migrating order item quantities")
+	}
+	if resized > 0 {
+		logger.Log.WithFields(logrus.Fields{
+			"event":    "quantity_migration_success",
+			"time":     time.Now().Format(time.RFC3339),
+			"modified": resized,
+		}).Info("Moved order item sizes out of quantity")
+	}
+
+	closed, placed, err := database.MigrateOrderStatuses(client)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"event": "order_status_migration_error",
+			"time":  time.Now().Format(time.RFC3339),
+			"error": err,
+		}).Error("Error occurred while migrating order statuses")
+	}
+	if closed > 0 || placed > 0 {
+		logger.Log.WithFields(logrus.Fields{
+			"event":  "order_status_migration_success",
+			"time":   time.Now().Format(time.RFC3339),
+			"closed": closed,
+			"placed": placed,
+		}).Info("Gave orders without a status one")
+	}
+
+	backfilled, promoted, err := database.MigrateUserRoles(client, settings.Auth.Owner_email)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"event": "role_migration_error",
+			"time":  time.Now().Format(time.RFC3339),
+			"error": err,
+		}).Error("Error occurred while migrating user roles")
+	}
+	if backfilled > 0 {
+		logger.Log.WithFields(logrus.Fields{
+			"event":    "role_migration_success",
+			"time":     time.Now().Format(time.RFC3339),
+			"modified": backfilled,
+		}).Info("Gave users without a role the waiter role")
+	}
+	if promoted {
+		logger.Log.WithFields(logrus.Fields{
+			"event": "owner_seeded",
+			"time":  time.Now().Format(time.RFC3339),
+			"email": settings.Auth.Owner_email,
+		}).Info("Made the configured owner email OWNER")
+	}
+
+	if err := database.EnsureIndexes(client); err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"event": "index_error",
+			"time":  time.Now().Format(time.RFC3339),
+			"error": err,
+		}).Error("Error occurred while creating indexes")
 	}
 
-	logger.Init()
+	stores := store.NewMongo(client)
 
 	router := gin.New()
 	router.Use(gin.LoggerWithWriter(logger.Log.Out))
+	router.Use(metrics.Middleware())
 	router.Use(gin.RecoveryWithWriter(logger.Log.Out))
 
  	router.Use(cors.New(cors.Config{
-        AllowOrigins:     []string{"http://localhost:5173"}, 
+        AllowOrigins:     settings.Cors.Origins,
         AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
         AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
         ExposeHeaders:    []string{"Content-Length"},
@@ -39,20 +176,91 @@ func main() {
         MaxAge:           12 * time.Hour,
     }))
 
-	routes.UserRoutes(router)
-	router.Use(middleware.Authentication())
+	routes.HealthRoutes(router, client)
+	routes.MetricsRoutes(router)
+	routes.UserRoutes(router, stores)
+	routes.KitchenStreamRoutes(router, stores)
+	router.Use(middleware.Authentication(stores.Users))
 
-	routes.FoodRoutes(router)
-	routes.MenuRoutes(router)
-	routes.TableRoutes(router)
-	routes.OrderRoutes(router)
-	routes.OrderItemRoutes(router)
-	routes.InvoiceRoutes(router)
+	routes.FoodRoutes(router, stores)
+	routes.MenuRoutes(router, stores)
+	routes.TableRoutes(router, stores)
+	routes.OrderRoutes(router, stores)
+	routes.OrderItemRoutes(router, stores)
+	routes.InvoiceRoutes(router, stores)
+	routes.KitchenRoutes(router, stores)
+	routes.ReservationRoutes(router, stores)
+	routes.WaitlistRoutes(router, stores)
+	routes.FloorRoutes(router, stores)
+	routes.InventoryRoutes(router, stores)
+	routes.SupplierRoutes(router, stores)
+	routes.ReportRoutes(router, stores)
+	routes.PricingRuleRoutes(router, stores)
+	routes.PromotionRoutes(router, stores)
+
+	server := &http.Server{Addr: ":" + port, Handler: router}
+	server.RegisterOnShutdown(controller.CloseKitchenStreams)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
 
 	logger.Log.WithFields(logrus.Fields{
 		"event": "application_start",
 		"time":  time.Now().Format(time.RFC3339),
+		"port":  port,
 	}).Info("Application started")
 
-	router.Run(":" + port)
+	stop, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	select {
+	case err := <-serverErr:
+		logger.Log.WithFields(logrus.Fields{
+			"event": "server_error",
+			"time":  time.Now().Format(time.RFC3339),
+			"error": err,
+		}).Error("HTTP server stopped unexpectedly")
+	case <-stop.Done():
+		shutdown(server, settings.Drain_delay.Duration, settings.Shutdown_timeout.Duration)
+	}
+
+	if err := database.Disconnect(client, 5*time.Second); err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"event": "mongo_disconnect_error",
+			"time":  time.Now().Format(time.RFC3339),
+			"error": err,
+		}).Error("Error occurred while disconnecting from MongoDB")
+	}
+	logger.Log.WithFields(logrus.Fields{
+		"event": "application_stop",
+		"time":  time.Now().Format(time.RFC3339),
+	}).Info("Application stopped")
+}
+
+// shutdown fails readiness, keeps serving for drainDelay so the load balancer
+// takes the instance out first, then stops accepting connections and waits up
+// to timeout for the requests in flight to finish.
+func shutdown(server *http.Server, drainDelay time.Duration, timeout time.Duration) {
+	logger.Log.WithFields(logrus.Fields{
+		"event":       "shutdown_started",
+		"time":        time.Now().Format(time.RFC3339),
+		"drain_delay": drainDelay.String(),
+		"timeout":     timeout.String(),
+	}).Info("Shutting down, draining in-flight requests")
+
+	controller.StartDraining()
+	time.Sleep(drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Log.WithFields(logrus.Fields{
+			"event": "shutdown_error",
+			"time":  time.Now().Format(time.RFC3339),
+			"error": err,
+		}).Error("Requests were still running when the shutdown timeout passed")
+	}
 }
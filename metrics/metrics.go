@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"golang-restaurant-management/logger"
+	"golang-restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "restaurant"
+
+var (
+	ordersCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_created_total",
+		Help:      "Orders created, directly or when items were ordered for a table.",
+	})
+	orderItemsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "order_items_created_total",
+		Help:      "Order items sent to the kitchen.",
+	})
+	invoicesPaid = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "invoices_paid_total",
+		Help:      "Invoices that became fully paid.",
+	})
+	paymentsRecorded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payments_recorded_total",
+		Help:      "Payments recorded against invoices, by method.",
+	}, []string{"method"})
+	revenue = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revenue_total",
+		Help:      "Money taken in payments, excluding tips, in major units.",
+	}, []string{"currency"})
+	tips = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tips_total",
+		Help:      "Tips taken with payments, in major units.",
+	}, []string{"currency"})
+)
+
+func init() {
+	// the sink keeps its own counts, so they are read when scraped
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "log_sink_connected",
+		Help:      "1 while connected to Logstash.",
+	}, func() float64 {
+		if logger.Stats().Connected {
+			return 1
+		}
+		return 0
+	})
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "log_sink_queued",
+		Help:      "Log entries waiting to be shipped.",
+	}, func() float64 { return float64(logger.Stats().Queued) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "log_entries_shipped_total",
+		Help:      "Log entries shipped to Logstash.",
+	}, func() float64 { return float64(logger.Stats().Shipped) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "log_entries_fallback_total",
+		Help:      "Log entries written to the fallback instead of Logstash.",
+	}, func() float64 { return float64(logger.Stats().Fallback) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "log_entries_dropped_total",
+		Help:      "Log entries dropped because the queue was full.",
+	}, func() float64 { return float64(logger.Stats().Dropped) })
+}
+
+// Handler serves everything registered with the default registry, including
+// the Go runtime and process collectors.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+func OrderCreated() {
+	ordersCreated.Inc()
+}
+
+func OrderItemsCreated(count int) {
+	orderItemsCreated.Add(float64(count))
+}
+
+func InvoicePaid() {
+	invoicesPaid.Inc()
+}
+
+// PaymentRecorded counts the payment and adds it to revenue, keeping the tip
+// apart so revenue matches the invoice totals.
+func PaymentRecorded(method string, amount models.Money, tip models.Money) {
+	paymentsRecorded.WithLabelValues(method).Inc()
+	revenue.WithLabelValues(currencyOf(amount)).Add(majorUnits(amount))
+	if !tip.IsZero() {
+		tips.WithLabelValues(currencyOf(tip)).Add(majorUnits(tip))
+	}
+}
+
+func currencyOf(amount models.Money) string {
+	if amount.Currency == "" {
+		return models.DEFAULT_CURRENCY
+	}
+	return amount.Currency
+}
+
+// majorUnits is only for reporting; prometheus counters are floats and must
+// not go down, so refunds are not subtracted here.
+func majorUnits(amount models.Money) float64 {
+	if amount.Amount < 0 {
+		return 0
+	}
+	return float64(amount.Amount) / models.MINOR_UNITS
+}
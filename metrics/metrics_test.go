@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang-restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestMiddlewareLabelsByRoutePattern(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/orders/:order_id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/orders/:order_id", func(c *gin.Context) { c.Status(http.StatusConflict) })
+
+	tests := []struct {
+		method string
+		path   string
+		route  string
+		status string
+	}{
+		{http.MethodGet, "/orders/abc", "/orders/:order_id", "200"},
+		{http.MethodGet, "/orders/def", "/orders/:order_id", "200"},
+		{http.MethodPost, "/orders/abc", "/orders/:order_id", "409"},
+		{http.MethodGet, "/nowhere/abc", "unmatched", "404"},
+	}
+	before := map[[3]string]float64{}
+	for _, test := range tests {
+		key := [3]string{test.method, test.route, test.status}
+		before[key] = testutil.ToFloat64(httpRequests.WithLabelValues(key[:]...))
+	}
+	for _, test := range tests {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(test.method, test.path, nil))
+	}
+
+	want := map[[3]string]float64{}
+	for _, test := range tests {
+		want[[3]string{test.method, test.route, test.status}]++
+	}
+	for key, count := range want {
+		if got := testutil.ToFloat64(httpRequests.WithLabelValues(key[:]...)) - before[key]; got != count {
+			t.Errorf("%v: expected %v requests, got %v", key, count, got)
+		}
+	}
+	// ids never become series of their own
+	if httpRequests.DeleteLabelValues(http.MethodGet, "/orders/abc", "200") {
+		t.Error("expected no series for the raw path")
+	}
+	if got := testutil.ToFloat64(httpInFlight); got != 0 {
+		t.Errorf("expected nothing in flight, got %v", got)
+	}
+}
+
+func TestPaymentRecordedLabels(t *testing.T) {
+	foreign := models.Money{Amount: 500, Currency: "XTS"}
+	tests := []struct {
+		name        string
+		method      string
+		amount, tip models.Money
+		currency    string
+		wantRevenue float64
+		wantTip     float64
+	}{
+		{"with a tip", "CARD", models.NewMoney(1250), models.NewMoney(200), models.DEFAULT_CURRENCY, 12.5, 2},
+		{"without a tip", "CASH", models.NewMoney(1000), models.NewMoney(0), models.DEFAULT_CURRENCY, 10, 0},
+		{"no currency is the default one", "CASH", models.Money{Amount: 100}, models.Money{}, models.DEFAULT_CURRENCY, 1, 0},
+		{"refunds do not lower revenue", "CARD", models.NewMoney(-500), models.NewMoney(0), models.DEFAULT_CURRENCY, 0, 0},
+		{"stored currency", "CARD", foreign, models.Money{Amount: 50, Currency: "XTS"}, "XTS", 5, 0.5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payments := testutil.ToFloat64(paymentsRecorded.WithLabelValues(test.method))
+			revenueBefore := testutil.ToFloat64(revenue.WithLabelValues(test.currency))
+			tipsBefore := testutil.ToFloat64(tips.WithLabelValues(test.currency))
+
+			PaymentRecorded(test.method, test.amount, test.tip)
+
+			if got := testutil.ToFloat64(paymentsRecorded.WithLabelValues(test.method)) - payments; got != 1 {
+				t.Errorf("expected one %s payment, got %v", test.method, got)
+			}
+			if got := testutil.ToFloat64(revenue.WithLabelValues(test.currency)) - revenueBefore; got != test.wantRevenue {
+				t.Errorf("expected %v revenue, got %v", test.wantRevenue, got)
+			}
+			if got := testutil.ToFloat64(tips.WithLabelValues(test.currency)) - tipsBefore; got != test.wantTip {
+				t.Errorf("expected %v in tips, got %v", test.wantTip, got)
+			}
+		})
+	}
+}
+
+func TestMongoMonitorLabels(t *testing.T) {
+	monitor := MongoMonitor()
+	command := func(doc bson.D) bson.Raw {
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	tests := []struct {
+		name       string
+		command    bson.Raw
+		commandId  string
+		failed     bool
+		collection string
+		outcome    string
+	}{
+		{"find on a collection", command(bson.D{{Key: "find", Value: "order"}}), "find", false, "order", "success"},
+		{"failed update", command(bson.D{{Key: "update", Value: "table"}}), "update", true, "table", "error"},
+		{"admin command", command(bson.D{{Key: "ping", Value: 1}}), "ping", false, "", "success"},
+		{"blanked out command", bson.Raw{}, "saslStart", false, "", "success"},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mongoDuration.DeleteLabelValues(test.commandId, test.collection, test.outcome)
+			finished := event.CommandFinishedEvent{CommandName: test.commandId, RequestID: int64(i), ConnectionID: "conn-1", Duration: time.Millisecond}
+
+			monitor.Started(context.Background(), &event.CommandStartedEvent{Command: test.command, CommandName: test.commandId, RequestID: int64(i), ConnectionID: "conn-1"})
+			if test.failed {
+				monitor.Failed(context.Background(), &event.CommandFailedEvent{CommandFinishedEvent: finished})
+			} else {
+				monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: finished})
+			}
+
+			if !mongoDuration.DeleteLabelValues(test.commandId, test.collection, test.outcome) {
+				t.Fatalf("expected a %s/%q/%s observation", test.commandId, test.collection, test.outcome)
+			}
+		})
+	}
+}
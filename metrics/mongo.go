@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var mongoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "mongo_command_duration_seconds",
+	Help:      "Time taken by MongoDB commands, by command, collection and outcome.",
+	Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+}, []string{"command", "collection", "outcome"})
+
+// MongoMonitor times every command the driver sends. The collection is only
+// on the started event, so it is held until the command finishes.
+func MongoMonitor() *event.CommandMonitor {
+	var collections sync.Map
+
+	key := func(connectionID string, requestID int64) string {
+		return connectionID + "/" + strconv.FormatInt(requestID, 10)
+	}
+	finish := func(evt event.CommandFinishedEvent, outcome string) {
+		collection := ""
+		if value, ok := collections.LoadAndDelete(key(evt.ConnectionID, evt.RequestID)); ok {
+			collection = value.(string)
+		}
+		mongoDuration.WithLabelValues(evt.CommandName, collection, outcome).Observe(evt.Duration.Seconds())
+	}
+
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, evt *event.CommandStartedEvent) {
+			// commands name their collection as the value of the first field,
+			// e.g. {find: "order", ...}; admin commands such as ping have none
+			// and the driver blanks out authentication commands
+			collection := ""
+			if first, err := evt.Command.IndexErr(0); err == nil {
+				collection, _ = first.Value().StringValueOK()
+			}
+			collections.Store(key(evt.ConnectionID, evt.RequestID), collection)
+		},
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			finish(evt.CommandFinishedEvent, "success")
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			finish(evt.CommandFinishedEvent, "error")
+		},
+	}
+}
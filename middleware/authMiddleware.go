@@ -3,12 +3,13 @@ package middleware
 import (
 	"fmt"
 	helper "golang-restaurant-management/helpers"
+	"golang-restaurant-management/store"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
-func Authentication() gin.HandlerFunc {
+func Authentication(users store.UserStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		clientToken := c.Request.Header.Get("token")
 		if clientToken == "" {
@@ -17,18 +18,46 @@ func Authentication() gin.HandlerFunc {
 			return
 		}
 
-		claims, err := helper.ValidateToken(clientToken)
+		claims, err := helper.ValidateToken(users, clientToken)
 		if err != "" {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err})
 			c.Abort()
 			return
 		}
 
-		c.Set("email", claims.Email)
-		c.Set("first_name", claims.First_name)
-		c.Set("last_name", claims.Last_name)
-		c.Set("uid", claims.Uid)
+		setClaims(c, claims)
+		c.Next()
+	}
+}
+
+// StreamAuthentication also takes a stream ticket from the ticket query
+// parameter, for browsers whose EventSource cannot send the token header.
+func StreamAuthentication(users store.UserStore) gin.HandlerFunc {
+	authenticate := Authentication(users)
+
+	return func(c *gin.Context) {
+		ticket := c.Query("ticket")
+		if ticket == "" || c.Request.Header.Get("token") != "" {
+			authenticate(c)
+			return
+		}
 
+		claims, err := helper.ValidateStreamTicket(users, ticket)
+		if err != "" {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+			c.Abort()
+			return
+		}
+
+		setClaims(c, claims)
 		c.Next()
 	}
 }
+
+func setClaims(c *gin.Context, claims *helper.SignedDetails) {
+	c.Set("email", claims.Email)
+	c.Set("first_name", claims.First_name)
+	c.Set("last_name", claims.Last_name)
+	c.Set("uid", claims.Uid)
+	c.Set("role", claims.Role)
+}
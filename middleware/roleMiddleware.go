@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	appLogger "golang-restaurant-management/logger"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Authorize must run after Authentication, which puts the caller's role on the context.
+func Authorize(roles ...string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		allowed[role] = true
+	}
+
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		if !allowed[role] {
+			appLogger.Log.WithFields(logrus.Fields{
+				"event":  "authorization_denied",
+				"time":   time.Now().Format(time.RFC3339),
+				"uid":    c.GetString("uid"),
+				"role":   role,
+				"method": c.Request.Method,
+				"path":   c.FullPath(),
+			}).Warn("Role is not allowed to access this route")
+			c.JSON(http.StatusForbidden, gin.H{"error": "you are not allowed to perform this action"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
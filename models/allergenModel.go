@@ -0,0 +1,69 @@
+package models
+
+import "strings"
+
+const (
+	ALLERGY_MILD   = "MILD"
+	ALLERGY_SEVERE = "SEVERE"
+)
+
+// A MILD declaration warns the server when an item contains the allergen; a
+// SEVERE one refuses to send the item to the kitchen at all.
+type AllergyDeclaration struct {
+	Allergen *string `json:"allergen" validate:"required,min=1,max=50"`
+	Severity *string `json:"severity" validate:"required,eq=MILD|eq=SEVERE"`
+	Guest    *string `json:"guest"`
+	Notes    *string `json:"notes"`
+}
+
+// NormalizeTags upper-cases tags and drops blanks and duplicates, so "Nuts"
+// and "NUTS " match the same allergen.
+func NormalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToUpper(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+func NormalizeAllergies(declarations []AllergyDeclaration) {
+	for i := range declarations {
+		allergen := strings.ToUpper(strings.TrimSpace(*declarations[i].Allergen))
+		declarations[i].Allergen = &allergen
+	}
+}
+
+// AllergensFor lists what the food contains once the chosen modifiers are
+// added, e.g. extra cheese bringing in DAIRY.
+func (food Food) AllergensFor(modifiers []SelectedModifier) []string {
+	allergens := append([]string{}, food.Allergens...)
+	for _, modifier := range modifiers {
+		_, option, err := food.findModifier(modifier.Group_id, modifier.Option_id)
+		if err == nil {
+			allergens = append(allergens, option.Allergens...)
+		}
+	}
+	return NormalizeTags(allergens)
+}
+
+func ConflictingAllergies(allergens []string, declarations []AllergyDeclaration) []AllergyDeclaration {
+	var conflicts []AllergyDeclaration
+	for _, declaration := range declarations {
+		for _, allergen := range allergens {
+			if allergen == *declaration.Allergen {
+				conflicts = append(conflicts, declaration)
+				break
+			}
+		}
+	}
+	return conflicts
+}
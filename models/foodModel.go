@@ -1,6 +1,8 @@
 package models
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -9,10 +11,49 @@ import (
 type Food struct {
 	ID         primitive.ObjectID `bson:"_id"`
 	Name       *string            `json:"name" validate:"required,min=2,max=100"`
-	Price      *float64           `json:"price" validate:"required"`
+	Price      *Money             `json:"price" validate:"required"`
 	Food_image *string            `json:"food_image" validate:"required"`
 	Created_at time.Time          `json:"created_at"`
 	Updated_at time.Time          `json:"updated_at"`
 	Food_id    string             `json:"food_id"`
 	Menu_id    *string            `json:"menu_id" validate:"required"`
+	Station    *string            `json:"station"`
+
+	// Size_prices replaces the size multipliers for foods sold in fixed sizes, e.g. {"S": 4.00, "L": 6.50}
+	Size_prices     map[string]Money `json:"size_prices"`
+	Modifier_groups []ModifierGroup  `json:"modifier_groups" validate:"dive"`
+	Allergens       []string         `json:"allergens"`
+	Dietary_tags    []string         `json:"dietary_tags"`
+	Recipe          []RecipeLine     `json:"recipe" validate:"dive"`
+
+	// Available is cleared when the item is 86'd; Remaining_portions, when
+	// set, counts down as the item is ordered and 86s it at zero.
+	Available          *bool      `json:"available"`
+	Remaining_portions *int       `json:"remaining_portions"`
+	Unavailable_reason *string    `json:"unavailable_reason"`
+	Unavailable_since  *time.Time `json:"unavailable_since"`
+}
+
+// Foods saved before availability was tracked have no flag and are on sale.
+func (food Food) IsAvailable() bool {
+	if food.Available != nil && !*food.Available {
+		return false
+	}
+	return food.Remaining_portions == nil || *food.Remaining_portions > 0
+}
+
+// NormalizeSizePrices upper-cases the size names so "l" and "L" are the same size.
+func (food *Food) NormalizeSizePrices() error {
+	if food.Size_prices == nil {
+		return nil
+	}
+	normalized := map[string]Money{}
+	for size, price := range food.Size_prices {
+		if price.Amount < 0 {
+			return fmt.Errorf("price for size %s cannot be negative", size)
+		}
+		normalized[strings.ToUpper(strings.TrimSpace(size))] = price
+	}
+	food.Size_prices = normalized
+	return nil
 }
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	STOCK_DEPLETION  = "DEPLETION"
+	STOCK_REVERSAL   = "REVERSAL"
+	STOCK_ADJUSTMENT = "ADJUSTMENT"
+	STOCK_RECEIPT    = "RECEIPT"
+)
+
+// Low_stock is raised once when stock falls to the reorder point and cleared
+// when the ingredient is restocked above it. Unit_cost is what the last
+// delivery was bought at.
+type Ingredient struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	Ingredient_id    string             `json:"ingredient_id"`
+	Name             *string            `json:"name" validate:"required,min=2,max=100"`
+	Unit             *string            `json:"unit" validate:"required,min=1,max=20"`
+	Stock            float64            `json:"stock"`
+	Reorder_point    *float64           `json:"reorder_point" validate:"omitempty,min=0"`
+	Reorder_quantity *float64           `json:"reorder_quantity" validate:"omitempty,gt=0"`
+	Low_stock        bool               `json:"low_stock"`
+	Supplier_id      *string            `json:"supplier_id"`
+	Unit_cost        *Money             `json:"unit_cost"`
+	Created_at       time.Time          `json:"created_at"`
+	Updated_at       time.Time          `json:"updated_at"`
+}
+
+// RecipeLine is how much of an ingredient, in the ingredient's unit, goes
+// into one portion of a food.
+type RecipeLine struct {
+	Ingredient_id string  `json:"ingredient_id" validate:"required"`
+	Quantity      float64 `json:"quantity" validate:"required,gt=0"`
+}
+
+// StockMovement is one change to an ingredient's stock. Depletions keep the
+// order they came from so a void can put exactly that much back.
+type StockMovement struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Movement_id   string             `json:"movement_id"`
+	Ingredient_id string             `json:"ingredient_id"`
+	Change        float64            `json:"change"`
+	Reason        string             `json:"reason"`
+	Note          string             `json:"note,omitempty"`
+	Order_id      string             `json:"order_id,omitempty"`
+	Order_item_id string             `json:"order_item_id,omitempty"`
+	Reversed      bool               `json:"reversed"`
+	Created_by    string             `json:"created_by"`
+	Created_at    time.Time          `json:"created_at"`
+}
@@ -11,8 +11,20 @@ type Invoice struct {
 	Invoice_id       string             `json:"invoice_id"`
 	Order_id         string             `json:"order_id"`
 	Payment_method   *string            `json:"payment_method" validate:"eq=CARD|eq=CASH|eq="`
-	Payment_status   *string            `json:"payment_status" validate:"required,eq=PENDING|eq=PAID"`
+	Payment_status   *string            `json:"payment_status" validate:"required,eq=PENDING|eq=PARTIALLY_PAID|eq=PAID"`
 	Payment_due_date time.Time          `json:"Payment_due_date"`
+	Subtotal         *Money             `json:"subtotal"`
+	Discount         *Money             `json:"discount"`
+	Discounts        []InvoiceDiscount  `json:"discounts"`
+	Promo_codes      []string           `json:"promo_codes" bson:"-"`
+	Service_charge   *Money             `json:"service_charge"`
+	Tax              *Money             `json:"tax"`
+	Tip              *Money             `json:"tip"`
+	Total            *Money             `json:"total"`
+	Amount_paid      *Money             `json:"amount_paid"`
+	Tips_collected   *Money             `json:"tips_collected"`
+	Paid_items       []string           `json:"paid_items"`
 	Created_at       time.Time          `json:"created_at"`
 	Updated_at       time.Time          `json:"updated_at"`
+	Version          int64              `json:"version"`
 }
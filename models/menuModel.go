@@ -15,4 +15,32 @@ type Menu struct {
 	Created_at time.Time          `json:"created_at"`
 	Updated_at time.Time          `json:"updated_at"`
 	Menu_id    string             `json:"food_id"`
+
+	// Dayparts limit the menu to recurring windows, e.g. breakfast 07:00-11:00
+	// on weekdays. A menu without dayparts is served all day.
+	Dayparts  []TimeWindow       `json:"dayparts" validate:"dive"`
+	Overrides []ScheduleOverride `json:"overrides" validate:"dive"`
+}
+
+// IsActive reports whether the menu can be ordered from at the given time:
+// inside its date range, and inside a daypart or that date's override.
+func (menu Menu) IsActive(at time.Time) bool {
+	if menu.Start_Date != nil && at.Before(*menu.Start_Date) {
+		return false
+	}
+	if menu.End_Date != nil && !at.Before(*menu.End_Date) {
+		return false
+	}
+
+	date := at.In(RESTAURANT_LOCATION).Format("2006-01-02")
+	for _, override := range menu.Overrides {
+		if override.Date != date {
+			continue
+		}
+		if override.Closed {
+			return false
+		}
+		return AnyWindowContains(override.Windows, at)
+	}
+	return AnyWindowContains(menu.Dayparts, at)
 }
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ModifierOption struct {
+	Option_id   string   `json:"option_id"`
+	Name        *string  `json:"name" validate:"required,min=1,max=100"`
+	Price_delta *Money   `json:"price_delta"`
+	Allergens   []string `json:"allergens"`
+}
+
+// Max_selections of 0 leaves the group unlimited. A required group must have
+// at least one selection even when Min_selections is left at 0.
+type ModifierGroup struct {
+	Group_id       string           `json:"group_id"`
+	Name           *string          `json:"name" validate:"required,min=1,max=100"`
+	Required       bool             `json:"required"`
+	Min_selections int              `json:"min_selections" validate:"min=0"`
+	Max_selections int              `json:"max_selections" validate:"min=0"`
+	Options        []ModifierOption `json:"options" validate:"required,min=1,dive"`
+}
+
+// SelectedModifier is sent as a group_id and option_id; the names and price
+// delta are copied from the food when the item is ordered, like Unit_price.
+type SelectedModifier struct {
+	Group_id    string `json:"group_id" validate:"required"`
+	Option_id   string `json:"option_id" validate:"required"`
+	Group_name  string `json:"group_name"`
+	Option_name string `json:"option_name"`
+	Price_delta Money  `json:"price_delta"`
+}
+
+func (group ModifierGroup) minSelections() int {
+	if group.Required && group.Min_selections < 1 {
+		return 1
+	}
+	return group.Min_selections
+}
+
+// PrepareModifierGroups fills in missing ids and checks the selection bounds
+// make sense before the groups are saved on a food.
+func PrepareModifierGroups(groups []ModifierGroup) error {
+	for i := range groups {
+		group := &groups[i]
+		if group.Group_id == "" {
+			group.Group_id = primitive.NewObjectID().Hex()
+		}
+		if group.Max_selections > 0 && group.Max_selections < group.minSelections() {
+			return fmt.Errorf("modifier group %q allows fewer selections than it requires", *group.Name)
+		}
+		if group.minSelections() > len(group.Options) {
+			return fmt.Errorf("modifier group %q requires more selections than it has options", *group.Name)
+		}
+		for j := range group.Options {
+			if group.Options[j].Option_id == "" {
+				group.Options[j].Option_id = primitive.NewObjectID().Hex()
+			}
+			group.Options[j].Allergens = NormalizeTags(group.Options[j].Allergens)
+		}
+	}
+	return nil
+}
+
+// ResolveModifiers checks the selections against the food's groups and
+// returns them with names and prices filled in.
+func (food Food) ResolveModifiers(selected []SelectedModifier) ([]SelectedModifier, error) {
+	resolved := make([]SelectedModifier, 0, len(selected))
+	counts := map[string]int{}
+	seen := map[string]bool{}
+
+	for _, selection := range selected {
+		group, option, err := food.findModifier(selection.Group_id, selection.Option_id)
+		if err != nil {
+			return nil, err
+		}
+		key := group.Group_id + "/" + option.Option_id
+		if seen[key] {
+			return nil, fmt.Errorf("%q is selected more than once", *option.Name)
+		}
+		seen[key] = true
+		counts[group.Group_id]++
+
+		delta := NewMoney(0)
+		if option.Price_delta != nil {
+			delta = *option.Price_delta
+		}
+		resolved = append(resolved, SelectedModifier{
+			Group_id:    group.Group_id,
+			Option_id:   option.Option_id,
+			Group_name:  *group.Name,
+			Option_name: *option.Name,
+			Price_delta: delta,
+		})
+	}
+
+	for _, group := range food.Modifier_groups {
+		count := counts[group.Group_id]
+		if count < group.minSelections() {
+			return nil, fmt.Errorf("%q needs at least %d selection(s)", *group.Name, group.minSelections())
+		}
+		if group.Max_selections > 0 && count > group.Max_selections {
+			return nil, fmt.Errorf("%q allows at most %d selection(s)", *group.Name, group.Max_selections)
+		}
+	}
+	return resolved, nil
+}
+
+func (food Food) findModifier(groupId string, optionId string) (ModifierGroup, ModifierOption, error) {
+	for _, group := range food.Modifier_groups {
+		if group.Group_id != groupId {
+			continue
+		}
+		for _, option := range group.Options {
+			if option.Option_id == optionId {
+				return group, option, nil
+			}
+		}
+		return group, ModifierOption{}, fmt.Errorf("option %s is not part of %q", optionId, *group.Name)
+	}
+	return ModifierGroup{}, ModifierOption{}, fmt.Errorf("modifier group %s does not belong to this food", groupId)
+}
+
+func ModifiersTotal(modifiers []SelectedModifier) Money {
+	total := NewMoney(0)
+	for _, modifier := range modifiers {
+		total = total.Add(modifier.Price_delta)
+	}
+	return total
+}
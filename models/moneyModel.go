@@ -0,0 +1,214 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"golang-restaurant-management/config"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// Money is stored as integer minor units (cents) so sums and tax never drift.
+// In JSON the amount is written as an exact decimal, e.g. {"amount": 12.50, "currency": "USD"}.
+type Money struct {
+	Amount   int64  `json:"amount" bson:"amount"`
+	Currency string `json:"currency" bson:"currency"`
+}
+
+const MINOR_UNITS = 100
+
+var DEFAULT_CURRENCY = config.Settings.Pricing.Currency
+
+func NewMoney(amount int64) Money {
+	return Money{Amount: amount, Currency: DEFAULT_CURRENCY}
+}
+
+func ParseMoney(value string) (Money, error) {
+	rat, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return Money{}, fmt.Errorf("%q is not a valid amount", value)
+	}
+	rat.Mul(rat, big.NewRat(MINOR_UNITS, 1))
+	if !rat.IsInt() {
+		return Money{}, fmt.Errorf("%q has more than two decimal places", value)
+	}
+	if !rat.Num().IsInt64() {
+		return Money{}, fmt.Errorf("%q is too large", value)
+	}
+	return NewMoney(rat.Num().Int64()), nil
+}
+
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return DEFAULT_CURRENCY
+	}
+	return m.Currency
+}
+
+// Add and Sub keep the receiver's currency. Amounts are never mixed, since
+// requests cannot bring in a foreign currency and the service refuses to
+// start on stored amounts in another one.
+func (m Money) Add(other Money) Money {
+	return Money{Amount: m.Amount + other.Amount, Currency: m.currency()}
+}
+
+func (m Money) Sub(other Money) Money {
+	return Money{Amount: m.Amount - other.Amount, Currency: m.currency()}
+}
+
+func (m Money) Times(quantity int64) Money {
+	return Money{Amount: m.Amount * quantity, Currency: m.currency()}
+}
+
+// MulRate multiplies by a rate such as a tax rate and rounds half away from
+// zero to the nearest minor unit. The rate is taken at its shortest decimal
+// form, so 0.17 is exactly seventeen hundredths.
+func (m Money) MulRate(rate float64) Money {
+	exact, _ := new(big.Rat).SetString(strconv.FormatFloat(rate, 'f', -1, 64))
+	product := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), exact)
+	return Money{Amount: roundRat(product), Currency: m.currency()}
+}
+
+// Prorate returns the part/whole share of m, e.g. the slice of the service
+// charge that belongs to the items one guest is paying for.
+func (m Money) Prorate(part Money, whole Money) Money {
+	if whole.Amount == 0 {
+		return Money{Currency: m.currency()}
+	}
+	numerator := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(part.Amount))
+	share := new(big.Rat).SetFrac(numerator, big.NewInt(whole.Amount))
+	return Money{Amount: roundRat(share), Currency: m.currency()}
+}
+
+// Split divides into n shares that add back up exactly, handing the
+// leftover minor units to the first shares. There are no shares when n is
+// not positive.
+func (m Money) Split(n int64) []Money {
+	if n <= 0 {
+		return nil
+	}
+	shares := make([]Money, n)
+	base := m.Amount / n
+	remainder := m.Amount % n
+	for i := range shares {
+		shares[i] = Money{Amount: base, Currency: m.currency()}
+		if int64(i) < remainder {
+			shares[i].Amount++
+		}
+	}
+	return shares
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) String() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/MINOR_UNITS, amount%MINOR_UNITS)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	currency, _ := json.Marshal(m.currency())
+	return []byte(`{"amount":` + m.String() + `,"currency":` + string(currency) + `}`), nil
+}
+
+// UnmarshalJSON accepts the object form as well as a bare number or string,
+// which is how prices were sent before amounts carried a currency. Amounts in
+// any currency but the configured one are refused, since nothing converts them.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var raw struct {
+			Amount   json.Number `json:"amount"`
+			Currency string      `json:"currency"`
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			return err
+		}
+		parsed, err := ParseMoney(raw.Amount.String())
+		if err != nil {
+			return err
+		}
+		if currency := strings.ToUpper(strings.TrimSpace(raw.Currency)); currency != "" && currency != DEFAULT_CURRENCY {
+			return fmt.Errorf("amounts must be in %s, got %s", DEFAULT_CURRENCY, currency)
+		}
+		*m = parsed
+		return nil
+	}
+
+	parsed, err := ParseMoney(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// UnmarshalBSONValue also reads the plain doubles written before prices were
+// stored in minor units, so documents the migration has not reached still load.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+
+	switch t {
+	case bson.TypeEmbeddedDocument:
+		var doc struct {
+			Amount   int64  `bson:"amount"`
+			Currency string `bson:"currency"`
+		}
+		if err := raw.Unmarshal(&doc); err != nil {
+			return err
+		}
+		*m = Money{Amount: doc.Amount, Currency: doc.Currency}
+	case bson.TypeDouble:
+		parsed, err := ParseMoney(strconv.FormatFloat(raw.Double(), 'f', 2, 64))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	case bson.TypeInt32:
+		*m = NewMoney(int64(raw.Int32()) * MINOR_UNITS)
+	case bson.TypeInt64:
+		*m = NewMoney(raw.Int64() * MINOR_UNITS)
+	case bson.TypeDecimal128:
+		parsed, err := ParseMoney(raw.Decimal128().String())
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	case bson.TypeNull, bson.TypeUndefined:
+		*m = Money{}
+	default:
+		return fmt.Errorf("cannot decode %s into Money", t)
+	}
+	return nil
+}
+
+func roundRat(value *big.Rat) int64 {
+	numerator := new(big.Int).Set(value.Num())
+	denominator := value.Denom()
+
+	negative := numerator.Sign() < 0
+	numerator.Abs(numerator)
+
+	quotient, remainder := new(big.Int).QuoRem(numerator, denominator, new(big.Int))
+	if new(big.Int).Mul(remainder, big.NewInt(2)).Cmp(denominator) >= 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	if negative {
+		quotient.Neg(quotient)
+	}
+	return quotient.Int64()
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+		err   string
+	}{
+		{"12.50", 1250, ""},
+		{" 7 ", 700, ""},
+		{"-0.05", -5, ""},
+		{"0.1", 10, ""},
+		{"1.005", 0, "more than two decimal places"},
+		{"ten", 0, "not a valid amount"},
+		{"100000000000000000000", 0, "too large"},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParseMoney(test.value)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error mentioning %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Amount != test.want || got.Currency != DEFAULT_CURRENCY {
+				t.Fatalf("expected %d %s, got %d %s", test.want, DEFAULT_CURRENCY, got.Amount, got.Currency)
+			}
+		})
+	}
+}
+
+func TestMulRateRoundsHalfAwayFromZero(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64
+		rate   float64
+		want   int64
+	}{
+		{"exact", 1000, 0.2, 200},
+		{"half rounds up", 1250, 0.1, 125},
+		{"half cent up", 5, 0.5, 3},
+		{"half cent down for negatives", -5, 0.5, -3},
+		{"below half", 333, 0.17, 57},
+		{"decimal rate taken as written", 100, 0.07, 7},
+		{"zero rate", 999, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := NewMoney(test.amount).MulRate(test.rate).Amount; got != test.want {
+				t.Fatalf("expected %d, got %d", test.want, got)
+			}
+		})
+	}
+}
+
+func TestProrate(t *testing.T) {
+	tests := []struct {
+		name        string
+		amount      int64
+		part, whole int64
+		want        int64
+	}{
+		{"half", 1000, 50, 100, 500},
+		{"a third rounds", 100, 1, 3, 33},
+		{"two thirds rounds up", 100, 2, 3, 67},
+		{"empty whole", 1000, 0, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := NewMoney(test.amount).Prorate(NewMoney(test.part), NewMoney(test.whole))
+			if got.Amount != test.want {
+				t.Fatalf("expected %d, got %d", test.want, got.Amount)
+			}
+		})
+	}
+}
+
+func TestSplitAddsBackUp(t *testing.T) {
+	tests := []struct {
+		amount int64
+		n      int64
+		want   []int64
+	}{
+		{1000, 3, []int64{334, 333, 333}},
+		{1001, 2, []int64{501, 500}},
+		{2, 4, []int64{1, 1, 0, 0}},
+		{1000, 0, nil},
+	}
+	for _, test := range tests {
+		shares := NewMoney(test.amount).Split(test.n)
+		if len(shares) != len(test.want) {
+			t.Fatalf("%d in %d: expected %d shares, got %d", test.amount, test.n, len(test.want), len(shares))
+		}
+		for i, share := range shares {
+			if share.Amount != test.want[i] {
+				t.Fatalf("%d in %d: share %d is %d, expected %d", test.amount, test.n, i, share.Amount, test.want[i])
+			}
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := map[int64]string{1250: "12.50", 5: "0.05", -1205: "-12.05", 0: "0.00"}
+	for amount, want := range tests {
+		if got := NewMoney(amount).String(); got != want {
+			t.Fatalf("%d: expected %s, got %s", amount, want, got)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int64
+		err  string
+	}{
+		{"bare number", `12.5`, 1250, ""},
+		{"string", `"3.10"`, 310, ""},
+		{"object", `{"amount": 4.20, "currency": "` + DEFAULT_CURRENCY + `"}`, 420, ""},
+		{"object without currency", `{"amount": 1}`, 100, ""},
+		{"lower case currency", `{"amount": 1, "currency": "` + strings.ToLower(DEFAULT_CURRENCY) + `"}`, 100, ""},
+		{"foreign currency", `{"amount": 1, "currency": "XTS"}`, 0, "amounts must be in"},
+		{"too precise", `0.001`, 0, "more than two decimal places"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got Money
+			err := json.Unmarshal([]byte(test.body), &got)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error mentioning %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Amount != test.want || got.Currency != DEFAULT_CURRENCY {
+				t.Fatalf("expected %d %s, got %d %s", test.want, DEFAULT_CURRENCY, got.Amount, got.Currency)
+			}
+		})
+	}
+}
+
+func TestArithmeticKeepsTheReceiverCurrency(t *testing.T) {
+	price := Money{Amount: 1000}
+	stored := Money{Amount: 250, Currency: "XTS"}
+
+	if got := price.Add(stored); got.Amount != 1250 || got.Currency != DEFAULT_CURRENCY {
+		t.Fatalf("expected 1250 %s, got %d %s", DEFAULT_CURRENCY, got.Amount, got.Currency)
+	}
+	if got := price.Sub(stored); got.Amount != 750 || got.Currency != DEFAULT_CURRENCY {
+		t.Fatalf("expected 750 %s, got %d %s", DEFAULT_CURRENCY, got.Amount, got.Currency)
+	}
+	if got := price.Times(3); got.Amount != 3000 || got.Currency != DEFAULT_CURRENCY {
+		t.Fatalf("expected 3000 %s, got %d %s", DEFAULT_CURRENCY, got.Amount, got.Currency)
+	}
+}
@@ -6,13 +6,28 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	DEFAULT_STATION = "KITCHEN"
+
+	KITCHEN_PENDING = "PENDING"
+	KITCHEN_BUMPED  = "BUMPED"
+	KITCHEN_VOIDED  = "VOIDED"
+)
+
 type OrderItem struct {
-	ID            primitive.ObjectID `bson:"_id"`
-	Quantity      *string            `json:"quantity" validate:"required,eq=S|eq=M|eq=L"`
-	Unit_price    *float64           `json:"unit_price" validate:"required"`
-	Created_at    time.Time          `json:"created_at"`
-	Updated_at    time.Time          `json:"updated_at"`
-	Food_id       *string            `json:"food_id" validate:"required"`
-	Order_item_id string             `json:"order_item_id"`
-	Order_id      string             `json:"order_id" validate:"required"`
+	ID             primitive.ObjectID  `bson:"_id"`
+	Quantity       *int                `json:"quantity" validate:"required,min=1"`
+	Size           *string             `json:"size" validate:"omitempty,max=20"`
+	Unit_price     *Money              `json:"unit_price"`
+	Pricing_rule   *AppliedPricingRule `json:"pricing_rule"`
+	Modifiers      []SelectedModifier  `json:"modifiers" validate:"dive"`
+	Allergy_alerts []string            `json:"allergy_alerts"`
+	Created_at     time.Time           `json:"created_at"`
+	Updated_at     time.Time           `json:"updated_at"`
+	Food_id        *string             `json:"food_id" validate:"required"`
+	Order_item_id  string              `json:"order_item_id"`
+	Order_id       string              `json:"order_id" validate:"required"`
+	Station        string              `json:"station"`
+	Kitchen_status string              `json:"kitchen_status"`
+	Bumped_at      *time.Time          `json:"bumped_at"`
 }
@@ -6,11 +6,91 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	ORDER_PLACED    = "PLACED"
+	ORDER_ACCEPTED  = "ACCEPTED"
+	ORDER_PREPARING = "PREPARING"
+	ORDER_READY     = "READY"
+	ORDER_SERVED    = "SERVED"
+	ORDER_CLOSED    = "CLOSED"
+	ORDER_CANCELLED = "CANCELLED"
+	ORDER_VOIDED    = "VOIDED"
+)
+
+// Orders saved before statuses existed are given one on startup, so every
+// order is counted here once the service is up.
+var OPEN_ORDER_STATUSES = []string{ORDER_PLACED, ORDER_ACCEPTED, ORDER_PREPARING, ORDER_READY, ORDER_SERVED}
+
+// Orders cancelled before the kitchen starts on them; once food is being made
+// they can only be voided.
+var OrderTransitions = map[string][]string{
+	ORDER_PLACED:    {ORDER_ACCEPTED, ORDER_CANCELLED},
+	ORDER_ACCEPTED:  {ORDER_PREPARING, ORDER_CANCELLED},
+	ORDER_PREPARING: {ORDER_READY, ORDER_VOIDED},
+	ORDER_READY:     {ORDER_SERVED, ORDER_VOIDED},
+	ORDER_SERVED:    {ORDER_CLOSED, ORDER_VOIDED},
+}
+
+// Who may move an order into each status: the kitchen works it through to
+// ready, the floor serves, cancels and closes it, and only management voids.
+var OrderTransitionRoles = map[string][]string{
+	ORDER_ACCEPTED:  {ROLE_OWNER, ROLE_MANAGER, ROLE_KITCHEN},
+	ORDER_PREPARING: {ROLE_OWNER, ROLE_MANAGER, ROLE_KITCHEN},
+	ORDER_READY:     {ROLE_OWNER, ROLE_MANAGER, ROLE_KITCHEN},
+	ORDER_SERVED:    {ROLE_OWNER, ROLE_MANAGER, ROLE_WAITER},
+	ORDER_CANCELLED: {ROLE_OWNER, ROLE_MANAGER, ROLE_WAITER},
+	ORDER_CLOSED:    {ROLE_OWNER, ROLE_MANAGER, ROLE_WAITER},
+	ORDER_VOIDED:    {ROLE_OWNER, ROLE_MANAGER},
+}
+
 type Order struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Order_Date time.Time          `json:"order_date" validate:"required"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Order_id   string             `json:"order_id"`
-	Table_id   *string            `json:"table_id" validate:"required"`
+	ID             primitive.ObjectID   `bson:"_id"`
+	Order_Date     time.Time            `json:"order_date" validate:"required"`
+	Created_at     time.Time            `json:"created_at"`
+	Updated_at     time.Time            `json:"updated_at"`
+	Order_id       string               `json:"order_id"`
+	Table_id       *string              `json:"table_id" validate:"required"`
+	Status         *string              `json:"status"`
+	Status_history []OrderStatusChange  `json:"status_history"`
+	Allergies      []AllergyDeclaration `json:"allergies" validate:"dive"`
+}
+
+type OrderStatusChange struct {
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Changed_by string    `json:"changed_by"`
+	Changed_at time.Time `json:"changed_at"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+func CanTransitionOrder(from string, to string) bool {
+	for _, next := range OrderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func CanMoveOrderTo(role string, to string) bool {
+	for _, allowed := range OrderTransitionRoles[to] {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Orders created before statuses existed have none and are treated as placed.
+func (order Order) CurrentStatus() string {
+	if order.Status == nil || *order.Status == "" {
+		return ORDER_PLACED
+	}
+	return *order.Status
+}
+
+// IsOpen reports whether the order can still change: once it is closed,
+// cancelled or voided its stock and portions are settled.
+func (order Order) IsOpen() bool {
+	return contains(OPEN_ORDER_STATUSES, order.CurrentStatus())
 }
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	PAYMENT_PENDING        = "PENDING"
+	PAYMENT_PARTIALLY_PAID = "PARTIALLY_PAID"
+	PAYMENT_PAID           = "PAID"
+)
+
+type Payment struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	Payment_id       string             `json:"payment_id"`
+	Invoice_id       string             `json:"invoice_id"`
+	Amount           *Money             `json:"amount"`
+	Tip              *Money             `json:"tip"`
+	Payment_method   *string            `json:"payment_method" validate:"required,eq=CARD|eq=CASH"`
+	Tender_reference *string            `json:"tender_reference"`
+	Order_item_ids   []string           `json:"order_item_ids"`
+	Split_label      *string            `json:"split_label"`
+	Created_by       string             `json:"created_by"`
+	Created_at       time.Time          `json:"created_at"`
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	RULE_PERCENTAGE = "PERCENTAGE"
+	RULE_FIXED      = "FIXED"
+)
+
+// PricingRule changes what a food costs during its windows, e.g. 50% off
+// drinks 17:00-19:00 on weekdays, or a fixed lunch price. It applies to the
+// foods listed, every food on the menus listed and every food on a menu of
+// the categories listed. A FIXED price replaces the unit price whatever the
+// size, but never raises it. When several rules match, the highest priority
+// wins, then the cheapest result.
+type PricingRule struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Pricing_rule_id string             `json:"pricing_rule_id"`
+	Name            *string            `json:"name" validate:"required,min=2,max=100"`
+	Kind            *string            `json:"kind" validate:"required,eq=PERCENTAGE|eq=FIXED"`
+	Percentage_off  *float64           `json:"percentage_off" validate:"omitempty,gt=0,lte=100"`
+	Fixed_price     *Money             `json:"fixed_price"`
+	Food_ids        []string           `json:"food_ids"`
+	Menu_ids        []string           `json:"menu_ids"`
+	Categories      []string           `json:"categories"`
+	Windows         []TimeWindow       `json:"windows" validate:"dive"`
+	Start_date      *time.Time         `json:"start_date"`
+	End_date        *time.Time         `json:"end_date"`
+	Priority        int                `json:"priority"`
+	Enabled         *bool              `json:"enabled"`
+	Created_by      string             `json:"created_by"`
+	Created_at      time.Time          `json:"created_at"`
+	Updated_at      time.Time          `json:"updated_at"`
+}
+
+// AppliedPricingRule is kept on the order item so the bill can show which
+// offer was used and what the item would otherwise have cost.
+type AppliedPricingRule struct {
+	Pricing_rule_id string `json:"pricing_rule_id"`
+	Name            string `json:"name"`
+	Regular_price   Money  `json:"regular_price"`
+}
+
+func (rule PricingRule) Validate() error {
+	switch *rule.Kind {
+	case RULE_PERCENTAGE:
+		if rule.Percentage_off == nil {
+			return fmt.Errorf("a PERCENTAGE rule needs percentage_off")
+		}
+	case RULE_FIXED:
+		if rule.Fixed_price == nil || rule.Fixed_price.Amount < 0 {
+			return fmt.Errorf("a FIXED rule needs a fixed_price that is not negative")
+		}
+	}
+	if len(rule.Food_ids) == 0 && len(rule.Menu_ids) == 0 && len(rule.Categories) == 0 {
+		return fmt.Errorf("a rule needs at least one of food_ids, menu_ids or categories")
+	}
+	if rule.Start_date != nil && rule.End_date != nil && !rule.End_date.After(*rule.Start_date) {
+		return fmt.Errorf("end_date must be after start_date")
+	}
+	return ValidateSchedule(rule.Windows, nil)
+}
+
+func (rule PricingRule) IsEnabled() bool {
+	return rule.Enabled == nil || *rule.Enabled
+}
+
+func (rule PricingRule) AppliesTo(food Food, menu Menu, at time.Time) bool {
+	if !rule.IsEnabled() {
+		return false
+	}
+	if rule.Start_date != nil && at.Before(*rule.Start_date) {
+		return false
+	}
+	if rule.End_date != nil && !at.Before(*rule.End_date) {
+		return false
+	}
+	if !AnyWindowContains(rule.Windows, at) {
+		return false
+	}
+	return contains(rule.Food_ids, food.Food_id) ||
+		(food.Menu_id != nil && contains(rule.Menu_ids, *food.Menu_id)) ||
+		(menu.Category != "" && containsFold(rule.Categories, menu.Category))
+}
+
+func (rule PricingRule) Apply(price Money) Money {
+	if *rule.Kind == RULE_FIXED {
+		if rule.Fixed_price.Amount > price.Amount {
+			return price
+		}
+		return *rule.Fixed_price
+	}
+	return price.Sub(price.MulRate(*rule.Percentage_off / 100))
+}
+
+// BestPricingRule returns the price after the winning rule, and the rule, or
+// the regular price and nil when none applies.
+func BestPricingRule(rules []PricingRule, food Food, menu Menu, price Money, at time.Time) (Money, *AppliedPricingRule) {
+	var best *PricingRule
+	bestPrice := price
+	for i, rule := range rules {
+		if !rule.AppliesTo(food, menu, at) {
+			continue
+		}
+		rulePrice := rule.Apply(price)
+		// a rule that takes nothing off is no offer, so it neither shows on
+		// the bill nor beats one that does
+		if rulePrice.Amount >= price.Amount {
+			continue
+		}
+		if best == nil || rule.Priority > best.Priority || (rule.Priority == best.Priority && rulePrice.Amount < bestPrice.Amount) {
+			best = &rules[i]
+			bestPrice = rulePrice
+		}
+	}
+	if best == nil {
+		return price, nil
+	}
+	return bestPrice, &AppliedPricingRule{Pricing_rule_id: best.Pricing_rule_id, Name: *best.Name, Regular_price: price}
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
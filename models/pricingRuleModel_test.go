@@ -0,0 +1,152 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func pricingRule(name string, kind string, priority int) PricingRule {
+	return PricingRule{Pricing_rule_id: name, Name: &name, Kind: &kind, Priority: priority, Food_ids: []string{"burger"}}
+}
+
+func percentageOff(rule PricingRule, percentage float64) PricingRule {
+	rule.Percentage_off = &percentage
+	return rule
+}
+
+func fixedAt(rule PricingRule, amount int64) PricingRule {
+	price := NewMoney(amount)
+	rule.Fixed_price = &price
+	return rule
+}
+
+func TestPricingRuleAppliesTo(t *testing.T) {
+	menuId := "mains"
+	food := Food{Food_id: "burger", Menu_id: &menuId}
+	menu := Menu{Menu_id: menuId, Category: "MAINS"}
+	disabled := false
+	from, until := monday(12, 0), monday(14, 0)
+
+	tests := []struct {
+		name string
+		edit func(*PricingRule)
+		at   time.Time
+		want bool
+	}{
+		{"listed food", func(rule *PricingRule) {}, monday(13, 0), true},
+		{"other food", func(rule *PricingRule) { rule.Food_ids = []string{"fries"} }, monday(13, 0), false},
+		{"menu", func(rule *PricingRule) { rule.Food_ids, rule.Menu_ids = nil, []string{menuId} }, monday(13, 0), true},
+		{"category in any case", func(rule *PricingRule) { rule.Food_ids, rule.Categories = nil, []string{"mains"} }, monday(13, 0), true},
+		{"disabled", func(rule *PricingRule) { rule.Enabled = &disabled }, monday(13, 0), false},
+		{"before start date", func(rule *PricingRule) { rule.Start_date = &from }, monday(11, 59), false},
+		{"on start date", func(rule *PricingRule) { rule.Start_date = &from }, monday(12, 0), true},
+		{"on end date", func(rule *PricingRule) { rule.End_date = &until }, monday(14, 0), false},
+		{"inside window", func(rule *PricingRule) { rule.Windows = []TimeWindow{{Start: "17:00", End: "19:00"}} }, monday(18, 0), true},
+		{"outside window", func(rule *PricingRule) { rule.Windows = []TimeWindow{{Start: "17:00", End: "19:00"}} }, monday(19, 0), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := percentageOff(pricingRule("happy hour", RULE_PERCENTAGE, 0), 50)
+			test.edit(&rule)
+			if got := rule.AppliesTo(food, menu, test.at); got != test.want {
+				t.Fatalf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPricingRuleApply(t *testing.T) {
+	tests := []struct {
+		name string
+		rule PricingRule
+		want int64
+	}{
+		{"half off", percentageOff(pricingRule("half", RULE_PERCENTAGE, 0), 50), 625},
+		{"the discount rounds half away from zero", percentageOff(pricingRule("third", RULE_PERCENTAGE, 0), 33), 837},
+		{"whole price off", percentageOff(pricingRule("free", RULE_PERCENTAGE, 0), 100), 0},
+		{"fixed lower price", fixedAt(pricingRule("lunch", RULE_FIXED, 0), 1000), 1000},
+		{"fixed price never raises", fixedAt(pricingRule("lunch", RULE_FIXED, 0), 1500), 1250},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.rule.Apply(NewMoney(1250)).Amount; got != test.want {
+				t.Fatalf("expected %d, got %d", test.want, got)
+			}
+		})
+	}
+}
+
+func TestBestPricingRule(t *testing.T) {
+	food := Food{Food_id: "burger"}
+	tenOff := percentageOff(pricingRule("ten off", RULE_PERCENTAGE, 0), 10)
+	halfOff := percentageOff(pricingRule("half off", RULE_PERCENTAGE, 0), 50)
+	favoured := percentageOff(pricingRule("favoured", RULE_PERCENTAGE, 1), 10)
+	dearer := fixedAt(pricingRule("dearer", RULE_FIXED, 5), 2000)
+
+	tests := []struct {
+		name     string
+		rules    []PricingRule
+		want     int64
+		wantRule string
+	}{
+		{"no rules", nil, 1250, ""},
+		{"cheapest at the same priority", []PricingRule{tenOff, halfOff}, 625, "half off"},
+		{"priority beats price", []PricingRule{halfOff, favoured}, 1125, "favoured"},
+		{"a rule that takes nothing off is skipped", []PricingRule{dearer, tenOff}, 1125, "ten off"},
+		{"only rules that take nothing off", []PricingRule{dearer}, 1250, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			price, applied := BestPricingRule(test.rules, food, Menu{}, NewMoney(1250), monday(13, 0))
+			if price.Amount != test.want {
+				t.Fatalf("expected %d, got %d", test.want, price.Amount)
+			}
+			if test.wantRule == "" {
+				if applied != nil {
+					t.Fatalf("expected no rule, got %s", applied.Name)
+				}
+				return
+			}
+			if applied == nil || applied.Name != test.wantRule || applied.Regular_price.Amount != 1250 {
+				t.Fatalf("expected %s over a regular 1250, got %+v", test.wantRule, applied)
+			}
+		})
+	}
+}
+
+func TestPricingRuleValidate(t *testing.T) {
+	from, until := monday(12, 0), monday(11, 0)
+	tests := []struct {
+		name    string
+		rule    PricingRule
+		wantErr bool
+	}{
+		{"percentage", percentageOff(pricingRule("half", RULE_PERCENTAGE, 0), 50), false},
+		{"percentage without amount", pricingRule("half", RULE_PERCENTAGE, 0), true},
+		{"fixed", fixedAt(pricingRule("lunch", RULE_FIXED, 0), 1000), false},
+		{"fixed without price", pricingRule("lunch", RULE_FIXED, 0), true},
+		{"negative fixed price", fixedAt(pricingRule("lunch", RULE_FIXED, 0), -1), true},
+		{"nothing to apply to", func() PricingRule {
+			rule := percentageOff(pricingRule("half", RULE_PERCENTAGE, 0), 50)
+			rule.Food_ids = nil
+			return rule
+		}(), true},
+		{"end before start", func() PricingRule {
+			rule := percentageOff(pricingRule("half", RULE_PERCENTAGE, 0), 50)
+			rule.Start_date, rule.End_date = &from, &until
+			return rule
+		}(), true},
+		{"bad window", func() PricingRule {
+			rule := percentageOff(pricingRule("half", RULE_PERCENTAGE, 0), 50)
+			rule.Windows = []TimeWindow{{Start: "17:00", End: "17:00"}}
+			return rule
+		}(), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.rule.Validate(); (err != nil) != test.wantErr {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	PROMO_ORDER       = "ORDER"
+	PROMO_ITEM        = "ITEM"
+	PROMO_BUY_X_GET_Y = "BUY_X_GET_Y"
+	PROMO_COMBO       = "COMBO"
+)
+
+// Promotion is a discount taken off the bill. Without a code it applies to
+// every invoice while it runs; with one it has to be redeemed by a cashier.
+//
+//   - ORDER takes percentage_off or amount_off the whole order, once it
+//     reaches min_subtotal.
+//   - ITEM takes percentage_off or amount_off each unit of the foods or
+//     categories listed.
+//   - BUY_X_GET_Y gives get_quantity of the listed foods free (or at
+//     percentage_off) for every buy_quantity bought, the cheapest first.
+//   - COMBO sells one of each of combo_food_ids for combo_price.
+type Promotion struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Promotion_id   string             `json:"promotion_id"`
+	Name           *string            `json:"name" validate:"required,min=2,max=100"`
+	Kind           *string            `json:"kind" validate:"required,eq=ORDER|eq=ITEM|eq=BUY_X_GET_Y|eq=COMBO"`
+	Code           *string            `json:"code" validate:"omitempty,min=3,max=30,alphanum"`
+	Percentage_off *float64           `json:"percentage_off" validate:"omitempty,gt=0,lte=100"`
+	Amount_off     *Money             `json:"amount_off"`
+	Food_ids       []string           `json:"food_ids"`
+	Categories     []string           `json:"categories"`
+	Buy_quantity   *int               `json:"buy_quantity" validate:"omitempty,min=1"`
+	Get_quantity   *int               `json:"get_quantity" validate:"omitempty,min=1"`
+	Combo_food_ids []string           `json:"combo_food_ids"`
+	Combo_price    *Money             `json:"combo_price"`
+	Min_subtotal   *Money             `json:"min_subtotal"`
+	Max_uses       *int               `json:"max_uses" validate:"omitempty,min=1"`
+	Uses           int                `json:"uses"`
+	Starts_at      *time.Time         `json:"starts_at"`
+	Expires_at     *time.Time         `json:"expires_at"`
+	Enabled        *bool              `json:"enabled"`
+	Created_by     string             `json:"created_by"`
+	Created_at     time.Time          `json:"created_at"`
+	Updated_at     time.Time          `json:"updated_at"`
+}
+
+// InvoiceDiscount is a promotion as it was when it was put on an invoice, or
+// a manual discount with the reason given for it. Amount is what it took off
+// the last time the invoice was priced.
+type InvoiceDiscount struct {
+	Discount_id    string    `json:"discount_id"`
+	Promotion      Promotion `json:"promotion"`
+	Manual         bool      `json:"manual"`
+	Order_item_ids []string  `json:"order_item_ids"`
+	Reason         string    `json:"reason"`
+	Amount         Money     `json:"amount"`
+	Applied_by     string    `json:"applied_by"`
+	Applied_at     time.Time `json:"applied_at"`
+}
+
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (promotion Promotion) Validate() error {
+	hasDiscount := promotion.Percentage_off != nil || promotion.Amount_off != nil
+	if promotion.Percentage_off != nil && promotion.Amount_off != nil {
+		return fmt.Errorf("set either percentage_off or amount_off, not both")
+	}
+	if promotion.Amount_off != nil && promotion.Amount_off.Amount <= 0 {
+		return fmt.Errorf("amount_off must be more than zero")
+	}
+
+	switch *promotion.Kind {
+	case PROMO_ORDER:
+		if !hasDiscount {
+			return fmt.Errorf("an ORDER promotion needs percentage_off or amount_off")
+		}
+	case PROMO_ITEM:
+		if !hasDiscount {
+			return fmt.Errorf("an ITEM promotion needs percentage_off or amount_off")
+		}
+		if len(promotion.Food_ids) == 0 && len(promotion.Categories) == 0 {
+			return fmt.Errorf("an ITEM promotion needs food_ids or categories")
+		}
+	case PROMO_BUY_X_GET_Y:
+		if promotion.Buy_quantity == nil || promotion.Get_quantity == nil {
+			return fmt.Errorf("a BUY_X_GET_Y promotion needs buy_quantity and get_quantity")
+		}
+		if promotion.Amount_off != nil {
+			return fmt.Errorf("a BUY_X_GET_Y promotion takes percentage_off, or nothing for free items")
+		}
+		if len(promotion.Food_ids) == 0 && len(promotion.Categories) == 0 {
+			return fmt.Errorf("a BUY_X_GET_Y promotion needs food_ids or categories")
+		}
+	case PROMO_COMBO:
+		if len(promotion.Combo_food_ids) < 2 || promotion.Combo_price == nil || promotion.Combo_price.Amount < 0 {
+			return fmt.Errorf("a COMBO promotion needs at least two combo_food_ids and a combo_price")
+		}
+		if hasDiscount {
+			return fmt.Errorf("a COMBO promotion is priced by combo_price only")
+		}
+	}
+
+	if promotion.Starts_at != nil && promotion.Expires_at != nil && !promotion.Expires_at.After(*promotion.Starts_at) {
+		return fmt.Errorf("expires_at must be after starts_at")
+	}
+	return nil
+}
+
+func (promotion Promotion) IsEnabled() bool {
+	return promotion.Enabled == nil || *promotion.Enabled
+}
+
+// Redeemable reports why the promotion cannot be used at the given time, if
+// it cannot.
+func (promotion Promotion) Redeemable(at time.Time) error {
+	switch {
+	case !promotion.IsEnabled():
+		return fmt.Errorf("%s is not active", *promotion.Name)
+	case promotion.Starts_at != nil && at.Before(*promotion.Starts_at):
+		return fmt.Errorf("%s has not started yet", *promotion.Name)
+	case promotion.Expires_at != nil && !at.Before(*promotion.Expires_at):
+		return fmt.Errorf("%s has expired", *promotion.Name)
+	case promotion.Max_uses != nil && promotion.Uses >= *promotion.Max_uses:
+		return fmt.Errorf("%s has been used up", *promotion.Name)
+	}
+	return nil
+}
+
+// Covers is true for items the promotion is scoped to; an unscoped ORDER or
+// manual discount covers everything.
+func (promotion Promotion) Covers(foodId string, category string) bool {
+	if len(promotion.Food_ids) == 0 && len(promotion.Categories) == 0 {
+		return true
+	}
+	return contains(promotion.Food_ids, foodId) || (category != "" && containsFold(promotion.Categories, category))
+}
+
+const (
+	DISCOUNT_APPLIED = "APPLIED"
+	DISCOUNT_REMOVED = "REMOVED"
+)
+
+// DiscountAudit records every discount put on or taken off an invoice, who
+// did it and why, so manual discounts can be reviewed after service.
+type DiscountAudit struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Audit_id     string             `json:"audit_id"`
+	Invoice_id   string             `json:"invoice_id"`
+	Discount_id  string             `json:"discount_id"`
+	Promotion_id string             `json:"promotion_id"`
+	Action       string             `json:"action"`
+	Manual       bool               `json:"manual"`
+	Amount       Money              `json:"amount"`
+	Reason       string             `json:"reason"`
+	By           string             `json:"by"`
+	At           time.Time          `json:"at"`
+}
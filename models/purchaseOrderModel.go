@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	PO_DRAFT     = "DRAFT"
+	PO_SENT      = "SENT"
+	PO_RECEIVED  = "RECEIVED"
+	PO_CANCELLED = "CANCELLED"
+)
+
+// Lines can only be edited while the order is a draft. Once it is sent the
+// supplier is working from it, and receiving it is what adds the stock.
+type PurchaseOrder struct {
+	ID                primitive.ObjectID  `bson:"_id"`
+	Purchase_order_id string              `json:"purchase_order_id"`
+	Supplier_id       *string             `json:"supplier_id" validate:"required"`
+	Status            string              `json:"status"`
+	Lines             []PurchaseOrderLine `json:"lines" validate:"required,min=1,dive"`
+	Total             Money               `json:"total"`
+	Notes             *string             `json:"notes"`
+	Created_by        string              `json:"created_by"`
+	Sent_at           *time.Time          `json:"sent_at"`
+	Received_at       *time.Time          `json:"received_at"`
+	Received_by       string              `json:"received_by,omitempty"`
+	Created_at        time.Time           `json:"created_at"`
+	Updated_at        time.Time           `json:"updated_at"`
+}
+
+type PurchaseOrderLine struct {
+	Ingredient_id     string   `json:"ingredient_id" validate:"required"`
+	Quantity          float64  `json:"quantity" validate:"required,gt=0"`
+	Unit_cost         *Money   `json:"unit_cost" validate:"required"`
+	Received_quantity *float64 `json:"received_quantity"`
+}
+
+func (order *PurchaseOrder) ComputeTotal() {
+	total := NewMoney(0)
+	for _, line := range order.Lines {
+		total = total.Add(line.Unit_cost.MulRate(line.Quantity))
+	}
+	order.Total = total
+}
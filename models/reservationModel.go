@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	RESERVATION_BOOKED    = "BOOKED"
+	RESERVATION_SEATED    = "SEATED"
+	RESERVATION_COMPLETED = "COMPLETED"
+	RESERVATION_CANCELLED = "CANCELLED"
+	RESERVATION_NO_SHOW   = "NO_SHOW"
+
+	DEFAULT_RESERVATION_MINUTES = 90
+)
+
+type Reservation struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	Reservation_id   string             `json:"reservation_id"`
+	Table_id         *string            `json:"table_id"`
+	Party_size       *int               `json:"party_size" validate:"required,min=1"`
+	Start_time       *time.Time         `json:"start_time" validate:"required"`
+	Duration_minutes *int               `json:"duration_minutes" validate:"omitempty,min=15,max=480"`
+	End_time         time.Time          `json:"end_time"`
+	Customer_name    *string            `json:"customer_name" validate:"required,min=2,max=100"`
+	Customer_phone   *string            `json:"customer_phone" validate:"required"`
+	Customer_email   *string            `json:"customer_email" validate:"omitempty,email"`
+	Notes            *string            `json:"notes"`
+	Status           *string            `json:"status" validate:"omitempty,eq=BOOKED|eq=SEATED|eq=COMPLETED|eq=CANCELLED|eq=NO_SHOW"`
+	Created_by       string             `json:"created_by"`
+	Created_at       time.Time          `json:"created_at"`
+	Updated_at       time.Time          `json:"updated_at"`
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"fmt"
+	"golang-restaurant-management/config"
+	"strings"
+	"time"
+)
+
+// RESTAURANT_LOCATION is the time zone dayparts and holidays are written in,
+// taken from the timezone setting (e.g. "Europe/London") and defaulting to the server's.
+var RESTAURANT_LOCATION = restaurantLocation()
+
+func restaurantLocation() *time.Location {
+	if config.Settings.Timezone == "" {
+		return time.Local
+	}
+	// the name was checked when the configuration loaded
+	location, _ := time.LoadLocation(config.Settings.Timezone)
+	return location
+}
+
+var weekdays = map[string]time.Weekday{
+	"SUN": time.Sunday,
+	"MON": time.Monday,
+	"TUE": time.Tuesday,
+	"WED": time.Wednesday,
+	"THU": time.Thursday,
+	"FRI": time.Friday,
+	"SAT": time.Saturday,
+}
+
+// TimeWindow is a recurring slot such as breakfast, 07:00 to 11:00 on
+// weekdays. Start is inclusive and End exclusive; an End before Start runs
+// past midnight and belongs to the day it starts on. No days means every day.
+type TimeWindow struct {
+	Days  []string `json:"days" validate:"dive,oneof=MON TUE WED THU FRI SAT SUN"`
+	Start string   `json:"start" validate:"required"`
+	End   string   `json:"end" validate:"required"`
+}
+
+func (window TimeWindow) Validate() error {
+	start, err := parseClock(window.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(window.End)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return fmt.Errorf("window %s-%s is empty", window.Start, window.End)
+	}
+	for _, day := range window.Days {
+		if _, ok := weekdays[strings.ToUpper(day)]; !ok {
+			return fmt.Errorf("%q is not a day, use MON..SUN", day)
+		}
+	}
+	return nil
+}
+
+func (window TimeWindow) Contains(at time.Time) bool {
+	at = at.In(RESTAURANT_LOCATION)
+	start, startErr := parseClock(window.Start)
+	end, endErr := parseClock(window.End)
+	if startErr != nil || endErr != nil {
+		return false
+	}
+	minute := at.Hour()*60 + at.Minute()
+
+	if start < end {
+		return minute >= start && minute < end && window.onDay(at.Weekday())
+	}
+	// past midnight: the early hours belong to the previous day's window
+	if minute >= start {
+		return window.onDay(at.Weekday())
+	}
+	return minute < end && window.onDay((at.Weekday()+6)%7)
+}
+
+func (window TimeWindow) onDay(day time.Weekday) bool {
+	if len(window.Days) == 0 {
+		return true
+	}
+	for _, name := range window.Days {
+		if weekdays[strings.ToUpper(name)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+func parseClock(value string) (int, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day, use HH:MM", value)
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+// AnyWindowContains is true when no windows are set at all.
+func AnyWindowContains(windows []TimeWindow, at time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	for _, window := range windows {
+		if window.Contains(at) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduleOverride replaces a menu's dayparts on one date, e.g. closing the
+// lunch menu on a holiday or serving brunch on a bank-holiday Monday. With
+// neither Closed nor Windows set the menu runs all day on that date.
+type ScheduleOverride struct {
+	Date    string       `json:"date" validate:"required,datetime=2006-01-02"`
+	Closed  bool         `json:"closed"`
+	Windows []TimeWindow `json:"windows" validate:"dive"`
+	Note    string       `json:"note"`
+}
+
+// NormalizeSchedule upper-cases the days in place, so "mon" is validated and
+// stored as MON.
+func NormalizeSchedule(windows []TimeWindow, overrides []ScheduleOverride) {
+	for _, window := range windows {
+		for i, day := range window.Days {
+			window.Days[i] = strings.ToUpper(day)
+		}
+	}
+	for _, override := range overrides {
+		NormalizeSchedule(override.Windows, nil)
+	}
+}
+
+func ValidateSchedule(windows []TimeWindow, overrides []ScheduleOverride) error {
+	for _, window := range windows {
+		if err := window.Validate(); err != nil {
+			return err
+		}
+	}
+	seen := map[string]bool{}
+	for _, override := range overrides {
+		if seen[override.Date] {
+			return fmt.Errorf("%s has more than one override", override.Date)
+		}
+		seen[override.Date] = true
+		for _, window := range override.Windows {
+			if err := window.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// monday is a Monday in the restaurant's time zone at the given clock time.
+func monday(hour int, minute int) time.Time {
+	return time.Date(2024, time.January, 1, hour, minute, 0, 0, RESTAURANT_LOCATION)
+}
+
+func TestTimeWindowContains(t *testing.T) {
+	breakfast := TimeWindow{Days: []string{"MON", "TUE", "WED", "THU", "FRI"}, Start: "07:00", End: "11:00"}
+	lateFriday := TimeWindow{Days: []string{"FRI"}, Start: "22:00", End: "02:00"}
+	everyNight := TimeWindow{Start: "22:00", End: "02:00"}
+
+	tests := []struct {
+		name   string
+		window TimeWindow
+		at     time.Time
+		want   bool
+	}{
+		{"start is inclusive", breakfast, monday(7, 0), true},
+		{"inside", breakfast, monday(10, 59), true},
+		{"end is exclusive", breakfast, monday(11, 0), false},
+		{"before", breakfast, monday(6, 59), false},
+		{"wrong day", breakfast, monday(8, 0).AddDate(0, 0, 5), false},
+		{"lower case day", TimeWindow{Days: []string{"mon"}, Start: "07:00", End: "11:00"}, monday(8, 0), true},
+		{"past midnight on its day", lateFriday, monday(23, 0).AddDate(0, 0, 4), true},
+		{"early hours belong to the day before", lateFriday, monday(1, 0).AddDate(0, 0, 5), true},
+		{"early hours of the day itself", lateFriday, monday(1, 0).AddDate(0, 0, 4), false},
+		{"past midnight ends exclusive", lateFriday, monday(2, 0).AddDate(0, 0, 5), false},
+		{"no days means every day", everyNight, monday(0, 30), true},
+		{"unparsable window", TimeWindow{Start: "7am", End: "11:00"}, monday(8, 0), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.window.Contains(test.at); got != test.want {
+				t.Fatalf("expected %v at %s, got %v", test.want, test.at.Format("Mon 15:04"), got)
+			}
+		})
+	}
+}
+
+func TestTimeWindowValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  TimeWindow
+		wantErr bool
+	}{
+		{"daytime", TimeWindow{Start: "07:00", End: "11:00"}, false},
+		{"past midnight", TimeWindow{Start: "22:00", End: "02:00"}, false},
+		{"empty", TimeWindow{Start: "09:00", End: "09:00"}, true},
+		{"bad start", TimeWindow{Start: "25:00", End: "09:00"}, true},
+		{"bad end", TimeWindow{Start: "09:00", End: "noon"}, true},
+		{"bad day", TimeWindow{Days: []string{"MONDAY"}, Start: "09:00", End: "10:00"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.window.Validate(); (err != nil) != test.wantErr {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestMenuIsActive(t *testing.T) {
+	holiday := monday(0, 0).Format("2006-01-02")
+	from, until := monday(0, 0), monday(0, 0).AddDate(0, 0, 7)
+	lunch := []TimeWindow{{Start: "12:00", End: "15:00"}}
+
+	tests := []struct {
+		name string
+		menu Menu
+		at   time.Time
+		want bool
+	}{
+		{"no schedule", Menu{}, monday(3, 0), true},
+		{"inside a daypart", Menu{Dayparts: lunch}, monday(13, 0), true},
+		{"outside every daypart", Menu{Dayparts: lunch}, monday(16, 0), false},
+		{"before the start date", Menu{Start_Date: &until}, monday(13, 0), false},
+		{"on the end date", Menu{End_Date: &from}, monday(13, 0), false},
+		{"closed override", Menu{Dayparts: lunch, Overrides: []ScheduleOverride{{Date: holiday, Closed: true}}}, monday(13, 0), false},
+		{"override windows replace dayparts", Menu{Dayparts: lunch, Overrides: []ScheduleOverride{{Date: holiday, Windows: []TimeWindow{{Start: "10:00", End: "12:00"}}}}}, monday(11, 0), true},
+		{"override without windows runs all day", Menu{Dayparts: lunch, Overrides: []ScheduleOverride{{Date: holiday}}}, monday(20, 0), true},
+		{"override on another date", Menu{Dayparts: lunch, Overrides: []ScheduleOverride{{Date: "2024-01-02", Closed: true}}}, monday(13, 0), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.menu.IsActive(test.at); got != test.want {
+				t.Fatalf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateScheduleRefusesDuplicateOverrides(t *testing.T) {
+	overrides := []ScheduleOverride{{Date: "2024-12-25", Closed: true}, {Date: "2024-12-25"}}
+	if err := ValidateSchedule(nil, overrides); err == nil {
+		t.Fatal("expected two overrides on one date to be refused")
+	}
+}
+
+func TestNormalizeScheduleUpperCasesDays(t *testing.T) {
+	windows := []TimeWindow{{Days: []string{"mon", "Fri"}, Start: "07:00", End: "11:00"}}
+	overrides := []ScheduleOverride{{Date: "2024-12-25", Windows: []TimeWindow{{Days: []string{"wed"}, Start: "10:00", End: "12:00"}}}}
+	NormalizeSchedule(windows, overrides)
+
+	if windows[0].Days[0] != "MON" || windows[0].Days[1] != "FRI" || overrides[0].Windows[0].Days[0] != "WED" {
+		t.Fatalf("expected upper-cased days, got %v and %v", windows[0].Days, overrides[0].Windows[0].Days)
+	}
+}
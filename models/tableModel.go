@@ -6,11 +6,38 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	TABLE_FREE             = "FREE"
+	TABLE_OCCUPIED         = "OCCUPIED"
+	TABLE_AWAITING_PAYMENT = "AWAITING_PAYMENT"
+	TABLE_CLEANING         = "CLEANING"
+)
+
+// SeatingClaim is held on a table while a party is being seated at it or
+// booked onto it, so two hosts cannot take it at once.
+type SeatingClaim struct {
+	Claim_id string    `bson:"claim_id"`
+	At       time.Time `bson:"at"`
+}
+
+// Status is never stored; it is worked out from the table's open order, its
+// unpaid invoice and the cleaning flag each time the table is read.
 type Table struct {
 	ID               primitive.ObjectID `bson:"_id"`
 	Number_of_guests *int               `json:"number_of_guests" validate:"required"`
 	Table_number     *int               `json:"table_number" validate:"required"`
+	Section          *string            `json:"section"`
+	Position_x       *float64           `json:"position_x"`
+	Position_y       *float64           `json:"position_y"`
+	Server_id        *string            `json:"server_id"`
+	Needs_cleaning   *bool              `json:"needs_cleaning"`
+	Seating_claim    *SeatingClaim      `json:"-" bson:"seating_claim,omitempty"`
+	Status           string             `json:"status" bson:"-"`
 	Created_at       time.Time          `json:"created_at"`
 	Updated_at       time.Time          `json:"updated_at"`
 	Table_id         string             `json:"table_id"`
 }
+
+func (table Table) NeedsCleaning() bool {
+	return table.Needs_cleaning != nil && *table.Needs_cleaning
+}
@@ -6,6 +6,23 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	ROLE_OWNER   = "OWNER"
+	ROLE_MANAGER = "MANAGER"
+	ROLE_WAITER  = "WAITER"
+	ROLE_KITCHEN = "KITCHEN"
+	ROLE_CASHIER = "CASHIER"
+)
+
+// roleRanks orders the roles for actions one user takes on another; the
+// staff roles share a rank below management.
+var roleRanks = map[string]int{ROLE_OWNER: 2, ROLE_MANAGER: 1}
+
+// Outranks reports whether role sits above other.
+func Outranks(role string, other string) bool {
+	return roleRanks[role] > roleRanks[other]
+}
+
 type User struct {
 	ID            primitive.ObjectID `bson:"_id"`
 	First_name    *string            `json:"first_name" validate:"required,min=2,max=100"`
@@ -14,6 +31,7 @@ type User struct {
 	Email         *string            `json:"email" validate:"email,required"`
 	Avatar        *string            `json:"avatar"`
 	Phone         *string            `json:"phone" validate:"required"`
+	Role          *string            `json:"role" validate:"omitempty,eq=OWNER|eq=MANAGER|eq=WAITER|eq=KITCHEN|eq=CASHIER"`
 	Token         *string            `json:"token"`
 	Refresh_Token *string            `json:"refresh_token"`
 	Created_at    time.Time          `json:"created_at"`
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	WAITLIST_WAITING = "WAITING"
+	WAITLIST_SEATED  = "SEATED"
+	WAITLIST_LEFT    = "LEFT"
+)
+
+type WaitlistEntry struct {
+	ID                  primitive.ObjectID `bson:"_id"`
+	Waitlist_id         string             `json:"waitlist_id"`
+	Party_name          *string            `json:"party_name" validate:"required,min=1,max=100"`
+	Party_size          *int               `json:"party_size" validate:"required,min=1"`
+	Phone               *string            `json:"phone" validate:"required"`
+	Notes               *string            `json:"notes"`
+	Status              *string            `json:"status" validate:"omitempty,eq=WAITING|eq=SEATED|eq=LEFT"`
+	Quoted_wait_minutes int                `json:"quoted_wait_minutes"`
+	Table_id            *string            `json:"table_id"`
+	Order_id            *string            `json:"order_id"`
+	Seated_at           *time.Time         `json:"seated_at"`
+	Created_at          time.Time          `json:"created_at"`
+	Updated_at          time.Time          `json:"updated_at"`
+}
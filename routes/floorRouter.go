@@ -0,0 +1,12 @@
+package routes
+
+import (
+	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func FloorRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/floor", floorStaff, controller.GetFloor(stores))
+}
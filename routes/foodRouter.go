@@ -2,13 +2,16 @@ package routes
 
 import (
 	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/store"
 
 	"github.com/gin-gonic/gin"
 )
 
-func FoodRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/foods", controller.GetFoods())
-	incomingRoutes.GET("/foods/:food_id", controller.GetFood())
-	incomingRoutes.POST("/foods", controller.CreateFood())
-	incomingRoutes.PATCH("/foods/:food_id", controller.UpdateFood())
+func FoodRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/foods", allStaff, controller.GetFoods(stores))
+	incomingRoutes.GET("/foods/:food_id", allStaff, controller.GetFood(stores))
+	incomingRoutes.POST("/foods", management, controller.CreateFood(stores))
+	incomingRoutes.PATCH("/foods/:food_id", management, controller.UpdateFood(stores))
+	incomingRoutes.POST("/foods/:food_id/86", serviceStaff, controller.EightySixFood(stores))
+	incomingRoutes.PATCH("/foods/:food_id/availability", kitchenStaff, controller.UpdateFoodAvailability(stores))
 }
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	controller "golang-restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func HealthRoutes(incomingRoutes *gin.Engine, client *mongo.Client) {
+	incomingRoutes.GET("/healthz", controller.Healthz(client))
+	incomingRoutes.GET("/readyz", controller.Readyz(client))
+}
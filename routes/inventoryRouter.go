@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InventoryRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/inventory", kitchenStaff, controller.GetInventory(stores))
+	incomingRoutes.GET("/inventory/low-stock", kitchenStaff, controller.GetLowStock(stores))
+	incomingRoutes.GET("/inventory/:ingredient_id/movements", kitchenStaff, controller.GetStockMovements(stores))
+	incomingRoutes.POST("/inventory/:ingredient_id/adjust", kitchenStaff, controller.AdjustStock(stores))
+	incomingRoutes.POST("/ingredients", management, controller.CreateIngredient(stores))
+	incomingRoutes.PATCH("/ingredients/:ingredient_id", management, controller.UpdateIngredient(stores))
+}
@@ -2,13 +2,20 @@ package routes
 
 import (
 	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/store"
 
 	"github.com/gin-gonic/gin"
 )
 
-func InvoiceRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/invoices", controller.GetInvoices())
-	incomingRoutes.GET("/invoices/:invoice_id", controller.GetInvoice())
-	incomingRoutes.POST("/invoices", controller.CreateInvoice())
-	incomingRoutes.PATCH("/invoices/:invoice_id", controller.UpdateInvoice())
+func InvoiceRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/invoices", billing, controller.GetInvoices(stores))
+	incomingRoutes.GET("/invoices/:invoice_id", billing, controller.GetInvoice(stores))
+	incomingRoutes.POST("/invoices", billing, controller.CreateInvoice(stores))
+	incomingRoutes.PATCH("/invoices/:invoice_id", cashDesk, controller.UpdateInvoice(stores))
+	incomingRoutes.GET("/invoices/:invoice_id/payments", billing, controller.GetPayments(stores))
+	incomingRoutes.POST("/invoices/:invoice_id/payments", billing, controller.CreatePayment(stores))
+	incomingRoutes.GET("/invoices/:invoice_id/split", billing, controller.SplitInvoice(stores))
+	incomingRoutes.GET("/invoices/:invoice_id/discounts", cashDesk, controller.GetDiscountAudit(stores))
+	incomingRoutes.POST("/invoices/:invoice_id/discounts", billing, controller.ApplyDiscount(stores))
+	incomingRoutes.DELETE("/invoices/:invoice_id/discounts/:discount_id", cashDesk, controller.RemoveDiscount(stores))
 }
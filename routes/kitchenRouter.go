@@ -0,0 +1,22 @@
+package routes
+
+import (
+	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/middleware"
+	"golang-restaurant-management/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KitchenStreamRoutes must be registered before Authentication is applied to
+// the router, since the stream authenticates itself to accept stream tickets.
+func KitchenStreamRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/kitchen/stream", middleware.StreamAuthentication(stores.Users), kitchenStaff, controller.StreamKitchenItems())
+}
+
+func KitchenRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/kitchen/items", kitchenStaff, controller.GetKitchenItems(stores))
+	incomingRoutes.POST("/kitchen/stream/ticket", kitchenStaff, controller.IssueKitchenStreamTicket())
+	incomingRoutes.POST("/kitchen/items/:order_item_id/bump", kitchenStaff, controller.BumpOrderItem(stores))
+	incomingRoutes.POST("/kitchen/items/:order_item_id/recall", kitchenStaff, controller.RecallOrderItem(stores))
+}
@@ -2,13 +2,15 @@ package routes
 
 import (
 	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/store"
 
 	"github.com/gin-gonic/gin"
 )
 
-func MenuRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/menus", controller.GetMenus())
-	incomingRoutes.GET("/menus/:menu_id", controller.GetMenu())
-	incomingRoutes.POST("/menus", controller.CreateMenu())
-	incomingRoutes.PATCH("/menus/:menu_id", controller.UpdateMenu())
+func MenuRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/menus", allStaff, controller.GetMenus(stores))
+	incomingRoutes.GET("/menus/active", allStaff, controller.GetActiveMenus(stores))
+	incomingRoutes.GET("/menus/:menu_id", allStaff, controller.GetMenu(stores))
+	incomingRoutes.POST("/menus", management, controller.CreateMenu(stores))
+	incomingRoutes.PATCH("/menus/:menu_id", management, controller.UpdateMenu(stores))
 }
@@ -0,0 +1,779 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang-restaurant-management/config"
+	helper "golang-restaurant-management/helpers"
+	appLogger "golang-restaurant-management/logger"
+	"golang-restaurant-management/middleware"
+	"golang-restaurant-management/models"
+	"golang-restaurant-management/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	appLogger.Log = logrus.New()
+	appLogger.Log.Out = io.Discard
+	helper.SECRET_KEY = "a-test-secret-that-is-long-enough"
+}
+
+// testRouter serves the routes on the given stores, with every request made
+// as the given role; it stands in for Authentication.
+func testRouter(stores *store.Store, role string) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("uid", "test-user")
+		c.Set("role", role)
+		c.Next()
+	})
+	TableRoutes(router, stores)
+	OrderRoutes(router, stores)
+	OrderItemRoutes(router, stores)
+	InvoiceRoutes(router, stores)
+	InventoryRoutes(router, stores)
+	PromotionRoutes(router, stores)
+	WaitlistRoutes(router, stores)
+	ReportRoutes(router, stores)
+	PricingRuleRoutes(router, stores)
+	UserRoutes(router, stores)
+	FoodRoutes(router, stores)
+	MenuRoutes(router, stores)
+	ReservationRoutes(router, stores)
+	return router
+}
+
+func send(t *testing.T, router *gin.Engine, method string, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload = bytes.NewReader(data)
+	}
+	request := httptest.NewRequest(method, path, payload)
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func expectStatus(t *testing.T, recorder *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if recorder.Code != status {
+		t.Fatalf("expected status %d, got %d: %s", status, recorder.Code, recorder.Body.String())
+	}
+}
+
+// seedMenu stores a table and a burger that uses 0.2 of an ingredient with
+// 10 in stock, and returns their ids.
+func seedMenu(t *testing.T, stores *store.Store) (tableId string, foodId string, ingredientId string) {
+	t.Helper()
+	ctx := context.Background()
+
+	ingredient := models.Ingredient{ID: primitive.NewObjectID(), Stock: 10}
+	ingredient.Ingredient_id = ingredient.ID.Hex()
+	name, unit := "Beef patty", "kg"
+	ingredient.Name, ingredient.Unit = &name, &unit
+	if _, err := stores.Ingredients.Insert(ctx, ingredient); err != nil {
+		t.Fatal(err)
+	}
+
+	menu := models.Menu{ID: primitive.NewObjectID(), Name: "Mains", Category: "MAINS"}
+	menu.Menu_id = menu.ID.Hex()
+	if _, err := stores.Menus.Insert(ctx, menu); err != nil {
+		t.Fatal(err)
+	}
+
+	food := models.Food{ID: primitive.NewObjectID(), Menu_id: &menu.Menu_id}
+	food.Food_id = food.ID.Hex()
+	foodName, price := "Burger", models.NewMoney(1250)
+	food.Name, food.Price = &foodName, &price
+	food.Recipe = []models.RecipeLine{{Ingredient_id: ingredient.Ingredient_id, Quantity: 0.2}}
+	if _, err := stores.Foods.Insert(ctx, food); err != nil {
+		t.Fatal(err)
+	}
+
+	table := models.Table{ID: primitive.NewObjectID()}
+	table.Table_id = table.ID.Hex()
+	guests, number := 4, 1
+	table.Number_of_guests, table.Table_number = &guests, &number
+	if _, err := stores.Tables.Insert(ctx, table); err != nil {
+		t.Fatal(err)
+	}
+
+	return table.Table_id, food.Food_id, ingredient.Ingredient_id
+}
+
+func TestOrderToPaymentFlow(t *testing.T) {
+	stores := store.NewMemory()
+	router := testRouter(stores, models.ROLE_MANAGER)
+	tableId, foodId, ingredientId := seedMenu(t, stores)
+	ctx := context.Background()
+
+	recorder := send(t, router, http.MethodPost, "/orderItems", gin.H{
+		"table_id":    tableId,
+		"order_items": []gin.H{{"food_id": foodId, "quantity": 2}},
+	})
+	expectStatus(t, recorder, http.StatusOK)
+	var inserted struct{ InsertedIDs []string }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &inserted); err != nil || len(inserted.InsertedIDs) != 1 {
+		t.Fatalf("expected one order item, got %s", recorder.Body.String())
+	}
+
+	orderItem, err := stores.OrderItems.Get(ctx, inserted.InsertedIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	orderId := orderItem.Order_id
+	if orderItem.Unit_price.Amount != 1250 {
+		t.Fatalf("expected the item priced at 12.50, got %s", orderItem.Unit_price)
+	}
+
+	ingredient, err := stores.Ingredients.Get(ctx, ingredientId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ingredient.Stock != 9.6 {
+		t.Fatalf("expected stock 9.6 after two burgers, got %v", ingredient.Stock)
+	}
+
+	recorder = send(t, router, http.MethodPost, "/invoices", gin.H{"order_id": orderId, "payment_method": "CARD"})
+	expectStatus(t, recorder, http.StatusOK)
+	var created struct{ InsertedID string }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	invoice, err := stores.Invoices.Get(ctx, created.InsertedID)
+	if err != nil {
+		t.Fatalf("invoice %q was not stored: %v", created.InsertedID, err)
+	}
+	if invoice.Subtotal.Amount != 2500 {
+		t.Fatalf("expected a subtotal of 25.00, got %s", invoice.Subtotal)
+	}
+
+	// half now, the rest later
+	half := models.NewMoney(invoice.Total.Amount / 2)
+	rest := invoice.Total.Sub(half)
+	path := "/invoices/" + invoice.Invoice_id + "/payments"
+
+	recorder = send(t, router, http.MethodPost, path, gin.H{"amount": gin.H{"amount": 5, "currency": "EUR"}, "payment_method": "CARD"})
+	expectStatus(t, recorder, http.StatusBadRequest)
+
+	recorder = send(t, router, http.MethodPost, path, gin.H{"amount": half, "payment_method": "CARD"})
+	expectStatus(t, recorder, http.StatusOK)
+
+	recorder = send(t, router, http.MethodPost, path, gin.H{"amount": invoice.Total, "payment_method": "CASH"})
+	expectStatus(t, recorder, http.StatusBadRequest)
+
+	recorder = send(t, router, http.MethodPost, path, gin.H{"amount": rest, "payment_method": "CASH"})
+	expectStatus(t, recorder, http.StatusOK)
+
+	invoice, err = stores.Invoices.Get(ctx, invoice.Invoice_id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *invoice.Payment_status != models.PAYMENT_PAID {
+		t.Fatalf("expected the invoice to be PAID, got %s", *invoice.Payment_status)
+	}
+
+	recorder = send(t, router, http.MethodGet, path, nil)
+	expectStatus(t, recorder, http.StatusOK)
+	var payments []models.Payment
+	if err := json.Unmarshal(recorder.Body.Bytes(), &payments); err != nil {
+		t.Fatal(err)
+	}
+	if len(payments) != 2 {
+		t.Fatalf("expected two payments, got %d", len(payments))
+	}
+
+	path = "/invoices/" + invoice.Invoice_id
+	expectStatus(t, send(t, router, http.MethodPatch, path, gin.H{"payment_status": "REFUNDED"}), http.StatusBadRequest)
+	expectStatus(t, send(t, router, http.MethodPatch, path, gin.H{"payment_status": "PENDING"}), http.StatusConflict)
+	expectStatus(t, send(t, router, http.MethodPatch, path, gin.H{"payment_method": "CASH"}), http.StatusOK)
+	expectStatus(t, send(t, router, http.MethodPatch, "/invoices/missing", gin.H{"payment_method": "CASH"}), http.StatusNotFound)
+
+	invoice, err = stores.Invoices.Get(ctx, invoice.Invoice_id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *invoice.Payment_status != models.PAYMENT_PAID || *invoice.Payment_method != "CASH" {
+		t.Fatalf("expected a PAID cash invoice, got %s by %s", *invoice.Payment_status, *invoice.Payment_method)
+	}
+}
+
+func TestOrderItemForUnknownFood(t *testing.T) {
+	stores := store.NewMemory()
+	router := testRouter(stores, models.ROLE_WAITER)
+	tableId, _, _ := seedMenu(t, stores)
+
+	recorder := send(t, router, http.MethodPost, "/orderItems", gin.H{
+		"table_id":    tableId,
+		"order_items": []gin.H{{"food_id": "missing", "quantity": 1}},
+	})
+	expectStatus(t, recorder, http.StatusBadRequest)
+
+	orders, err := stores.Orders.All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 0 {
+		t.Fatalf("expected no order to be left behind, got %d", len(orders))
+	}
+}
+
+func TestOrderTransitionsByRole(t *testing.T) {
+	stores := store.NewMemory()
+	tableId, foodId, _ := seedMenu(t, stores)
+	waiter := testRouter(stores, models.ROLE_WAITER)
+	kitchen := testRouter(stores, models.ROLE_KITCHEN)
+	cashier := testRouter(stores, models.ROLE_CASHIER)
+
+	recorder := send(t, waiter, http.MethodPost, "/orderItems", gin.H{
+		"table_id":    tableId,
+		"order_items": []gin.H{{"food_id": foodId, "quantity": 1}},
+	})
+	expectStatus(t, recorder, http.StatusOK)
+	var inserted struct{ InsertedIDs []string }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &inserted); err != nil || len(inserted.InsertedIDs) != 1 {
+		t.Fatalf("expected one order item, got %s", recorder.Body.String())
+	}
+	orderItem, err := stores.OrderItems.Get(context.Background(), inserted.InsertedIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/orders/" + orderItem.Order_id + "/transition"
+
+	expectStatus(t, send(t, kitchen, http.MethodPost, path, gin.H{"status": models.ORDER_CANCELLED}), http.StatusForbidden)
+	expectStatus(t, send(t, cashier, http.MethodPost, path, gin.H{"status": models.ORDER_CANCELLED}), http.StatusForbidden)
+	expectStatus(t, send(t, waiter, http.MethodPost, path, gin.H{"status": models.ORDER_ACCEPTED}), http.StatusForbidden)
+	expectStatus(t, send(t, kitchen, http.MethodPost, path, gin.H{"status": models.ORDER_ACCEPTED}), http.StatusOK)
+	expectStatus(t, send(t, waiter, http.MethodPost, path, gin.H{"status": models.ORDER_CANCELLED}), http.StatusOK)
+}
+
+func TestKitchenCannotTakePayment(t *testing.T) {
+	stores := store.NewMemory()
+	router := testRouter(stores, models.ROLE_KITCHEN)
+
+	recorder := send(t, router, http.MethodPost, "/invoices/any/payments", gin.H{"payment_method": "CASH"})
+	expectStatus(t, recorder, http.StatusForbidden)
+}
+
+func TestChangingQuantityRedepletesStock(t *testing.T) {
+	stores := store.NewMemory()
+	router := testRouter(stores, models.ROLE_WAITER)
+	tableId, foodId, ingredientId := seedMenu(t, stores)
+	ctx := context.Background()
+
+	recorder := send(t, router, http.MethodPost, "/orderItems", gin.H{
+		"table_id":    tableId,
+		"order_items": []gin.H{{"food_id": foodId, "quantity": 2}},
+	})
+	expectStatus(t, recorder, http.StatusOK)
+	var inserted struct{ InsertedIDs []string }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &inserted); err != nil || len(inserted.InsertedIDs) != 1 {
+		t.Fatalf("expected one order item, got %s", recorder.Body.String())
+	}
+	path := "/orderItems/" + inserted.InsertedIDs[0]
+
+	expectStock := func(want float64) {
+		t.Helper()
+		ingredient, err := stores.Ingredients.Get(ctx, ingredientId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(ingredient.Stock-want) > 1e-9 {
+			t.Fatalf("expected stock %v, got %v", want, ingredient.Stock)
+		}
+	}
+
+	expectStatus(t, send(t, router, http.MethodGet, path, nil), http.StatusOK)
+
+	expectStatus(t, send(t, router, http.MethodPatch, path, gin.H{"quantity": 3}), http.StatusOK)
+	expectStock(9.4)
+
+	expectStatus(t, send(t, router, http.MethodPatch, path, gin.H{"quantity": 1}), http.StatusOK)
+	expectStock(9.8)
+
+	// a waiter cannot reprice a line; that is a manual discount on the invoice
+	expectStatus(t, send(t, router, http.MethodPatch, path, gin.H{"unit_price": 0}), http.StatusBadRequest)
+	if orderItem, _ := stores.OrderItems.Get(ctx, inserted.InsertedIDs[0]); orderItem.Unit_price.Amount != 1250 {
+		t.Fatalf("expected the price to stay 12.50, got %s", orderItem.Unit_price)
+	}
+
+	expectStatus(t, send(t, router, http.MethodPatch, "/orderItems/missing", gin.H{"quantity": 1}), http.StatusNotFound)
+	if _, err := stores.OrderItems.Get(ctx, "missing"); err != store.ErrNotFound {
+		t.Fatalf("expected no order item to be created, got %v", err)
+	}
+
+	// once cancelled the stock is back and the item is settled
+	orderItem, err := stores.OrderItems.Get(ctx, inserted.InsertedIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, send(t, router, http.MethodPost, "/orders/"+orderItem.Order_id+"/transition", gin.H{"status": models.ORDER_CANCELLED}), http.StatusOK)
+	expectStock(10)
+	expectStatus(t, send(t, router, http.MethodPatch, path, gin.H{"quantity": 4}), http.StatusConflict)
+	expectStock(10)
+}
+
+func TestDiscountsUseTheBilledSubtotal(t *testing.T) {
+	stores := store.NewMemory()
+	router := testRouter(stores, models.ROLE_MANAGER)
+	tableId, foodId, _ := seedMenu(t, stores)
+	ctx := context.Background()
+
+	recorder := send(t, router, http.MethodPost, "/orderItems", gin.H{
+		"table_id":    tableId,
+		"order_items": []gin.H{{"food_id": foodId, "quantity": 2}},
+	})
+	expectStatus(t, recorder, http.StatusOK)
+	var inserted struct{ InsertedIDs []string }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &inserted); err != nil || len(inserted.InsertedIDs) != 1 {
+		t.Fatalf("expected one order item, got %s", recorder.Body.String())
+	}
+	orderItem, err := stores.OrderItems.Get(ctx, inserted.InsertedIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder = send(t, router, http.MethodPost, "/invoices", gin.H{"order_id": orderItem.Order_id, "payment_method": "CARD"})
+	expectStatus(t, recorder, http.StatusOK)
+	var created struct{ InsertedID string }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	path := "/invoices/" + created.InsertedID + "/discounts"
+
+	recorder = send(t, router, http.MethodPost, path, gin.H{"percentage_off": 10, "reason": "Birthday"})
+	expectStatus(t, recorder, http.StatusOK)
+	invoice, err := stores.Invoices.Get(ctx, created.InsertedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Subtotal.Amount != 2500 || invoice.Discount.Amount != 250 || invoice.Total.Amount != 2250 || invoice.Version != 2 {
+		t.Fatalf("expected 25.00 less 2.50 at version 2, got %s less %s = %s at version %d",
+			invoice.Subtotal, invoice.Discount, invoice.Total, invoice.Version)
+	}
+
+	recorder = send(t, router, http.MethodDelete, path+"/"+invoice.Discounts[0].Discount_id, nil)
+	expectStatus(t, recorder, http.StatusOK)
+	invoice, err = stores.Invoices.Get(ctx, created.InsertedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Total.Amount != 2500 || invoice.Version != 3 {
+		t.Fatalf("expected 25.00 at version 3, got %s at version %d", invoice.Total, invoice.Version)
+	}
+
+	// the order grows after billing, so its items no longer match the invoice
+	expectStatus(t, send(t, router, http.MethodPatch, "/orderItems/"+orderItem.Order_item_id, gin.H{"quantity": 3}), http.StatusOK)
+	expectStatus(t, send(t, router, http.MethodPost, path, gin.H{"percentage_off": 10, "reason": "Birthday"}), http.StatusConflict)
+}
+
+func TestPromotionCodesAreUnique(t *testing.T) {
+	stores := store.NewMemory()
+	router := testRouter(stores, models.ROLE_MANAGER)
+
+	promotion := gin.H{"name": "Spring", "kind": models.PROMO_ORDER, "code": "spring10", "percentage_off": 10}
+	expectStatus(t, send(t, router, http.MethodPost, "/promotions", promotion), http.StatusOK)
+	expectStatus(t, send(t, router, http.MethodPost, "/promotions", promotion), http.StatusConflict)
+
+	// the store refuses the duplicate on its own, as the index does in MongoDB
+	code := "SPRING10"
+	duplicate := models.Promotion{ID: primitive.NewObjectID(), Code: &code}
+	duplicate.Promotion_id = duplicate.ID.Hex()
+	if _, err := stores.Promotions.Insert(context.Background(), duplicate); err != store.ErrDuplicate {
+		t.Fatalf("expected ErrDuplicate, got %v", err)
+	}
+}
+
+func TestWaiterMarksTableClean(t *testing.T) {
+	stores := store.NewMemory()
+	router := testRouter(stores, models.ROLE_WAITER)
+	tableId, _, _ := seedMenu(t, stores)
+	ctx := context.Background()
+
+	if _, err := stores.Tables.Update(ctx, tableId, store.Patch{Set: bson.M{"needs_cleaning": true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectStatus(t, send(t, router, http.MethodPost, "/tables/"+tableId+"/clean", nil), http.StatusOK)
+	table, err := stores.Tables.Get(ctx, tableId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.NeedsCleaning() {
+		t.Fatal("expected the table to be clean")
+	}
+
+	expectStatus(t, send(t, router, http.MethodPost, "/tables/"+tableId+"/clean", nil), http.StatusOK)
+	expectStatus(t, send(t, router, http.MethodPost, "/tables/missing/clean", nil), http.StatusNotFound)
+}
+
+func TestSeatingFromWaitlistClaimsTheTable(t *testing.T) {
+	stores := store.NewMemory()
+	router := testRouter(stores, models.ROLE_WAITER)
+	tableId, _, _ := seedMenu(t, stores)
+	ctx := context.Background()
+
+	joinWaitlist := func(name string) string {
+		t.Helper()
+		recorder := send(t, router, http.MethodPost, "/waitlist", gin.H{"party_name": name, "party_size": 2, "phone": "555-0100"})
+		expectStatus(t, recorder, http.StatusOK)
+		var entry models.WaitlistEntry
+		if err := json.Unmarshal(recorder.Body.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		return entry.Waitlist_id
+	}
+	first, second := joinWaitlist("Ada"), joinWaitlist("Grace")
+
+	// a booking due in half an hour keeps walk-ins off the only table
+	start, status := time.Now().Add(30*time.Minute), models.RESERVATION_BOOKED
+	reservation := models.Reservation{ID: primitive.NewObjectID(), Table_id: &tableId, Start_time: &start, End_time: start.Add(90 * time.Minute), Status: &status}
+	reservation.Reservation_id = reservation.ID.Hex()
+	if _, err := stores.Reservations.Insert(ctx, reservation); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, send(t, router, http.MethodPost, "/waitlist/"+first+"/seat", nil), http.StatusConflict)
+
+	if err := stores.Reservations.Delete(ctx, reservation.Reservation_id); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, send(t, router, http.MethodPost, "/waitlist/"+first+"/seat", nil), http.StatusOK)
+	expectStatus(t, send(t, router, http.MethodPost, "/waitlist/"+second+"/seat", gin.H{"table_id": tableId}), http.StatusConflict)
+
+	// seating lets go of the table once the order holds it
+	if err := stores.Tables.Claim(ctx, tableId, "other-host", time.Now(), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("expected the seating claim to be released, got %v", err)
+	}
+	if err := stores.Tables.Claim(ctx, tableId, "third-host", time.Now(), time.Now().Add(-time.Minute)); err != store.ErrNotFound {
+		t.Fatalf("expected a live claim to hold the table, got %v", err)
+	}
+}
+
+func TestMarginReportListsUnpricedFoods(t *testing.T) {
+	stores := store.NewMemory()
+	router := testRouter(stores, models.ROLE_MANAGER)
+	_, foodId, _ := seedMenu(t, stores)
+
+	if _, err := stores.Foods.Update(context.Background(), foodId, store.Patch{Unset: []string{"price"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := send(t, router, http.MethodGet, "/reports/margins", nil)
+	expectStatus(t, recorder, http.StatusOK)
+	var report struct {
+		Unpriced []struct {
+			Food_id string `json:"food_id"`
+		} `json:"unpriced"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Unpriced) != 1 || report.Unpriced[0].Food_id != foodId {
+		t.Fatalf("expected the burger to be listed as unpriced, got %s", recorder.Body.String())
+	}
+}
+
+func TestFixedPriceNeverRaisesThePrice(t *testing.T) {
+	stores := store.NewMemory()
+	router := testRouter(stores, models.ROLE_MANAGER)
+	tableId, foodId, _ := seedMenu(t, stores)
+	ctx := context.Background()
+
+	// the higher priority rule would charge more than the menu price
+	expectStatus(t, send(t, router, http.MethodPost, "/pricing-rules", gin.H{
+		"name": "Set price", "kind": "fixed", "fixed_price": 20, "food_ids": []string{foodId}, "priority": 5,
+	}), http.StatusOK)
+	expectStatus(t, send(t, router, http.MethodPost, "/pricing-rules", gin.H{
+		"name": "Tenth off", "kind": "PERCENTAGE", "percentage_off": 10, "food_ids": []string{foodId},
+	}), http.StatusOK)
+	// days are accepted in any case and kept upper-cased
+	expectStatus(t, send(t, router, http.MethodPost, "/pricing-rules", gin.H{
+		"name": "Breakfast", "kind": "PERCENTAGE", "percentage_off": 5, "food_ids": []string{"other"},
+		"windows": []gin.H{{"days": []string{"mon", "Tue"}, "start": "07:00", "end": "11:00"}},
+	}), http.StatusOK)
+
+	recorder := send(t, router, http.MethodPost, "/orderItems", gin.H{
+		"table_id":    tableId,
+		"order_items": []gin.H{{"food_id": foodId, "quantity": 1}},
+	})
+	expectStatus(t, recorder, http.StatusOK)
+	var inserted struct{ InsertedIDs []string }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &inserted); err != nil || len(inserted.InsertedIDs) != 1 {
+		t.Fatalf("expected one order item, got %s", recorder.Body.String())
+	}
+	orderItem, err := stores.OrderItems.Get(ctx, inserted.InsertedIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orderItem.Unit_price.Amount != 1125 || orderItem.Pricing_rule == nil || orderItem.Pricing_rule.Name != "Tenth off" {
+		t.Fatalf("expected the tenth off to apply at 11.25, got %s with %+v", orderItem.Unit_price, orderItem.Pricing_rule)
+	}
+
+	recorder = send(t, router, http.MethodGet, "/pricing-rules", nil)
+	expectStatus(t, recorder, http.StatusOK)
+	if !bytes.Contains(recorder.Body.Bytes(), []byte(`"days":["MON","TUE"]`)) {
+		t.Fatalf("expected the days stored upper-cased, got %s", recorder.Body.String())
+	}
+}
+
+// signUp signs a user up and returns their id and a token for them with the
+// given role.
+func signUp(t *testing.T, router *gin.Engine, stores *store.Store, email string, phone string, role string) (string, string) {
+	t.Helper()
+	recorder := send(t, router, http.MethodPost, "/users/signup", gin.H{
+		"first_name": "Test", "last_name": "User", "Password": "secret1", "email": email, "phone": phone,
+	})
+	expectStatus(t, recorder, http.StatusOK)
+	var created struct{ InsertedID string }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	token, refreshToken, err := helper.GenerateAllTokens(email, "Test", "User", created.InsertedID, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stores.Users.Update(context.Background(), created.InsertedID, store.Patch{
+		Set: bson.M{"role": role, "token": token, "refresh_token": refreshToken},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return created.InsertedID, token
+}
+
+func TestUserLookupHidesSecrets(t *testing.T) {
+	stores := store.NewMemory()
+	router := testRouter(stores, "")
+	ownerId, _ := signUp(t, router, stores, "owner@example.com", "555-0001", models.ROLE_OWNER)
+	_, managerToken := signUp(t, router, stores, "manager@example.com", "555-0002", models.ROLE_MANAGER)
+
+	request := httptest.NewRequest(http.MethodGet, "/users/"+ownerId, nil)
+	request.Header.Set("token", managerToken)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	expectStatus(t, recorder, http.StatusOK)
+
+	var user map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"token", "refresh_token", "Password"} {
+		if user[field] != nil {
+			t.Fatalf("expected %s to be hidden, got %s", field, recorder.Body.String())
+		}
+	}
+}
+
+func TestSignUpNeverMakesAnOwner(t *testing.T) {
+	config.Settings.Auth.Owner_email = "owner@example.com"
+	defer func() { config.Settings.Auth.Owner_email = "" }()
+
+	stores := store.NewMemory()
+	router := testRouter(stores, "")
+	expectStatus(t, send(t, router, http.MethodPost, "/users/signup", gin.H{
+		"first_name": "Test", "last_name": "User", "Password": "secret1", "email": "owner@example.com", "phone": "555-0001",
+	}), http.StatusOK)
+	user, err := stores.Users.ByEmail(context.Background(), "owner@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *user.Role != models.ROLE_WAITER {
+		t.Fatalf("expected a waiter, got %s", *user.Role)
+	}
+
+	// the same email with a fresh phone number is still the same account
+	expectStatus(t, send(t, router, http.MethodPost, "/users/signup", gin.H{
+		"first_name": "Test", "last_name": "User", "Password": "secret1", "email": "owner@example.com", "phone": "555-0099",
+	}), http.StatusConflict)
+}
+
+func TestSwappingFoodChecksAvailabilityAndAllergies(t *testing.T) {
+	stores := store.NewMemory()
+	router := testRouter(stores, models.ROLE_WAITER)
+	tableId, foodId, _ := seedMenu(t, stores)
+	ctx := context.Background()
+
+	burger, err := stores.Foods.Get(ctx, foodId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addFood := func(name string, available bool, allergens ...string) string {
+		t.Helper()
+		food := models.Food{ID: primitive.NewObjectID(), Menu_id: burger.Menu_id, Price: burger.Price, Available: &available, Allergens: allergens}
+		food.Food_id = food.ID.Hex()
+		food.Name = &name
+		if _, err := stores.Foods.Insert(ctx, food); err != nil {
+			t.Fatal(err)
+		}
+		return food.Food_id
+	}
+	soldOut := addFood("Special", false)
+	satay := addFood("Satay", true, "PEANUTS")
+
+	recorder := send(t, router, http.MethodPost, "/orderItems", gin.H{
+		"table_id":    tableId,
+		"order_items": []gin.H{{"food_id": foodId, "quantity": 1}},
+		"allergies":   []gin.H{{"allergen": "peanuts", "severity": models.ALLERGY_SEVERE}},
+	})
+	expectStatus(t, recorder, http.StatusOK)
+	var inserted struct{ InsertedIDs []string }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &inserted); err != nil || len(inserted.InsertedIDs) != 1 {
+		t.Fatalf("expected one order item, got %s", recorder.Body.String())
+	}
+	path := "/orderItems/" + inserted.InsertedIDs[0]
+
+	expectStatus(t, send(t, router, http.MethodPatch, path, gin.H{"food_id": soldOut}), http.StatusConflict)
+	expectStatus(t, send(t, router, http.MethodPatch, path, gin.H{"food_id": satay}), http.StatusUnprocessableEntity)
+
+	orderItem, err := stores.OrderItems.Get(ctx, inserted.InsertedIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *orderItem.Food_id != foodId {
+		t.Fatalf("expected the burger to stay on the order, got %s", *orderItem.Food_id)
+	}
+}
+
+func TestPatchingUnknownRecordsCreatesNothing(t *testing.T) {
+	stores := store.NewMemory()
+	router := testRouter(stores, models.ROLE_MANAGER)
+	ctx := context.Background()
+
+	tests := []struct {
+		path   string
+		body   gin.H
+		stored func() error
+	}{
+		{"/orders/missing", gin.H{"allergies": []gin.H{}}, func() error { _, err := stores.Orders.Get(ctx, "missing"); return err }},
+		{"/foods/missing", gin.H{"name": "Ghost"}, func() error { _, err := stores.Foods.Get(ctx, "missing"); return err }},
+		{"/menus/missing", gin.H{"name": "Ghost"}, func() error { _, err := stores.Menus.Get(ctx, "missing"); return err }},
+		{"/tables/missing", gin.H{"number_of_guests": 2}, func() error { _, err := stores.Tables.Get(ctx, "missing"); return err }},
+	}
+	for _, test := range tests {
+		expectStatus(t, send(t, router, http.MethodPatch, test.path, test.body), http.StatusNotFound)
+		if err := test.stored(); err != store.ErrNotFound {
+			t.Fatalf("%s: expected nothing to be created, got %v", test.path, err)
+		}
+	}
+}
+
+func TestBookingWaitsForAHeldTable(t *testing.T) {
+	stores := store.NewMemory()
+	router := testRouter(stores, models.ROLE_WAITER)
+	tableId, _, _ := seedMenu(t, stores)
+	ctx := context.Background()
+
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Minute).Format(time.RFC3339)
+	booking := gin.H{"party_size": 2, "start_time": start, "table_id": tableId, "customer_name": "Ada", "customer_phone": "555-0100"}
+
+	// another host is halfway through booking or seating the table
+	if err := stores.Tables.Claim(ctx, tableId, "other-host", time.Now(), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, send(t, router, http.MethodPost, "/reservations", booking), http.StatusConflict)
+
+	if err := stores.Tables.Release(ctx, tableId, "other-host"); err != nil {
+		t.Fatal(err)
+	}
+	recorder := send(t, router, http.MethodPost, "/reservations", booking)
+	expectStatus(t, recorder, http.StatusOK)
+	var reservation models.Reservation
+	if err := json.Unmarshal(recorder.Body.Bytes(), &reservation); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stores.Tables.Claim(ctx, tableId, "other-host", time.Now(), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	path := "/reservations/" + reservation.Reservation_id
+	expectStatus(t, send(t, router, http.MethodPatch, path, gin.H{"duration_minutes": 120}), http.StatusConflict)
+	// a change that keeps the slot needs no hold
+	expectStatus(t, send(t, router, http.MethodPatch, path, gin.H{"notes": "window seat"}), http.StatusOK)
+}
+
+// streamRecorder lets gin stream into a recorder, which has no CloseNotify.
+type streamRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (streamRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func TestKitchenStreamAcceptsATicket(t *testing.T) {
+	stores := store.NewMemory()
+	router := gin.New()
+	UserRoutes(router, stores)
+	KitchenStreamRoutes(router, stores)
+	router.Use(middleware.Authentication(stores.Users))
+	KitchenRoutes(router, stores)
+
+	cookId, cookToken := signUp(t, router, stores, "cook@example.com", "555-0001", models.ROLE_KITCHEN)
+	_, waiterToken := signUp(t, router, stores, "waiter@example.com", "555-0002", models.ROLE_WAITER)
+
+	issue := func(token string) string {
+		t.Helper()
+		request := httptest.NewRequest(http.MethodPost, "/kitchen/stream/ticket", nil)
+		request.Header.Set("token", token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		expectStatus(t, recorder, http.StatusOK)
+		var issued struct{ Ticket string }
+		if err := json.Unmarshal(recorder.Body.Bytes(), &issued); err != nil {
+			t.Fatal(err)
+		}
+		return issued.Ticket
+	}
+	stream := func(query string) *httptest.ResponseRecorder {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		request := httptest.NewRequest(http.MethodGet, "/kitchen/stream?station=GRILL"+query, nil).WithContext(ctx)
+		recorder := streamRecorder{httptest.NewRecorder()}
+		router.ServeHTTP(recorder, request)
+		return recorder.ResponseRecorder
+	}
+
+	ticket := issue(cookToken)
+	recorder := stream("&ticket=" + ticket)
+	// the stream stays open until the request ends, with nothing to send yet
+	expectStatus(t, recorder, http.StatusOK)
+	if recorder.Body.Len() != 0 {
+		t.Fatalf("expected an open stream, got %s", recorder.Body.String())
+	}
+
+	// waiters may sign in but not watch the kitchen
+	waiterRequest := httptest.NewRequest(http.MethodPost, "/kitchen/stream/ticket", nil)
+	waiterRequest.Header.Set("token", waiterToken)
+	waiterRecorder := httptest.NewRecorder()
+	router.ServeHTTP(waiterRecorder, waiterRequest)
+	expectStatus(t, waiterRecorder, http.StatusForbidden)
+
+	expectStatus(t, stream(""), http.StatusInternalServerError)
+	expectStatus(t, stream("&ticket="+cookToken), http.StatusInternalServerError)
+	expectStatus(t, stream("&ticket=not-a-ticket"), http.StatusInternalServerError)
+
+	if err := helper.RevokeAllTokens(stores.Users, cookId); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, stream("&ticket="+ticket), http.StatusInternalServerError)
+}
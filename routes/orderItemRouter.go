@@ -2,14 +2,15 @@ package routes
 
 import (
 	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/store"
 
 	"github.com/gin-gonic/gin"
 )
 
-func OrderItemRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/orderItems", controller.GetOrderItems())
-	incomingRoutes.GET("/orderItems/:orderItem_id", controller.GetOrderItem())
-	incomingRoutes.GET("/orderItems-order/:order_id", controller.GetOrderItemsByOrder())
-	incomingRoutes.POST("/orderItems", controller.CreateOrderItem())
-	incomingRoutes.PATCH("/orderItems/:orderItem_id", controller.UpdateOrderItem())
+func OrderItemRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/orderItems", allStaff, controller.GetOrderItems(stores))
+	incomingRoutes.GET("/orderItems/:order_item_id", allStaff, controller.GetOrderItem(stores))
+	incomingRoutes.GET("/orderItems-order/:order_id", allStaff, controller.GetOrderItemsByOrder(stores))
+	incomingRoutes.POST("/orderItems", floorStaff, controller.CreateOrderItem(stores))
+	incomingRoutes.PATCH("/orderItems/:order_item_id", floorStaff, controller.UpdateOrderItem(stores))
 }
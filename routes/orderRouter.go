@@ -2,13 +2,15 @@ package routes
 
 import (
 	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/store"
 
 	"github.com/gin-gonic/gin"
 )
 
-func OrderRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/orders", controller.GetOrders())
-	incomingRoutes.GET("/orders/:order_id", controller.GetOrder())
-	incomingRoutes.POST("/orders", controller.CreateOrder())
-	incomingRoutes.PATCH("/orders/:order_id", controller.UpdateOrder())
+func OrderRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/orders", allStaff, controller.GetOrders(stores))
+	incomingRoutes.GET("/orders/:order_id", allStaff, controller.GetOrder(stores))
+	incomingRoutes.POST("/orders", floorStaff, controller.CreateOrder(stores))
+	incomingRoutes.PATCH("/orders/:order_id", floorStaff, controller.UpdateOrder(stores))
+	incomingRoutes.POST("/orders/:order_id/transition", serviceStaff, controller.TransitionOrder(stores))
 }
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PricingRuleRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/pricing-rules", management, controller.GetPricingRules(stores))
+	incomingRoutes.POST("/pricing-rules", management, controller.CreatePricingRule(stores))
+	incomingRoutes.PATCH("/pricing-rules/:pricing_rule_id", management, controller.UpdatePricingRule(stores))
+}
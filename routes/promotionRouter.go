@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PromotionRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/promotions", management, controller.GetPromotions(stores))
+	incomingRoutes.POST("/promotions", management, controller.CreatePromotion(stores))
+	incomingRoutes.PATCH("/promotions/:promotion_id", management, controller.UpdatePromotion(stores))
+}
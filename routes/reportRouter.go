@@ -0,0 +1,12 @@
+package routes
+
+import (
+	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ReportRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/reports/margins", management, controller.GetMarginReport(stores))
+}
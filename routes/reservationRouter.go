@@ -0,0 +1,16 @@
+package routes
+
+import (
+	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ReservationRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/reservations", floorStaff, controller.GetReservations(stores))
+	incomingRoutes.GET("/reservations/availability", floorStaff, controller.GetAvailability(stores))
+	incomingRoutes.GET("/reservations/:reservation_id", floorStaff, controller.GetReservation(stores))
+	incomingRoutes.POST("/reservations", floorStaff, controller.CreateReservation(stores))
+	incomingRoutes.PATCH("/reservations/:reservation_id", floorStaff, controller.UpdateReservation(stores))
+}
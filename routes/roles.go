@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"golang-restaurant-management/middleware"
+	"golang-restaurant-management/models"
+)
+
+var (
+	allStaff     = middleware.Authorize(models.ROLE_OWNER, models.ROLE_MANAGER, models.ROLE_WAITER, models.ROLE_KITCHEN, models.ROLE_CASHIER)
+	management   = middleware.Authorize(models.ROLE_OWNER, models.ROLE_MANAGER)
+	ownerOnly    = middleware.Authorize(models.ROLE_OWNER)
+	floorStaff   = middleware.Authorize(models.ROLE_OWNER, models.ROLE_MANAGER, models.ROLE_WAITER)
+	billing      = middleware.Authorize(models.ROLE_OWNER, models.ROLE_MANAGER, models.ROLE_WAITER, models.ROLE_CASHIER)
+	cashDesk     = middleware.Authorize(models.ROLE_OWNER, models.ROLE_MANAGER, models.ROLE_CASHIER)
+	kitchenStaff = middleware.Authorize(models.ROLE_OWNER, models.ROLE_MANAGER, models.ROLE_KITCHEN)
+	serviceStaff = middleware.Authorize(models.ROLE_OWNER, models.ROLE_MANAGER, models.ROLE_WAITER, models.ROLE_KITCHEN)
+)
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SupplierRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/suppliers", management, controller.GetSuppliers(stores))
+	incomingRoutes.GET("/suppliers/:supplier_id", management, controller.GetSupplier(stores))
+	incomingRoutes.POST("/suppliers", management, controller.CreateSupplier(stores))
+	incomingRoutes.PATCH("/suppliers/:supplier_id", management, controller.UpdateSupplier(stores))
+
+	incomingRoutes.GET("/purchase-orders", management, controller.GetPurchaseOrders(stores))
+	incomingRoutes.GET("/purchase-orders/:purchase_order_id", management, controller.GetPurchaseOrder(stores))
+	incomingRoutes.POST("/purchase-orders", management, controller.CreatePurchaseOrder(stores))
+	incomingRoutes.PATCH("/purchase-orders/:purchase_order_id", management, controller.UpdatePurchaseOrder(stores))
+	incomingRoutes.POST("/purchase-orders/:purchase_order_id/send", management, controller.SendPurchaseOrder(stores))
+	incomingRoutes.POST("/purchase-orders/:purchase_order_id/cancel", management, controller.CancelPurchaseOrder(stores))
+	// deliveries are often signed for by whoever is in the kitchen
+	incomingRoutes.POST("/purchase-orders/:purchase_order_id/receive", kitchenStaff, controller.ReceivePurchaseOrder(stores))
+}
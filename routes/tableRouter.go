@@ -2,13 +2,15 @@ package routes
 
 import (
 	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/store"
 
 	"github.com/gin-gonic/gin"
 )
 
-func TableRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/tables", controller.GetTables())
-	incomingRoutes.GET("/tables/:table_id", controller.GetTable())
-	incomingRoutes.POST("/tables", controller.CreateTable())
-	incomingRoutes.PATCH("/tables/:table_id", controller.UpdateTable())
+func TableRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/tables", allStaff, controller.GetTables(stores))
+	incomingRoutes.GET("/tables/:table_id", allStaff, controller.GetTable(stores))
+	incomingRoutes.POST("/tables", management, controller.CreateTable(stores))
+	incomingRoutes.PATCH("/tables/:table_id", management, controller.UpdateTable(stores))
+	incomingRoutes.POST("/tables/:table_id/clean", floorStaff, controller.MarkTableClean(stores))
 }
@@ -2,13 +2,19 @@ package routes
 
 import (
 	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/middleware"
+	"golang-restaurant-management/store"
 
 	"github.com/gin-gonic/gin"
 )
 
-func UserRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/users", controller.GetUsers())
-	incomingRoutes.GET("/users/:user_id", controller.GetUser())
-	incomingRoutes.POST("/users/signup", controller.SignUp())
-	incomingRoutes.POST("/users/login", controller.Login())
+func UserRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/users", middleware.Authentication(stores.Users), management, controller.GetUsers(stores))
+	incomingRoutes.GET("/users/:user_id", middleware.Authentication(stores.Users), management, controller.GetUser(stores))
+	incomingRoutes.PATCH("/users/:user_id/role", middleware.Authentication(stores.Users), ownerOnly, controller.UpdateUserRole(stores))
+	incomingRoutes.POST("/users/:user_id/revoke", middleware.Authentication(stores.Users), management, controller.RevokeUserTokens(stores))
+	incomingRoutes.POST("/users/signup", controller.SignUp(stores))
+	incomingRoutes.POST("/users/login", controller.Login(stores))
+	incomingRoutes.POST("/users/refresh", controller.RefreshToken(stores))
+	incomingRoutes.POST("/users/logout", middleware.Authentication(stores.Users), controller.Logout(stores))
 }
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	controller "golang-restaurant-management/controllers"
+	"golang-restaurant-management/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func WaitlistRoutes(incomingRoutes *gin.Engine, stores *store.Store) {
+	incomingRoutes.GET("/waitlist", floorStaff, controller.GetWaitlist(stores))
+	incomingRoutes.POST("/waitlist", floorStaff, controller.CreateWaitlistEntry(stores))
+	incomingRoutes.PATCH("/waitlist/:waitlist_id", floorStaff, controller.UpdateWaitlistEntry(stores))
+	incomingRoutes.POST("/waitlist/:waitlist_id/seat", floorStaff, controller.SeatParty(stores))
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// FoodFilter narrows the food list: Allergen_free hides foods containing any
+// of the allergens, Dietary keeps foods carrying every tag.
+type FoodFilter struct {
+	Allergen_free []string
+	Dietary       []string
+	Available     *bool
+}
+
+// FoodPage is one page of foods as stored, with available worked out for
+// foods that track portions or predate the flag.
+type FoodPage struct {
+	Total_count int      `json:"total_count"`
+	Food_items  []bson.M `json:"food_items"`
+}
+
+type FoodStore interface {
+	Get(ctx context.Context, foodId string) (models.Food, error)
+	Page(ctx context.Context, filter FoodFilter, startIndex int, recordPerPage int) (FoodPage, error)
+	// ForMenus returns the foods on the given menus, or every food when no
+	// menu is given.
+	ForMenus(ctx context.Context, menuIds ...string) ([]models.Food, error)
+	Insert(ctx context.Context, food models.Food) (InsertResult, error)
+	Update(ctx context.Context, foodId string, patch Patch) (UpdateResult, error)
+	Modify(ctx context.Context, foodId string, patch Patch) (models.Food, error)
+	// ClaimPortions takes quantity off the portion counter of a food still on
+	// sale, or returns ErrNotFound when there are not that many left.
+	ClaimPortions(ctx context.Context, foodId string, quantity int) (models.Food, error)
+}
+
+type mongoFoods struct {
+	mongoCollection[models.Food]
+}
+
+func (m mongoFoods) Get(ctx context.Context, foodId string) (models.Food, error) {
+	return m.get(ctx, foodId)
+}
+
+func (m mongoFoods) Page(ctx context.Context, filter FoodFilter, startIndex int, recordPerPage int) (FoodPage, error) {
+	match := bson.M{}
+	if len(filter.Allergen_free) > 0 {
+		match["allergens"] = bson.M{"$nin": filter.Allergen_free}
+	}
+	if len(filter.Dietary) > 0 {
+		match["dietary_tags"] = bson.M{"$all": filter.Dietary}
+	}
+	if filter.Available != nil {
+		match["available"] = *filter.Available
+	}
+
+	// foods saved before availability was tracked have no flag and are on sale
+	availabilityStage := bson.D{{"$addFields", bson.D{{"available", bson.D{{"$and", bson.A{
+		bson.D{{"$ne", bson.A{"$available", false}}},
+		bson.D{{"$or", bson.A{
+			bson.D{{"$eq", bson.A{bson.D{{"$ifNull", bson.A{"$remaining_portions", nil}}}, nil}}},
+			bson.D{{"$gt", bson.A{"$remaining_portions", 0}}},
+		}}},
+	}}}}}}}
+	matchStage := bson.D{{"$match", match}}
+	groupStage := bson.D{{"$group", bson.D{{"_id", bson.D{{"_id", "null"}}}, {"total_count", bson.D{{"$sum", 1}}}, {"data", bson.D{{"$push", "$$ROOT"}}}}}}
+	projectStage := bson.D{
+		{
+			"$project", bson.D{
+				{"_id", 0},
+				{"total_count", 1},
+				{"food_items", bson.D{{"$slice", []interface{}{"$data", startIndex, recordPerPage}}}},
+			}}}
+
+	page := FoodPage{Food_items: []bson.M{}}
+	cursor, err := m.collection.Aggregate(ctx, mongo.Pipeline{
+		availabilityStage, matchStage, groupStage, projectStage})
+	if err != nil {
+		return page, err
+	}
+	var pages []FoodPage
+	if err = cursor.All(ctx, &pages); err != nil {
+		return page, err
+	}
+	// nothing matched the filters, so the $group stage had no rows to emit
+	if len(pages) > 0 {
+		page = pages[0]
+	}
+	return page, nil
+}
+
+func (m mongoFoods) ForMenus(ctx context.Context, menuIds ...string) ([]models.Food, error) {
+	filter := bson.M{}
+	if len(menuIds) > 0 {
+		filter["menu_id"] = bson.M{"$in": menuIds}
+	}
+	return m.find(ctx, filter)
+}
+
+func (m mongoFoods) Insert(ctx context.Context, food models.Food) (InsertResult, error) {
+	return m.insert(ctx, food)
+}
+
+func (m mongoFoods) Update(ctx context.Context, foodId string, patch Patch) (UpdateResult, error) {
+	return m.update(ctx, foodId, patch)
+}
+
+func (m mongoFoods) Modify(ctx context.Context, foodId string, patch Patch) (models.Food, error) {
+	return m.modify(ctx, foodId, patch)
+}
+
+func (m mongoFoods) ClaimPortions(ctx context.Context, foodId string, quantity int) (models.Food, error) {
+	return m.modifyWhere(ctx,
+		bson.M{"food_id": foodId, "available": bson.M{"$ne": false}, "remaining_portions": bson.M{"$gte": quantity}},
+		bson.M{"$inc": bson.M{"remaining_portions": -quantity}},
+	)
+}
+
+type memoryFoods struct {
+	memoryCollection[models.Food]
+}
+
+func (m memoryFoods) Get(ctx context.Context, foodId string) (models.Food, error) {
+	return m.get(foodId)
+}
+
+func (m memoryFoods) Page(ctx context.Context, filter FoodFilter, startIndex int, recordPerPage int) (FoodPage, error) {
+	page := FoodPage{Food_items: []bson.M{}}
+	docs, err := m.raw(func(food models.Food) bool {
+		for _, allergen := range filter.Allergen_free {
+			if containsString(food.Allergens, allergen) {
+				return false
+			}
+		}
+		for _, tag := range filter.Dietary {
+			if !containsString(food.Dietary_tags, tag) {
+				return false
+			}
+		}
+		return filter.Available == nil || food.IsAvailable() == *filter.Available
+	})
+	if err != nil {
+		return page, err
+	}
+
+	for _, doc := range docs {
+		var food models.Food
+		if err := decode(doc, &food); err != nil {
+			return page, err
+		}
+		doc["available"] = food.IsAvailable()
+	}
+
+	// the same window $slice takes, counting back from the end when negative
+	page.Total_count = len(docs)
+	if startIndex < 0 {
+		startIndex = max(len(docs)+startIndex, 0)
+	}
+	if startIndex < len(docs) {
+		page.Food_items = docs[startIndex:min(startIndex+recordPerPage, len(docs))]
+	}
+	return page, nil
+}
+
+func (m memoryFoods) ForMenus(ctx context.Context, menuIds ...string) ([]models.Food, error) {
+	return m.find(func(food models.Food) bool {
+		return len(menuIds) == 0 || (food.Menu_id != nil && containsString(menuIds, *food.Menu_id))
+	})
+}
+
+func (m memoryFoods) Insert(ctx context.Context, food models.Food) (InsertResult, error) {
+	return m.insert(food)
+}
+
+func (m memoryFoods) Update(ctx context.Context, foodId string, patch Patch) (UpdateResult, error) {
+	return m.update(foodId, patch)
+}
+
+func (m memoryFoods) Modify(ctx context.Context, foodId string, patch Patch) (models.Food, error) {
+	return m.modify(foodId, patch)
+}
+
+func (m memoryFoods) ClaimPortions(ctx context.Context, foodId string, quantity int) (models.Food, error) {
+	return m.modifyIf(foodId, func(food models.Food) bool {
+		return (food.Available == nil || *food.Available) &&
+			food.Remaining_portions != nil && *food.Remaining_portions >= quantity
+	}, Patch{Inc: bson.M{"remaining_portions": -quantity}})
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
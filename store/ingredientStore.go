@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IngredientStore interface {
+	Get(ctx context.Context, ingredientId string) (models.Ingredient, error)
+	// List returns every ingredient by name, or only those flagged low on
+	// stock when lowStock is set.
+	List(ctx context.Context, lowStock bool) ([]models.Ingredient, error)
+	Find(ctx context.Context, ingredientIds []string) ([]models.Ingredient, error)
+	// Costed returns the ingredients that have been bought at a known price.
+	Costed(ctx context.Context) ([]models.Ingredient, error)
+	Insert(ctx context.Context, ingredient models.Ingredient) (InsertResult, error)
+	Update(ctx context.Context, ingredientId string, patch Patch) (UpdateResult, error)
+	// ApplyStock adds each movement's change to its ingredient's stock.
+	ApplyStock(ctx context.Context, movements []models.StockMovement, at time.Time) error
+	// SetUnitCosts records what each ingredient was last bought at.
+	SetUnitCosts(ctx context.Context, costs map[string]models.Money) error
+}
+
+type mongoIngredients struct {
+	mongoCollection[models.Ingredient]
+}
+
+func (m mongoIngredients) Get(ctx context.Context, ingredientId string) (models.Ingredient, error) {
+	return m.get(ctx, ingredientId)
+}
+
+func (m mongoIngredients) List(ctx context.Context, lowStock bool) ([]models.Ingredient, error) {
+	filter := bson.M{}
+	if lowStock {
+		filter["low_stock"] = true
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	return m.find(ctx, filter, opts)
+}
+
+func (m mongoIngredients) Find(ctx context.Context, ingredientIds []string) ([]models.Ingredient, error) {
+	return m.find(ctx, bson.M{"ingredient_id": bson.M{"$in": ingredientIds}})
+}
+
+func (m mongoIngredients) Costed(ctx context.Context) ([]models.Ingredient, error) {
+	return m.find(ctx, bson.M{"unit_cost": bson.M{"$ne": nil}})
+}
+
+func (m mongoIngredients) Insert(ctx context.Context, ingredient models.Ingredient) (InsertResult, error) {
+	return m.insert(ctx, ingredient)
+}
+
+func (m mongoIngredients) Update(ctx context.Context, ingredientId string, patch Patch) (UpdateResult, error) {
+	return m.update(ctx, ingredientId, patch)
+}
+
+func (m mongoIngredients) ApplyStock(ctx context.Context, movements []models.StockMovement, at time.Time) error {
+	if len(movements) == 0 {
+		return nil
+	}
+	updates := make([]mongo.WriteModel, 0, len(movements))
+	for _, movement := range movements {
+		updates = append(updates, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"ingredient_id": movement.Ingredient_id}).
+			SetUpdate(bson.M{"$inc": bson.M{"stock": movement.Change}, "$set": bson.M{"updated_at": at}}))
+	}
+	_, err := m.collection.BulkWrite(ctx, updates, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (m mongoIngredients) SetUnitCosts(ctx context.Context, costs map[string]models.Money) error {
+	if len(costs) == 0 {
+		return nil
+	}
+	updates := make([]mongo.WriteModel, 0, len(costs))
+	for ingredientId, cost := range costs {
+		updates = append(updates, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"ingredient_id": ingredientId}).
+			SetUpdate(bson.M{"$set": bson.M{"unit_cost": cost}}))
+	}
+	_, err := m.collection.BulkWrite(ctx, updates, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+type memoryIngredients struct {
+	memoryCollection[models.Ingredient]
+}
+
+func (m memoryIngredients) Get(ctx context.Context, ingredientId string) (models.Ingredient, error) {
+	return m.get(ingredientId)
+}
+
+func (m memoryIngredients) List(ctx context.Context, lowStock bool) ([]models.Ingredient, error) {
+	ingredients, err := m.find(func(ingredient models.Ingredient) bool {
+		return !lowStock || ingredient.Low_stock
+	})
+	sort.SliceStable(ingredients, func(a, b int) bool {
+		return valueOfString(ingredients[a].Name) < valueOfString(ingredients[b].Name)
+	})
+	return ingredients, err
+}
+
+func (m memoryIngredients) Find(ctx context.Context, ingredientIds []string) ([]models.Ingredient, error) {
+	return m.find(func(ingredient models.Ingredient) bool {
+		return containsString(ingredientIds, ingredient.Ingredient_id)
+	})
+}
+
+func (m memoryIngredients) Costed(ctx context.Context) ([]models.Ingredient, error) {
+	return m.find(func(ingredient models.Ingredient) bool {
+		return ingredient.Unit_cost != nil
+	})
+}
+
+func (m memoryIngredients) Insert(ctx context.Context, ingredient models.Ingredient) (InsertResult, error) {
+	return m.insert(ingredient)
+}
+
+func (m memoryIngredients) Update(ctx context.Context, ingredientId string, patch Patch) (UpdateResult, error) {
+	return m.update(ingredientId, patch)
+}
+
+func (m memoryIngredients) ApplyStock(ctx context.Context, movements []models.StockMovement, at time.Time) error {
+	for _, movement := range movements {
+		patch := Patch{Inc: bson.M{"stock": movement.Change}, Set: bson.M{"updated_at": at}}
+		if _, err := m.update(movement.Ingredient_id, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m memoryIngredients) SetUnitCosts(ctx context.Context, costs map[string]models.Money) error {
+	for ingredientId, cost := range costs {
+		if _, err := m.update(ingredientId, Patch{Set: bson.M{"unit_cost": cost}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func valueOfString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type InvoiceStore interface {
+	Get(ctx context.Context, invoiceId string) (models.Invoice, error)
+	All(ctx context.Context) ([]bson.M, error)
+	// Unpaid returns the invoices of the given orders that are not paid off.
+	Unpaid(ctx context.Context, orderIds []string) ([]models.Invoice, error)
+	Insert(ctx context.Context, invoice models.Invoice) (InsertResult, error)
+	Update(ctx context.Context, invoiceId string, patch Patch) (UpdateResult, error)
+	// RecordPayment applies set only if the invoice is still at version, so
+	// no payment or discount landed since it was read, and none of paidItems
+	// is paid yet; it then adds paidItems to paid_items and bumps the version.
+	// Version 0 matches invoices from before versions were kept.
+	RecordPayment(ctx context.Context, invoiceId string, version int64, paidItems []string, set bson.M) (UpdateResult, error)
+}
+
+type mongoInvoices struct {
+	mongoCollection[models.Invoice]
+}
+
+func (m mongoInvoices) Get(ctx context.Context, invoiceId string) (models.Invoice, error) {
+	return m.get(ctx, invoiceId)
+}
+
+func (m mongoInvoices) All(ctx context.Context) ([]bson.M, error) {
+	return m.raw(ctx, bson.M{})
+}
+
+func (m mongoInvoices) Unpaid(ctx context.Context, orderIds []string) ([]models.Invoice, error) {
+	return m.find(ctx, bson.M{
+		"order_id":       bson.M{"$in": orderIds},
+		"payment_status": bson.M{"$ne": models.PAYMENT_PAID},
+	})
+}
+
+func (m mongoInvoices) Insert(ctx context.Context, invoice models.Invoice) (InsertResult, error) {
+	return m.insert(ctx, invoice)
+}
+
+func (m mongoInvoices) Update(ctx context.Context, invoiceId string, patch Patch) (UpdateResult, error) {
+	return m.update(ctx, invoiceId, patch)
+}
+
+func (m mongoInvoices) RecordPayment(ctx context.Context, invoiceId string, version int64, paidItems []string, set bson.M) (UpdateResult, error) {
+	filter := bson.M{"invoice_id": invoiceId, "version": version}
+	if version == 0 {
+		filter["version"] = nil
+	}
+	update := bson.M{"$set": set, "$inc": bson.M{"version": int64(1)}}
+	if len(paidItems) > 0 {
+		filter["paid_items"] = bson.M{"$nin": paidItems}
+		update["$push"] = bson.M{"paid_items": bson.M{"$each": paidItems}}
+	}
+
+	result, err := m.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	return UpdateResult{result.MatchedCount, result.ModifiedCount, result.UpsertedCount, result.UpsertedID}, nil
+}
+
+type memoryInvoices struct {
+	memoryCollection[models.Invoice]
+}
+
+func (m memoryInvoices) Get(ctx context.Context, invoiceId string) (models.Invoice, error) {
+	return m.get(invoiceId)
+}
+
+func (m memoryInvoices) All(ctx context.Context) ([]bson.M, error) {
+	return m.raw(nil)
+}
+
+func (m memoryInvoices) Unpaid(ctx context.Context, orderIds []string) ([]models.Invoice, error) {
+	return m.find(func(invoice models.Invoice) bool {
+		return containsString(orderIds, invoice.Order_id) &&
+			(invoice.Payment_status == nil || *invoice.Payment_status != models.PAYMENT_PAID)
+	})
+}
+
+func (m memoryInvoices) Insert(ctx context.Context, invoice models.Invoice) (InsertResult, error) {
+	return m.insert(invoice)
+}
+
+func (m memoryInvoices) Update(ctx context.Context, invoiceId string, patch Patch) (UpdateResult, error) {
+	return m.update(invoiceId, patch)
+}
+
+func (m memoryInvoices) RecordPayment(ctx context.Context, invoiceId string, version int64, paidItems []string, set bson.M) (UpdateResult, error) {
+	patch := Patch{Set: set, Inc: bson.M{"version": int64(1)}}
+	if len(paidItems) > 0 {
+		patch.Push = bson.M{"paid_items": paidItems}
+	}
+
+	_, err := m.modifyIf(invoiceId, func(invoice models.Invoice) bool {
+		if invoice.Version != version {
+			return false
+		}
+		for _, orderItemId := range paidItems {
+			if containsString(invoice.Paid_items, orderItemId) {
+				return false
+			}
+		}
+		return true
+	}, patch)
+	if err == ErrNotFound {
+		return UpdateResult{}, nil
+	}
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	return UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRecordPaymentRefusesAStaleInvoice(t *testing.T) {
+	ctx := context.Background()
+	invoices := NewMemory().Invoices
+
+	total := models.NewMoney(2500)
+	invoice := models.Invoice{ID: primitive.NewObjectID(), Total: &total, Version: 1}
+	invoice.Invoice_id = invoice.ID.Hex()
+	if _, err := invoices.Insert(ctx, invoice); err != nil {
+		t.Fatal(err)
+	}
+
+	// the till reads the invoice, then a discount lands before it writes
+	read, err := invoices.Get(ctx, invoice.Invoice_id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	discounted := models.NewMoney(2000)
+	if _, err := invoices.Update(ctx, invoice.Invoice_id, Patch{
+		If:  bson.M{"version": read.Version},
+		Set: bson.M{"total": discounted},
+		Inc: bson.M{"version": int64(1)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := invoices.RecordPayment(ctx, invoice.Invoice_id, read.Version, nil, bson.M{
+		"total":          read.Total,
+		"amount_paid":    read.Total,
+		"payment_status": models.PAYMENT_PAID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MatchedCount != 0 {
+		t.Fatal("expected the payment read before the discount to be refused")
+	}
+
+	stored, err := invoices.Get(ctx, invoice.Invoice_id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Total.Amount != 2000 || stored.Payment_status != nil {
+		t.Fatalf("expected the discounted total to stand unpaid, got %s", stored.Total)
+	}
+
+	// reloaded, the payment goes through and moves the version on
+	result, err = invoices.RecordPayment(ctx, invoice.Invoice_id, stored.Version, nil, bson.M{"amount_paid": discounted})
+	if err != nil || result.MatchedCount != 1 {
+		t.Fatalf("expected the payment on the current version to be recorded, got %+v, %v", result, err)
+	}
+	if stored, _ = invoices.Get(ctx, invoice.Invoice_id); stored.Version != 3 {
+		t.Fatalf("expected version 3 after the discount and the payment, got %d", stored.Version)
+	}
+}
+
+func TestRecordPaymentOnALegacyInvoice(t *testing.T) {
+	ctx := context.Background()
+	invoices := NewMemory().Invoices
+
+	invoice := models.Invoice{ID: primitive.NewObjectID()}
+	invoice.Invoice_id = invoice.ID.Hex()
+	if _, err := invoices.Insert(ctx, invoice); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		version int64
+		items   []string
+		matched int64
+	}{
+		{"no version yet", 0, []string{"a"}, 1},
+		{"same read again", 0, []string{"b"}, 0},
+		{"item already paid", 1, []string{"a"}, 0},
+		{"next version", 1, []string{"b"}, 1},
+	}
+	for _, test := range tests {
+		result, err := invoices.RecordPayment(ctx, invoice.Invoice_id, test.version, test.items, bson.M{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.MatchedCount != test.matched {
+			t.Errorf("%s: expected %d matched, got %d", test.name, test.matched, result.MatchedCount)
+		}
+	}
+}
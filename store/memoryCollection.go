@@ -0,0 +1,391 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryCollection keeps documents of type T in insertion order, encoded the
+// way MongoDB would store them, addressed by a string id field.
+type memoryCollection[T any] struct {
+	mu   *sync.RWMutex
+	key  string
+	docs *[]bson.M
+}
+
+func newMemoryCollection[T any](key string) memoryCollection[T] {
+	return memoryCollection[T]{&sync.RWMutex{}, key, &[]bson.M{}}
+}
+
+func (m memoryCollection[T]) get(id string) (T, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var doc T
+	index := m.indexOf(id)
+	if index < 0 {
+		return doc, ErrNotFound
+	}
+	err := decode((*m.docs)[index], &doc)
+	return doc, err
+}
+
+// find returns the documents match accepts; a nil match accepts all.
+func (m memoryCollection[T]) find(match func(T) bool) ([]T, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var docs []T
+	for _, stored := range *m.docs {
+		var doc T
+		if err := decode(stored, &doc); err != nil {
+			return nil, err
+		}
+		if match == nil || match(doc) {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+// raw is find returning the stored documents themselves, as the list
+// endpoints do.
+func (m memoryCollection[T]) raw(match func(T) bool) ([]bson.M, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var docs []bson.M
+	for _, stored := range *m.docs {
+		var doc T
+		if err := decode(stored, &doc); err != nil {
+			return nil, err
+		}
+		if match == nil || match(doc) {
+			clone, err := encode(stored)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, clone)
+		}
+	}
+	return docs, nil
+}
+
+func (m memoryCollection[T]) insert(doc T) (InsertResult, error) {
+	stored, err := encode(doc)
+	if err != nil {
+		return InsertResult{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	*m.docs = append(*m.docs, stored)
+	return InsertResult{stored["_id"]}, nil
+}
+
+func (m memoryCollection[T]) insertMany(docs []T) (InsertManyResult, error) {
+	if len(docs) == 0 {
+		return InsertManyResult{}, errors.New("store: nothing to insert")
+	}
+	var encoded []bson.M
+	var ids []interface{}
+	for _, doc := range docs {
+		stored, err := encode(doc)
+		if err != nil {
+			return InsertManyResult{}, err
+		}
+		encoded = append(encoded, stored)
+		ids = append(ids, stored["_id"])
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	*m.docs = append(*m.docs, encoded...)
+	return InsertManyResult{ids}, nil
+}
+
+func (m memoryCollection[T]) update(id string, patch Patch) (UpdateResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index := m.indexOf(id)
+	if index < 0 || !matches((*m.docs)[index], patch.If) {
+		if !patch.Upsert {
+			return UpdateResult{}, nil
+		}
+		stored, err := encodeValues(patch.If)
+		if err != nil {
+			return UpdateResult{}, err
+		}
+		stored["_id"] = primitive.NewObjectID()
+		stored[m.key] = id
+		if _, err := apply(stored, patch); err != nil {
+			return UpdateResult{}, err
+		}
+		*m.docs = append(*m.docs, stored)
+		return UpdateResult{UpsertedCount: 1, UpsertedID: stored["_id"]}, nil
+	}
+
+	changed, err := apply((*m.docs)[index], patch)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	result := UpdateResult{MatchedCount: 1}
+	if changed {
+		result.ModifiedCount = 1
+	}
+	return result, nil
+}
+
+func (m memoryCollection[T]) replace(id string, doc T) (UpdateResult, error) {
+	stored, err := encode(doc)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index := m.indexOf(id)
+	if index < 0 {
+		return UpdateResult{}, nil
+	}
+	result := UpdateResult{MatchedCount: 1}
+	if !reflect.DeepEqual((*m.docs)[index], stored) {
+		result.ModifiedCount = 1
+	}
+	(*m.docs)[index] = stored
+	return result, nil
+}
+
+func (m memoryCollection[T]) delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if index := m.indexOf(id); index >= 0 {
+		*m.docs = append((*m.docs)[:index], (*m.docs)[index+1:]...)
+	}
+	return nil
+}
+
+// modify applies the patch and returns the document as it is afterwards.
+func (m memoryCollection[T]) modify(id string, patch Patch) (T, error) {
+	return m.modifyIf(id, nil, patch)
+}
+
+// modifyIf is modify for conditions If cannot express; the document is only
+// patched when ok accepts it.
+func (m memoryCollection[T]) modifyIf(id string, ok func(T) bool, patch Patch) (T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var doc T
+	index := m.indexOf(id)
+	if index < 0 || !matches((*m.docs)[index], patch.If) {
+		return doc, ErrNotFound
+	}
+	if ok != nil {
+		if err := decode((*m.docs)[index], &doc); err != nil {
+			return doc, err
+		}
+		if !ok(doc) {
+			var none T
+			return none, ErrNotFound
+		}
+	}
+	if _, err := apply((*m.docs)[index], patch); err != nil {
+		return doc, err
+	}
+	err := decode((*m.docs)[index], &doc)
+	return doc, err
+}
+
+// updateWhere patches every document match accepts and returns them as they
+// were before.
+func (m memoryCollection[T]) updateWhere(match func(T) bool, patch Patch) ([]T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var before []T
+	for _, stored := range *m.docs {
+		var doc T
+		if err := decode(stored, &doc); err != nil {
+			return nil, err
+		}
+		if !match(doc) || !matches(stored, patch.If) {
+			continue
+		}
+		if _, err := apply(stored, patch); err != nil {
+			return nil, err
+		}
+		before = append(before, doc)
+	}
+	return before, nil
+}
+
+func (m memoryCollection[T]) indexOf(id string) int {
+	for i, stored := range *m.docs {
+		if stored[m.key] == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func encode(value interface{}) (bson.M, error) {
+	data, err := bson.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	err = bson.Unmarshal(data, &doc)
+	return doc, err
+}
+
+func decode(doc bson.M, value interface{}) error {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, value)
+}
+
+// encodeValues turns the values of a patch into their stored form, so they
+// compare and decode like the rest of the document.
+func encodeValues(values bson.M) (bson.M, error) {
+	if len(values) == 0 {
+		return bson.M{}, nil
+	}
+	return encode(values)
+}
+
+func matches(doc bson.M, conditions bson.M) bool {
+	if len(conditions) == 0 {
+		return true
+	}
+	want, err := encodeValues(conditions)
+	if err != nil {
+		return false
+	}
+	for field, value := range want {
+		current, _ := lookup(doc, field)
+		if !reflect.DeepEqual(current, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply writes the patch into doc and reports whether anything changed.
+func apply(doc bson.M, patch Patch) (bool, error) {
+	before, err := encode(doc)
+	if err != nil {
+		return false, err
+	}
+
+	set, err := encodeValues(patch.Set)
+	if err != nil {
+		return false, err
+	}
+	for field, value := range set {
+		parent, name := parentOf(doc, field)
+		parent[name] = value
+	}
+
+	for _, field := range patch.Unset {
+		parent, name := parentOf(doc, field)
+		delete(parent, name)
+	}
+
+	inc, err := encodeValues(patch.Inc)
+	if err != nil {
+		return false, err
+	}
+	for field, value := range inc {
+		parent, name := parentOf(doc, field)
+		sum, err := add(parent[name], value)
+		if err != nil {
+			return false, fmt.Errorf("cannot increment %s: %w", field, err)
+		}
+		parent[name] = sum
+	}
+
+	push, err := encodeValues(patch.Push)
+	if err != nil {
+		return false, err
+	}
+	for field, values := range push {
+		parent, name := parentOf(doc, field)
+		list, _ := parent[name].(primitive.A)
+		more, ok := values.(primitive.A)
+		if !ok {
+			return false, fmt.Errorf("cannot push to %s: not a list", field)
+		}
+		parent[name] = append(append(primitive.A{}, list...), more...)
+	}
+
+	return !reflect.DeepEqual(before, doc), nil
+}
+
+func lookup(doc bson.M, field string) (interface{}, bool) {
+	parts := strings.Split(field, ".")
+	current := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(bson.M)
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+	value, ok := current[parts[len(parts)-1]]
+	return value, ok
+}
+
+// parentOf finds the document holding a dotted field, creating the embedded
+// documents on the way as $set does.
+func parentOf(doc bson.M, field string) (bson.M, string) {
+	parts := strings.Split(field, ".")
+	current := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(bson.M)
+		if !ok {
+			next = bson.M{}
+			current[part] = next
+		}
+		current = next
+	}
+	return current, parts[len(parts)-1]
+}
+
+// add sums two stored numbers, keeping the type of the stored one.
+func add(current interface{}, delta interface{}) (interface{}, error) {
+	var by float64
+	switch d := delta.(type) {
+	case int32:
+		by = float64(d)
+	case int64:
+		by = float64(d)
+	case float64:
+		by = d
+	default:
+		return nil, fmt.Errorf("%v is not a number", delta)
+	}
+
+	switch c := current.(type) {
+	case nil:
+		return delta, nil
+	case int32:
+		return c + int32(by), nil
+	case int64:
+		return c + int64(by), nil
+	case float64:
+		return c + by, nil
+	}
+	return nil, fmt.Errorf("%v is not a number", current)
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type MenuStore interface {
+	Get(ctx context.Context, menuId string) (models.Menu, error)
+	All(ctx context.Context) ([]bson.M, error)
+	// Find returns the given menus, or every menu when none is given.
+	Find(ctx context.Context, menuIds ...string) ([]models.Menu, error)
+	Insert(ctx context.Context, menu models.Menu) (InsertResult, error)
+	Update(ctx context.Context, menuId string, patch Patch) (UpdateResult, error)
+}
+
+type mongoMenus struct {
+	mongoCollection[models.Menu]
+}
+
+func (m mongoMenus) Get(ctx context.Context, menuId string) (models.Menu, error) {
+	return m.get(ctx, menuId)
+}
+
+func (m mongoMenus) All(ctx context.Context) ([]bson.M, error) {
+	return m.raw(ctx, bson.M{})
+}
+
+func (m mongoMenus) Find(ctx context.Context, menuIds ...string) ([]models.Menu, error) {
+	filter := bson.M{}
+	if len(menuIds) > 0 {
+		filter["menu_id"] = bson.M{"$in": menuIds}
+	}
+	return m.find(ctx, filter)
+}
+
+func (m mongoMenus) Insert(ctx context.Context, menu models.Menu) (InsertResult, error) {
+	return m.insert(ctx, menu)
+}
+
+func (m mongoMenus) Update(ctx context.Context, menuId string, patch Patch) (UpdateResult, error) {
+	return m.update(ctx, menuId, patch)
+}
+
+type memoryMenus struct {
+	memoryCollection[models.Menu]
+}
+
+func (m memoryMenus) Get(ctx context.Context, menuId string) (models.Menu, error) {
+	return m.get(menuId)
+}
+
+func (m memoryMenus) All(ctx context.Context) ([]bson.M, error) {
+	return m.raw(nil)
+}
+
+func (m memoryMenus) Find(ctx context.Context, menuIds ...string) ([]models.Menu, error) {
+	return m.find(func(menu models.Menu) bool {
+		return len(menuIds) == 0 || containsString(menuIds, menu.Menu_id)
+	})
+}
+
+func (m memoryMenus) Insert(ctx context.Context, menu models.Menu) (InsertResult, error) {
+	return m.insert(menu)
+}
+
+func (m memoryMenus) Update(ctx context.Context, menuId string, patch Patch) (UpdateResult, error) {
+	return m.update(menuId, patch)
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoCollection is the part every Mongo store shares: documents of type T
+// addressed by a string id field such as food_id.
+type mongoCollection[T any] struct {
+	collection *mongo.Collection
+	key        string
+}
+
+func newMongoCollection[T any](client *mongo.Client, name string, key string) mongoCollection[T] {
+	return mongoCollection[T]{openCollection(client, name), key}
+}
+
+func (m mongoCollection[T]) get(ctx context.Context, id string) (T, error) {
+	var doc T
+	err := m.collection.FindOne(ctx, bson.M{m.key: id}).Decode(&doc)
+	return doc, notFound(err)
+}
+
+func (m mongoCollection[T]) find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]T, error) {
+	var docs []T
+	cursor, err := m.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &docs)
+	return docs, err
+}
+
+func (m mongoCollection[T]) raw(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]bson.M, error) {
+	var docs []bson.M
+	cursor, err := m.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &docs)
+	return docs, err
+}
+
+func (m mongoCollection[T]) insert(ctx context.Context, doc T) (InsertResult, error) {
+	result, err := m.collection.InsertOne(ctx, doc)
+	if err != nil {
+		return InsertResult{}, err
+	}
+	return InsertResult{result.InsertedID}, nil
+}
+
+func (m mongoCollection[T]) insertMany(ctx context.Context, docs []T) (InsertManyResult, error) {
+	many := make([]interface{}, len(docs))
+	for i := range docs {
+		many[i] = docs[i]
+	}
+	result, err := m.collection.InsertMany(ctx, many)
+	if err != nil {
+		return InsertManyResult{}, err
+	}
+	return InsertManyResult{result.InsertedIDs}, nil
+}
+
+func (m mongoCollection[T]) update(ctx context.Context, id string, patch Patch) (UpdateResult, error) {
+	opts := options.Update().SetUpsert(patch.Upsert)
+	result, err := m.collection.UpdateOne(ctx, m.filter(id, patch), patch.update(), opts)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	return UpdateResult{result.MatchedCount, result.ModifiedCount, result.UpsertedCount, result.UpsertedID}, nil
+}
+
+func (m mongoCollection[T]) replace(ctx context.Context, id string, doc T) (UpdateResult, error) {
+	result, err := m.collection.ReplaceOne(ctx, bson.M{m.key: id}, doc)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	return UpdateResult{result.MatchedCount, result.ModifiedCount, result.UpsertedCount, result.UpsertedID}, nil
+}
+
+func (m mongoCollection[T]) delete(ctx context.Context, id string) error {
+	_, err := m.collection.DeleteOne(ctx, bson.M{m.key: id})
+	return err
+}
+
+// modify applies the patch and returns the document as it is afterwards.
+func (m mongoCollection[T]) modify(ctx context.Context, id string, patch Patch) (T, error) {
+	return m.modifyWhere(ctx, m.filter(id, patch), patch.update())
+}
+
+func (m mongoCollection[T]) modifyWhere(ctx context.Context, filter interface{}, update interface{}) (T, error) {
+	var doc T
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := m.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&doc)
+	return doc, notFound(err)
+}
+
+func (m mongoCollection[T]) filter(id string, patch Patch) bson.M {
+	filter := bson.M{m.key: id}
+	for field, value := range patch.If {
+		filter[field] = value
+	}
+	return filter
+}
+
+func (patch Patch) update() bson.M {
+	update := bson.M{}
+	if len(patch.Set) > 0 {
+		update["$set"] = patch.Set
+	}
+	if len(patch.Unset) > 0 {
+		unset := bson.M{}
+		for _, field := range patch.Unset {
+			unset[field] = ""
+		}
+		update["$unset"] = unset
+	}
+	if len(patch.Inc) > 0 {
+		update["$inc"] = patch.Inc
+	}
+	if len(patch.Push) > 0 {
+		push := bson.M{}
+		for field, values := range patch.Push {
+			push[field] = bson.M{"$each": values}
+		}
+		update["$push"] = push
+	}
+	return update
+}
+
+func notFound(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type OrderItemStore interface {
+	Get(ctx context.Context, orderItemId string) (models.OrderItem, error)
+	All(ctx context.Context) ([]bson.M, error)
+	// ForOrder returns the order's items sorted by order_item_id.
+	ForOrder(ctx context.Context, orderId string) ([]models.OrderItem, error)
+	// Pending returns the items still waiting on the kitchen, oldest first,
+	// for one station or all of them when station is empty.
+	Pending(ctx context.Context, station string) ([]models.OrderItem, error)
+	InsertMany(ctx context.Context, orderItems []models.OrderItem) (InsertManyResult, error)
+	Update(ctx context.Context, orderItemId string, patch Patch) (UpdateResult, error)
+	Modify(ctx context.Context, orderItemId string, patch Patch) (models.OrderItem, error)
+	// VoidPending pulls the order's pending items off the kitchen screens and
+	// returns them as they were.
+	VoidPending(ctx context.Context, orderId string, at time.Time) ([]models.OrderItem, error)
+}
+
+type mongoOrderItems struct {
+	mongoCollection[models.OrderItem]
+}
+
+func (m mongoOrderItems) Get(ctx context.Context, orderItemId string) (models.OrderItem, error) {
+	return m.get(ctx, orderItemId)
+}
+
+func (m mongoOrderItems) All(ctx context.Context) ([]bson.M, error) {
+	return m.raw(ctx, bson.M{})
+}
+
+func (m mongoOrderItems) ForOrder(ctx context.Context, orderId string) ([]models.OrderItem, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "order_item_id", Value: 1}})
+	return m.find(ctx, bson.M{"order_id": orderId}, opts)
+}
+
+func (m mongoOrderItems) Pending(ctx context.Context, station string) ([]models.OrderItem, error) {
+	filter := bson.M{"kitchen_status": models.KITCHEN_PENDING}
+	if station != "" {
+		filter["station"] = station
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	return m.find(ctx, filter, opts)
+}
+
+func (m mongoOrderItems) InsertMany(ctx context.Context, orderItems []models.OrderItem) (InsertManyResult, error) {
+	return m.insertMany(ctx, orderItems)
+}
+
+func (m mongoOrderItems) Update(ctx context.Context, orderItemId string, patch Patch) (UpdateResult, error) {
+	return m.update(ctx, orderItemId, patch)
+}
+
+func (m mongoOrderItems) Modify(ctx context.Context, orderItemId string, patch Patch) (models.OrderItem, error) {
+	return m.modify(ctx, orderItemId, patch)
+}
+
+func (m mongoOrderItems) VoidPending(ctx context.Context, orderId string, at time.Time) ([]models.OrderItem, error) {
+	filter := bson.M{"order_id": orderId, "kitchen_status": models.KITCHEN_PENDING}
+	orderItems, err := m.find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	_, err = m.collection.UpdateMany(ctx, filter,
+		bson.M{"$set": bson.M{"kitchen_status": models.KITCHEN_VOIDED, "updated_at": at}},
+	)
+	return orderItems, err
+}
+
+type memoryOrderItems struct {
+	memoryCollection[models.OrderItem]
+}
+
+func (m memoryOrderItems) Get(ctx context.Context, orderItemId string) (models.OrderItem, error) {
+	return m.get(orderItemId)
+}
+
+func (m memoryOrderItems) All(ctx context.Context) ([]bson.M, error) {
+	return m.raw(nil)
+}
+
+func (m memoryOrderItems) ForOrder(ctx context.Context, orderId string) ([]models.OrderItem, error) {
+	orderItems, err := m.find(func(orderItem models.OrderItem) bool {
+		return orderItem.Order_id == orderId
+	})
+	sort.SliceStable(orderItems, func(a, b int) bool { return orderItems[a].Order_item_id < orderItems[b].Order_item_id })
+	return orderItems, err
+}
+
+func (m memoryOrderItems) Pending(ctx context.Context, station string) ([]models.OrderItem, error) {
+	orderItems, err := m.find(func(orderItem models.OrderItem) bool {
+		return orderItem.Kitchen_status == models.KITCHEN_PENDING && (station == "" || orderItem.Station == station)
+	})
+	sort.SliceStable(orderItems, func(a, b int) bool { return orderItems[a].Created_at.Before(orderItems[b].Created_at) })
+	return orderItems, err
+}
+
+func (m memoryOrderItems) InsertMany(ctx context.Context, orderItems []models.OrderItem) (InsertManyResult, error) {
+	return m.insertMany(orderItems)
+}
+
+func (m memoryOrderItems) Update(ctx context.Context, orderItemId string, patch Patch) (UpdateResult, error) {
+	return m.update(orderItemId, patch)
+}
+
+func (m memoryOrderItems) Modify(ctx context.Context, orderItemId string, patch Patch) (models.OrderItem, error) {
+	return m.modify(orderItemId, patch)
+}
+
+func (m memoryOrderItems) VoidPending(ctx context.Context, orderId string, at time.Time) ([]models.OrderItem, error) {
+	return m.updateWhere(func(orderItem models.OrderItem) bool {
+		return orderItem.Order_id == orderId && orderItem.Kitchen_status == models.KITCHEN_PENDING
+	}, Patch{Set: bson.M{"kitchen_status": models.KITCHEN_VOIDED, "updated_at": at}})
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type OrderStore interface {
+	Get(ctx context.Context, orderId string) (models.Order, error)
+	All(ctx context.Context) ([]bson.M, error)
+	// WithStatus returns the orders in any of the statuses, newest first,
+	// at most limit of them unless limit is zero.
+	WithStatus(ctx context.Context, limit int64, statuses ...string) ([]models.Order, error)
+	Insert(ctx context.Context, order models.Order) (InsertResult, error)
+	Update(ctx context.Context, orderId string, patch Patch) (UpdateResult, error)
+	// Transition moves the order on only while it is still in the status it
+	// was read with, so concurrent moves fail instead of skipping a step.
+	Transition(ctx context.Context, order models.Order, change models.OrderStatusChange) (UpdateResult, error)
+	Delete(ctx context.Context, orderId string) error
+}
+
+type mongoOrders struct {
+	mongoCollection[models.Order]
+}
+
+func (m mongoOrders) Get(ctx context.Context, orderId string) (models.Order, error) {
+	return m.get(ctx, orderId)
+}
+
+func (m mongoOrders) All(ctx context.Context) ([]bson.M, error) {
+	return m.raw(ctx, bson.M{})
+}
+
+func (m mongoOrders) WithStatus(ctx context.Context, limit int64, statuses ...string) ([]models.Order, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	return m.find(ctx, bson.M{"status": bson.M{"$in": statuses}}, opts)
+}
+
+func (m mongoOrders) Insert(ctx context.Context, order models.Order) (InsertResult, error) {
+	return m.insert(ctx, order)
+}
+
+func (m mongoOrders) Update(ctx context.Context, orderId string, patch Patch) (UpdateResult, error) {
+	return m.update(ctx, orderId, patch)
+}
+
+func (m mongoOrders) Transition(ctx context.Context, order models.Order, change models.OrderStatusChange) (UpdateResult, error) {
+	filter := bson.M{"order_id": order.Order_id, "status": change.From}
+	if order.Status == nil || *order.Status == "" {
+		filter = bson.M{"order_id": order.Order_id, "status": bson.M{"$in": []interface{}{nil, ""}}}
+	}
+
+	result, err := m.collection.UpdateOne(
+		ctx,
+		filter,
+		bson.M{
+			"$set":  bson.M{"status": change.To, "updated_at": change.Changed_at},
+			"$push": bson.M{"status_history": change},
+		},
+	)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	return UpdateResult{result.MatchedCount, result.ModifiedCount, result.UpsertedCount, result.UpsertedID}, nil
+}
+
+func (m mongoOrders) Delete(ctx context.Context, orderId string) error {
+	return m.delete(ctx, orderId)
+}
+
+type memoryOrders struct {
+	memoryCollection[models.Order]
+}
+
+func (m memoryOrders) Get(ctx context.Context, orderId string) (models.Order, error) {
+	return m.get(orderId)
+}
+
+func (m memoryOrders) All(ctx context.Context) ([]bson.M, error) {
+	return m.raw(nil)
+}
+
+func (m memoryOrders) WithStatus(ctx context.Context, limit int64, statuses ...string) ([]models.Order, error) {
+	orders, err := m.find(func(order models.Order) bool {
+		return order.Status != nil && containsString(statuses, *order.Status)
+	})
+	sort.SliceStable(orders, func(a, b int) bool { return orders[a].Created_at.After(orders[b].Created_at) })
+	if limit > 0 && int64(len(orders)) > limit {
+		orders = orders[:limit]
+	}
+	return orders, err
+}
+
+func (m memoryOrders) Insert(ctx context.Context, order models.Order) (InsertResult, error) {
+	return m.insert(order)
+}
+
+func (m memoryOrders) Update(ctx context.Context, orderId string, patch Patch) (UpdateResult, error) {
+	return m.update(orderId, patch)
+}
+
+func (m memoryOrders) Transition(ctx context.Context, order models.Order, change models.OrderStatusChange) (UpdateResult, error) {
+	legacy := order.Status == nil || *order.Status == ""
+	_, err := m.modifyIf(order.Order_id, func(stored models.Order) bool {
+		if legacy {
+			return stored.Status == nil || *stored.Status == ""
+		}
+		return stored.Status != nil && *stored.Status == change.From
+	}, Patch{
+		Set:  bson.M{"status": change.To, "updated_at": change.Changed_at},
+		Push: bson.M{"status_history": []models.OrderStatusChange{change}},
+	})
+	if err == ErrNotFound {
+		return UpdateResult{}, nil
+	}
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	return UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
+
+func (m memoryOrders) Delete(ctx context.Context, orderId string) error {
+	return m.delete(orderId)
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PaymentStore interface {
+	// ForInvoice returns the payments taken against an invoice, oldest first.
+	ForInvoice(ctx context.Context, invoiceId string) ([]models.Payment, error)
+	Insert(ctx context.Context, payment models.Payment) (InsertResult, error)
+	Delete(ctx context.Context, paymentId string) error
+}
+
+type mongoPayments struct {
+	mongoCollection[models.Payment]
+}
+
+func (m mongoPayments) ForInvoice(ctx context.Context, invoiceId string) ([]models.Payment, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	return m.find(ctx, bson.M{"invoice_id": invoiceId}, opts)
+}
+
+func (m mongoPayments) Insert(ctx context.Context, payment models.Payment) (InsertResult, error) {
+	return m.insert(ctx, payment)
+}
+
+func (m mongoPayments) Delete(ctx context.Context, paymentId string) error {
+	return m.delete(ctx, paymentId)
+}
+
+type memoryPayments struct {
+	memoryCollection[models.Payment]
+}
+
+func (m memoryPayments) ForInvoice(ctx context.Context, invoiceId string) ([]models.Payment, error) {
+	payments, err := m.find(func(payment models.Payment) bool {
+		return payment.Invoice_id == invoiceId
+	})
+	sort.SliceStable(payments, func(a, b int) bool { return payments[a].Created_at.Before(payments[b].Created_at) })
+	return payments, err
+}
+
+func (m memoryPayments) Insert(ctx context.Context, payment models.Payment) (InsertResult, error) {
+	return m.insert(payment)
+}
+
+func (m memoryPayments) Delete(ctx context.Context, paymentId string) error {
+	return m.delete(paymentId)
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PricingRuleStore interface {
+	Get(ctx context.Context, ruleId string) (models.PricingRule, error)
+	// List returns every rule, highest priority first and then by name.
+	List(ctx context.Context) ([]models.PricingRule, error)
+	// Enabled returns the rules not switched off, whether or not they are in
+	// their window right now.
+	Enabled(ctx context.Context) ([]models.PricingRule, error)
+	Insert(ctx context.Context, rule models.PricingRule) (InsertResult, error)
+	Update(ctx context.Context, ruleId string, patch Patch) (UpdateResult, error)
+}
+
+type mongoPricingRules struct {
+	mongoCollection[models.PricingRule]
+}
+
+func (m mongoPricingRules) Get(ctx context.Context, ruleId string) (models.PricingRule, error) {
+	return m.get(ctx, ruleId)
+}
+
+func (m mongoPricingRules) List(ctx context.Context) ([]models.PricingRule, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "priority", Value: -1}, {Key: "name", Value: 1}})
+	return m.find(ctx, bson.M{}, opts)
+}
+
+func (m mongoPricingRules) Enabled(ctx context.Context) ([]models.PricingRule, error) {
+	return m.find(ctx, bson.M{"enabled": bson.M{"$ne": false}})
+}
+
+func (m mongoPricingRules) Insert(ctx context.Context, rule models.PricingRule) (InsertResult, error) {
+	return m.insert(ctx, rule)
+}
+
+func (m mongoPricingRules) Update(ctx context.Context, ruleId string, patch Patch) (UpdateResult, error) {
+	return m.update(ctx, ruleId, patch)
+}
+
+type memoryPricingRules struct {
+	memoryCollection[models.PricingRule]
+}
+
+func (m memoryPricingRules) Get(ctx context.Context, ruleId string) (models.PricingRule, error) {
+	return m.get(ruleId)
+}
+
+func (m memoryPricingRules) List(ctx context.Context) ([]models.PricingRule, error) {
+	rules, err := m.find(nil)
+	sort.SliceStable(rules, func(a, b int) bool {
+		if rules[a].Priority != rules[b].Priority {
+			return rules[a].Priority > rules[b].Priority
+		}
+		return valueOfString(rules[a].Name) < valueOfString(rules[b].Name)
+	})
+	return rules, err
+}
+
+func (m memoryPricingRules) Enabled(ctx context.Context) ([]models.PricingRule, error) {
+	return m.find(func(rule models.PricingRule) bool {
+		return rule.IsEnabled()
+	})
+}
+
+func (m memoryPricingRules) Insert(ctx context.Context, rule models.PricingRule) (InsertResult, error) {
+	return m.insert(rule)
+}
+
+func (m memoryPricingRules) Update(ctx context.Context, ruleId string, patch Patch) (UpdateResult, error) {
+	return m.update(ruleId, patch)
+}
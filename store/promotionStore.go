@@ -0,0 +1,232 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PromotionStore interface {
+	Get(ctx context.Context, promotionId string) (models.Promotion, error)
+	ByCode(ctx context.Context, code string) (models.Promotion, error)
+	// List returns every promotion, newest first.
+	List(ctx context.Context) ([]models.Promotion, error)
+	// Automatic returns the enabled promotions that need no code.
+	Automatic(ctx context.Context) ([]models.Promotion, error)
+	// CodeTaken reports whether a promotion other than exceptId has the code.
+	CodeTaken(ctx context.Context, code string, exceptId string) (bool, error)
+	// Insert and Update return ErrDuplicate when another promotion has the
+	// code, even if CodeTaken said it was free a moment before.
+	Insert(ctx context.Context, promotion models.Promotion) (InsertResult, error)
+	Update(ctx context.Context, promotionId string, patch Patch) (UpdateResult, error)
+	// Redeem counts one use, or returns ErrNotFound once max_uses is reached.
+	Redeem(ctx context.Context, promotionId string) error
+	// Release gives a use back.
+	Release(ctx context.Context, promotionId string) error
+}
+
+type mongoPromotions struct {
+	mongoCollection[models.Promotion]
+}
+
+func (m mongoPromotions) Get(ctx context.Context, promotionId string) (models.Promotion, error) {
+	return m.get(ctx, promotionId)
+}
+
+func (m mongoPromotions) ByCode(ctx context.Context, code string) (models.Promotion, error) {
+	var promotion models.Promotion
+	err := m.collection.FindOne(ctx, bson.M{"code": code}).Decode(&promotion)
+	return promotion, notFound(err)
+}
+
+func (m mongoPromotions) List(ctx context.Context) ([]models.Promotion, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	return m.find(ctx, bson.M{}, opts)
+}
+
+func (m mongoPromotions) Automatic(ctx context.Context) ([]models.Promotion, error) {
+	return m.find(ctx, bson.M{"code": nil, "enabled": bson.M{"$ne": false}})
+}
+
+func (m mongoPromotions) CodeTaken(ctx context.Context, code string, exceptId string) (bool, error) {
+	count, err := m.collection.CountDocuments(ctx, bson.M{"code": code, "promotion_id": bson.M{"$ne": exceptId}})
+	return count > 0, err
+}
+
+func (m mongoPromotions) Insert(ctx context.Context, promotion models.Promotion) (InsertResult, error) {
+	result, err := m.insert(ctx, promotion)
+	if mongo.IsDuplicateKeyError(err) {
+		return result, ErrDuplicate
+	}
+	return result, err
+}
+
+func (m mongoPromotions) Update(ctx context.Context, promotionId string, patch Patch) (UpdateResult, error) {
+	result, err := m.update(ctx, promotionId, patch)
+	if mongo.IsDuplicateKeyError(err) {
+		return result, ErrDuplicate
+	}
+	return result, err
+}
+
+// Redeem checks max_uses in the same update that counts the use, so two
+// tills redeeming the last use at the same moment cannot both get it.
+func (m mongoPromotions) Redeem(ctx context.Context, promotionId string) error {
+	filter := bson.M{
+		"promotion_id": promotionId,
+		"$or": bson.A{
+			bson.M{"max_uses": nil},
+			bson.M{"$expr": bson.M{"$lt": bson.A{"$uses", "$max_uses"}}},
+		},
+	}
+	result, err := m.collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"uses": 1}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m mongoPromotions) Release(ctx context.Context, promotionId string) error {
+	_, err := m.collection.UpdateOne(ctx,
+		bson.M{"promotion_id": promotionId, "uses": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"uses": -1}},
+	)
+	return err
+}
+
+// memoryPromotions stands in for the unique index on code by checking and
+// writing under one lock.
+type memoryPromotions struct {
+	memoryCollection[models.Promotion]
+	codes *sync.Mutex
+}
+
+func (m memoryPromotions) Get(ctx context.Context, promotionId string) (models.Promotion, error) {
+	return m.get(promotionId)
+}
+
+func (m memoryPromotions) ByCode(ctx context.Context, code string) (models.Promotion, error) {
+	promotions, err := m.find(func(promotion models.Promotion) bool {
+		return promotion.Code != nil && *promotion.Code == code
+	})
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	if len(promotions) == 0 {
+		return models.Promotion{}, ErrNotFound
+	}
+	return promotions[0], nil
+}
+
+func (m memoryPromotions) List(ctx context.Context) ([]models.Promotion, error) {
+	promotions, err := m.find(nil)
+	sort.SliceStable(promotions, func(a, b int) bool {
+		return promotions[a].Created_at.After(promotions[b].Created_at)
+	})
+	return promotions, err
+}
+
+func (m memoryPromotions) Automatic(ctx context.Context) ([]models.Promotion, error) {
+	return m.find(func(promotion models.Promotion) bool {
+		return promotion.Code == nil && promotion.IsEnabled()
+	})
+}
+
+func (m memoryPromotions) CodeTaken(ctx context.Context, code string, exceptId string) (bool, error) {
+	promotions, err := m.find(func(promotion models.Promotion) bool {
+		return promotion.Code != nil && *promotion.Code == code && promotion.Promotion_id != exceptId
+	})
+	return len(promotions) > 0, err
+}
+
+func (m memoryPromotions) Insert(ctx context.Context, promotion models.Promotion) (InsertResult, error) {
+	m.codes.Lock()
+	defer m.codes.Unlock()
+
+	if promotion.Code != nil {
+		if taken, err := m.CodeTaken(ctx, *promotion.Code, promotion.Promotion_id); err != nil || taken {
+			return InsertResult{}, duplicateOr(err)
+		}
+	}
+	return m.insert(promotion)
+}
+
+func (m memoryPromotions) Update(ctx context.Context, promotionId string, patch Patch) (UpdateResult, error) {
+	m.codes.Lock()
+	defer m.codes.Unlock()
+
+	if code, ok := patch.Set["code"].(*string); ok && code != nil {
+		if taken, err := m.CodeTaken(ctx, *code, promotionId); err != nil || taken {
+			return UpdateResult{}, duplicateOr(err)
+		}
+	}
+	return m.update(promotionId, patch)
+}
+
+func duplicateOr(err error) error {
+	if err != nil {
+		return err
+	}
+	return ErrDuplicate
+}
+
+func (m memoryPromotions) Redeem(ctx context.Context, promotionId string) error {
+	_, err := m.modifyIf(promotionId, func(promotion models.Promotion) bool {
+		return promotion.Max_uses == nil || promotion.Uses < *promotion.Max_uses
+	}, Patch{Inc: bson.M{"uses": 1}})
+	return err
+}
+
+func (m memoryPromotions) Release(ctx context.Context, promotionId string) error {
+	_, err := m.modifyIf(promotionId, func(promotion models.Promotion) bool {
+		return promotion.Uses > 0
+	}, Patch{Inc: bson.M{"uses": -1}})
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+type DiscountAuditStore interface {
+	// ForInvoice returns the invoice's audit trail, oldest first.
+	ForInvoice(ctx context.Context, invoiceId string) ([]models.DiscountAudit, error)
+	Insert(ctx context.Context, audit models.DiscountAudit) (InsertResult, error)
+}
+
+type mongoDiscountAudits struct {
+	mongoCollection[models.DiscountAudit]
+}
+
+func (m mongoDiscountAudits) ForInvoice(ctx context.Context, invoiceId string) ([]models.DiscountAudit, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: 1}})
+	return m.find(ctx, bson.M{"invoice_id": invoiceId}, opts)
+}
+
+func (m mongoDiscountAudits) Insert(ctx context.Context, audit models.DiscountAudit) (InsertResult, error) {
+	return m.insert(ctx, audit)
+}
+
+type memoryDiscountAudits struct {
+	memoryCollection[models.DiscountAudit]
+}
+
+func (m memoryDiscountAudits) ForInvoice(ctx context.Context, invoiceId string) ([]models.DiscountAudit, error) {
+	audit, err := m.find(func(entry models.DiscountAudit) bool {
+		return entry.Invoice_id == invoiceId
+	})
+	sort.SliceStable(audit, func(a, b int) bool { return audit[a].At.Before(audit[b].At) })
+	return audit, err
+}
+
+func (m memoryDiscountAudits) Insert(ctx context.Context, audit models.DiscountAudit) (InsertResult, error) {
+	return m.insert(audit)
+}
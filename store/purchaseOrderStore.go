@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PurchaseOrderStore interface {
+	Get(ctx context.Context, purchaseOrderId string) (models.PurchaseOrder, error)
+	// List returns the purchase orders newest first, narrowed to a status
+	// and supplier when they are not empty.
+	List(ctx context.Context, status string, supplierId string) ([]models.PurchaseOrder, error)
+	Insert(ctx context.Context, purchaseOrder models.PurchaseOrder) (InsertResult, error)
+	// Move applies set only while the purchase order is in one of the from
+	// statuses and returns it as it is afterwards, or ErrNotFound.
+	Move(ctx context.Context, purchaseOrderId string, from []string, set bson.M) (models.PurchaseOrder, error)
+}
+
+type mongoPurchaseOrders struct {
+	mongoCollection[models.PurchaseOrder]
+}
+
+func (m mongoPurchaseOrders) Get(ctx context.Context, purchaseOrderId string) (models.PurchaseOrder, error) {
+	return m.get(ctx, purchaseOrderId)
+}
+
+func (m mongoPurchaseOrders) List(ctx context.Context, status string, supplierId string) ([]models.PurchaseOrder, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	if supplierId != "" {
+		filter["supplier_id"] = supplierId
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	return m.find(ctx, filter, opts)
+}
+
+func (m mongoPurchaseOrders) Insert(ctx context.Context, purchaseOrder models.PurchaseOrder) (InsertResult, error) {
+	return m.insert(ctx, purchaseOrder)
+}
+
+func (m mongoPurchaseOrders) Move(ctx context.Context, purchaseOrderId string, from []string, set bson.M) (models.PurchaseOrder, error) {
+	return m.modifyWhere(ctx,
+		bson.M{"purchase_order_id": purchaseOrderId, "status": bson.M{"$in": from}},
+		bson.M{"$set": set},
+	)
+}
+
+type memoryPurchaseOrders struct {
+	memoryCollection[models.PurchaseOrder]
+}
+
+func (m memoryPurchaseOrders) Get(ctx context.Context, purchaseOrderId string) (models.PurchaseOrder, error) {
+	return m.get(purchaseOrderId)
+}
+
+func (m memoryPurchaseOrders) List(ctx context.Context, status string, supplierId string) ([]models.PurchaseOrder, error) {
+	purchaseOrders, err := m.find(func(purchaseOrder models.PurchaseOrder) bool {
+		return (status == "" || purchaseOrder.Status == status) &&
+			(supplierId == "" || valueOfString(purchaseOrder.Supplier_id) == supplierId)
+	})
+	sort.SliceStable(purchaseOrders, func(a, b int) bool {
+		return purchaseOrders[a].Created_at.After(purchaseOrders[b].Created_at)
+	})
+	return purchaseOrders, err
+}
+
+func (m memoryPurchaseOrders) Insert(ctx context.Context, purchaseOrder models.PurchaseOrder) (InsertResult, error) {
+	return m.insert(purchaseOrder)
+}
+
+func (m memoryPurchaseOrders) Move(ctx context.Context, purchaseOrderId string, from []string, set bson.M) (models.PurchaseOrder, error) {
+	return m.modifyIf(purchaseOrderId, func(purchaseOrder models.PurchaseOrder) bool {
+		return containsString(from, purchaseOrder.Status)
+	}, Patch{Set: set})
+}
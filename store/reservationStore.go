@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ReservationFilter narrows the reservation list; zero fields match all.
+// Reservations starting in [From, To) are kept when From is set.
+type ReservationFilter struct {
+	From     time.Time
+	To       time.Time
+	Status   string
+	Table_id string
+}
+
+type ReservationStore interface {
+	Get(ctx context.Context, reservationId string) (models.Reservation, error)
+	// List returns the matching reservations, earliest first.
+	List(ctx context.Context, filter ReservationFilter) ([]models.Reservation, error)
+	// BookedTables returns the tables with a booking still standing that
+	// overlaps [start, end), leaving out the reservation excludeId.
+	BookedTables(ctx context.Context, start time.Time, end time.Time, excludeId string) ([]string, error)
+	// Clashes reports whether another booking still standing overlaps the
+	// reservation on the same table.
+	Clashes(ctx context.Context, reservation models.Reservation) (bool, error)
+	Insert(ctx context.Context, reservation models.Reservation) (InsertResult, error)
+	Replace(ctx context.Context, reservation models.Reservation) (UpdateResult, error)
+	Delete(ctx context.Context, reservationId string) error
+}
+
+var activeReservationStatuses = []string{models.RESERVATION_BOOKED, models.RESERVATION_SEATED}
+
+func activeReservationFilter(start time.Time, end time.Time) bson.M {
+	return bson.M{
+		"status":     bson.M{"$in": activeReservationStatuses},
+		"start_time": bson.M{"$lt": end},
+		"end_time":   bson.M{"$gt": start},
+	}
+}
+
+func overlaps(reservation models.Reservation, start time.Time, end time.Time) bool {
+	return reservation.Status != nil && containsString(activeReservationStatuses, *reservation.Status) &&
+		reservation.Start_time != nil && reservation.Start_time.Before(end) && reservation.End_time.After(start)
+}
+
+type mongoReservations struct {
+	mongoCollection[models.Reservation]
+}
+
+func (m mongoReservations) Get(ctx context.Context, reservationId string) (models.Reservation, error) {
+	return m.get(ctx, reservationId)
+}
+
+func (m mongoReservations) List(ctx context.Context, filter ReservationFilter) ([]models.Reservation, error) {
+	match := bson.M{}
+	if !filter.From.IsZero() {
+		match["start_time"] = bson.M{"$gte": filter.From, "$lt": filter.To}
+	}
+	if filter.Status != "" {
+		match["status"] = filter.Status
+	}
+	if filter.Table_id != "" {
+		match["table_id"] = filter.Table_id
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "start_time", Value: 1}})
+	return m.find(ctx, match, opts)
+}
+
+func (m mongoReservations) BookedTables(ctx context.Context, start time.Time, end time.Time, excludeId string) ([]string, error) {
+	filter := activeReservationFilter(start, end)
+	filter["reservation_id"] = bson.M{"$ne": excludeId}
+	booked, err := m.collection.Distinct(ctx, "table_id", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var tableIds []string
+	for _, tableId := range booked {
+		if id, ok := tableId.(string); ok {
+			tableIds = append(tableIds, id)
+		}
+	}
+	return tableIds, nil
+}
+
+func (m mongoReservations) Clashes(ctx context.Context, reservation models.Reservation) (bool, error) {
+	filter := activeReservationFilter(*reservation.Start_time, reservation.End_time)
+	filter["table_id"] = reservation.Table_id
+	filter["reservation_id"] = bson.M{"$ne": reservation.Reservation_id}
+
+	count, err := m.collection.CountDocuments(ctx, filter)
+	return count > 0, err
+}
+
+func (m mongoReservations) Insert(ctx context.Context, reservation models.Reservation) (InsertResult, error) {
+	return m.insert(ctx, reservation)
+}
+
+func (m mongoReservations) Replace(ctx context.Context, reservation models.Reservation) (UpdateResult, error) {
+	return m.replace(ctx, reservation.Reservation_id, reservation)
+}
+
+func (m mongoReservations) Delete(ctx context.Context, reservationId string) error {
+	return m.delete(ctx, reservationId)
+}
+
+type memoryReservations struct {
+	memoryCollection[models.Reservation]
+}
+
+func (m memoryReservations) Get(ctx context.Context, reservationId string) (models.Reservation, error) {
+	return m.get(reservationId)
+}
+
+func (m memoryReservations) List(ctx context.Context, filter ReservationFilter) ([]models.Reservation, error) {
+	reservations, err := m.find(func(reservation models.Reservation) bool {
+		if !filter.From.IsZero() && (reservation.Start_time == nil ||
+			reservation.Start_time.Before(filter.From) || !reservation.Start_time.Before(filter.To)) {
+			return false
+		}
+		return (filter.Status == "" || valueOfString(reservation.Status) == filter.Status) &&
+			(filter.Table_id == "" || valueOfString(reservation.Table_id) == filter.Table_id)
+	})
+	sort.SliceStable(reservations, func(a, b int) bool {
+		return reservations[a].Start_time.Before(*reservations[b].Start_time)
+	})
+	return reservations, err
+}
+
+func (m memoryReservations) BookedTables(ctx context.Context, start time.Time, end time.Time, excludeId string) ([]string, error) {
+	reservations, err := m.find(func(reservation models.Reservation) bool {
+		return reservation.Reservation_id != excludeId && reservation.Table_id != nil && overlaps(reservation, start, end)
+	})
+	var tableIds []string
+	for _, reservation := range reservations {
+		if !containsString(tableIds, *reservation.Table_id) {
+			tableIds = append(tableIds, *reservation.Table_id)
+		}
+	}
+	return tableIds, err
+}
+
+func (m memoryReservations) Clashes(ctx context.Context, reservation models.Reservation) (bool, error) {
+	earlier, err := m.find(func(other models.Reservation) bool {
+		return other.Reservation_id != reservation.Reservation_id &&
+			valueOfString(other.Table_id) == valueOfString(reservation.Table_id) &&
+			overlaps(other, *reservation.Start_time, reservation.End_time)
+	})
+	return len(earlier) > 0, err
+}
+
+func (m memoryReservations) Insert(ctx context.Context, reservation models.Reservation) (InsertResult, error) {
+	return m.insert(reservation)
+}
+
+func (m memoryReservations) Replace(ctx context.Context, reservation models.Reservation) (UpdateResult, error) {
+	return m.replace(reservation.Reservation_id, reservation)
+}
+
+func (m memoryReservations) Delete(ctx context.Context, reservationId string) error {
+	return m.delete(reservationId)
+}
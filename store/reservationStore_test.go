@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReservationOverlap(t *testing.T) {
+	start := time.Date(2026, 10, 17, 19, 0, 0, 0, time.UTC)
+	booked, cancelled := models.RESERVATION_BOOKED, models.RESERVATION_CANCELLED
+	reservation := models.Reservation{Start_time: &start, End_time: start.Add(90 * time.Minute), Status: &booked}
+
+	tests := []struct {
+		name   string
+		from   time.Duration
+		to     time.Duration
+		status *string
+		want   bool
+	}{
+		{"same slot", 0, 90 * time.Minute, &booked, true},
+		{"starts inside", 60 * time.Minute, 150 * time.Minute, &booked, true},
+		{"ends inside", -60 * time.Minute, 30 * time.Minute, &booked, true},
+		{"covers it", -time.Hour, 3 * time.Hour, &booked, true},
+		{"ends as it starts", -time.Hour, 0, &booked, false},
+		{"starts as it ends", 90 * time.Minute, 3 * time.Hour, &booked, false},
+		{"cancelled", 0, 90 * time.Minute, &cancelled, false},
+		{"no status", 0, 90 * time.Minute, nil, false},
+	}
+	for _, test := range tests {
+		reservation.Status = test.status
+		if got := overlaps(reservation, start.Add(test.from), start.Add(test.to)); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+// Clashes must see the other booking from both sides, whichever was made
+// first, or a rebook racing a new booking could leave both standing.
+func TestReservationClashesBothWays(t *testing.T) {
+	ctx := context.Background()
+	reservations := NewMemory().Reservations
+
+	tableId, booked := "table-1", models.RESERVATION_BOOKED
+	book := func(start time.Time) models.Reservation {
+		t.Helper()
+		reservation := models.Reservation{ID: primitive.NewObjectID(), Table_id: &tableId, Start_time: &start, End_time: start.Add(time.Hour), Status: &booked}
+		reservation.Reservation_id = reservation.ID.Hex()
+		if _, err := reservations.Insert(ctx, reservation); err != nil {
+			t.Fatal(err)
+		}
+		return reservation
+	}
+	older := book(time.Date(2026, 10, 17, 19, 0, 0, 0, time.UTC))
+	newer := book(time.Date(2026, 10, 17, 19, 30, 0, 0, time.UTC))
+
+	for _, reservation := range []models.Reservation{older, newer} {
+		clash, err := reservations.Clashes(ctx, reservation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !clash {
+			t.Errorf("expected %s to clash with the other booking", reservation.Start_time.Format(time.Kitchen))
+		}
+	}
+
+	later := book(time.Date(2026, 10, 17, 21, 0, 0, 0, time.UTC))
+	if clash, _ := reservations.Clashes(ctx, later); clash {
+		t.Error("expected a booking after both to stand alone")
+	}
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type StockMovementStore interface {
+	// ForIngredient returns the ingredient's latest movements, newest first.
+	ForIngredient(ctx context.Context, ingredientId string, limit int64) ([]models.StockMovement, error)
+	// Depletions returns what the order's items took from stock and has not
+	// been put back yet.
+	Depletions(ctx context.Context, orderId string) ([]models.StockMovement, error)
+	InsertMany(ctx context.Context, movements []models.StockMovement) (InsertManyResult, error)
+	Update(ctx context.Context, movementId string, patch Patch) (UpdateResult, error)
+}
+
+type mongoStockMovements struct {
+	mongoCollection[models.StockMovement]
+}
+
+func (m mongoStockMovements) ForIngredient(ctx context.Context, ingredientId string, limit int64) ([]models.StockMovement, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(limit)
+	return m.find(ctx, bson.M{"ingredient_id": ingredientId}, opts)
+}
+
+func (m mongoStockMovements) Depletions(ctx context.Context, orderId string) ([]models.StockMovement, error) {
+	return m.find(ctx, bson.M{"order_id": orderId, "reason": models.STOCK_DEPLETION, "reversed": false})
+}
+
+func (m mongoStockMovements) InsertMany(ctx context.Context, movements []models.StockMovement) (InsertManyResult, error) {
+	return m.insertMany(ctx, movements)
+}
+
+func (m mongoStockMovements) Update(ctx context.Context, movementId string, patch Patch) (UpdateResult, error) {
+	return m.update(ctx, movementId, patch)
+}
+
+type memoryStockMovements struct {
+	memoryCollection[models.StockMovement]
+}
+
+func (m memoryStockMovements) ForIngredient(ctx context.Context, ingredientId string, limit int64) ([]models.StockMovement, error) {
+	movements, err := m.find(func(movement models.StockMovement) bool {
+		return movement.Ingredient_id == ingredientId
+	})
+	sort.SliceStable(movements, func(a, b int) bool { return movements[a].Created_at.After(movements[b].Created_at) })
+	if limit > 0 && int64(len(movements)) > limit {
+		movements = movements[:limit]
+	}
+	return movements, err
+}
+
+func (m memoryStockMovements) Depletions(ctx context.Context, orderId string) ([]models.StockMovement, error) {
+	return m.find(func(movement models.StockMovement) bool {
+		return movement.Order_id == orderId && movement.Reason == models.STOCK_DEPLETION && !movement.Reversed
+	})
+}
+
+func (m memoryStockMovements) InsertMany(ctx context.Context, movements []models.StockMovement) (InsertManyResult, error) {
+	return m.insertMany(movements)
+}
+
+func (m memoryStockMovements) Update(ctx context.Context, movementId string, patch Patch) (UpdateResult, error) {
+	return m.update(movementId, patch)
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"errors"
+	"sync"
+
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound is returned when no document has the id asked for, or when a
+// conditional change found nothing in the state it expected.
+var ErrNotFound = errors.New("store: not found")
+
+// ErrDuplicate is returned when a write would break a unique index.
+var ErrDuplicate = errors.New("store: duplicate")
+
+// Store holds one store per aggregate. Handlers are given a Store rather than
+// opening collections themselves, so the API runs against MongoDB in
+// production and against NewMemory in tests.
+type Store struct {
+	Foods      FoodStore
+	Menus      MenuStore
+	Tables     TableStore
+	Orders     OrderStore
+	OrderItems OrderItemStore
+	Invoices   InvoiceStore
+	Users      UserStore
+
+	Ingredients    IngredientStore
+	StockMovements StockMovementStore
+	Suppliers      SupplierStore
+	PurchaseOrders PurchaseOrderStore
+	PricingRules   PricingRuleStore
+	Promotions     PromotionStore
+	DiscountAudits DiscountAuditStore
+	Payments       PaymentStore
+	Reservations   ReservationStore
+	Waitlist       WaitlistStore
+}
+
+// Patch is a partial update of one document, addressed by its id.
+//
+//   - If holds fields that must equal the given values for the patch to
+//     apply; nil matches a missing field.
+//   - Set and Unset write and remove fields, Inc adds to numeric fields and
+//     Push appends every element of the given slices to array fields.
+//   - Upsert creates the document when there is none with that id.
+type Patch struct {
+	If     bson.M
+	Set    bson.M
+	Unset  []string
+	Inc    bson.M
+	Push   bson.M
+	Upsert bool
+}
+
+// The result types keep the field names of the driver's results, which the
+// API has always returned as they are.
+type InsertResult struct {
+	InsertedID interface{}
+}
+
+type InsertManyResult struct {
+	InsertedIDs []interface{}
+}
+
+type UpdateResult struct {
+	MatchedCount  int64
+	ModifiedCount int64
+	UpsertedCount int64
+	UpsertedID    interface{}
+}
+
+func NewMongo(client *mongo.Client) *Store {
+	return &Store{
+		Foods:      mongoFoods{newMongoCollection[models.Food](client, "food", "food_id")},
+		Menus:      mongoMenus{newMongoCollection[models.Menu](client, "menu", "menu_id")},
+		Tables:     mongoTables{newMongoCollection[models.Table](client, "table", "table_id")},
+		Orders:     mongoOrders{newMongoCollection[models.Order](client, "order", "order_id")},
+		OrderItems: mongoOrderItems{newMongoCollection[models.OrderItem](client, "orderItem", "order_item_id")},
+		Invoices:   mongoInvoices{newMongoCollection[models.Invoice](client, "invoice", "invoice_id")},
+		Users:      mongoUsers{newMongoCollection[models.User](client, "user", "user_id")},
+
+		Ingredients:    mongoIngredients{newMongoCollection[models.Ingredient](client, "ingredient", "ingredient_id")},
+		StockMovements: mongoStockMovements{newMongoCollection[models.StockMovement](client, "stockMovement", "movement_id")},
+		Suppliers:      mongoSuppliers{newMongoCollection[models.Supplier](client, "supplier", "supplier_id")},
+		PurchaseOrders: mongoPurchaseOrders{newMongoCollection[models.PurchaseOrder](client, "purchaseOrder", "purchase_order_id")},
+		PricingRules:   mongoPricingRules{newMongoCollection[models.PricingRule](client, "pricingRule", "pricing_rule_id")},
+		Promotions:     mongoPromotions{newMongoCollection[models.Promotion](client, "promotion", "promotion_id")},
+		DiscountAudits: mongoDiscountAudits{newMongoCollection[models.DiscountAudit](client, "discountAudit", "audit_id")},
+		Payments:       mongoPayments{newMongoCollection[models.Payment](client, "payment", "payment_id")},
+		Reservations:   mongoReservations{newMongoCollection[models.Reservation](client, "reservation", "reservation_id")},
+		Waitlist:       mongoWaitlist{newMongoCollection[models.WaitlistEntry](client, "waitlist", "waitlist_id")},
+	}
+}
+
+// NewMemory keeps everything in process, for tests and local runs without a
+// database. Documents go through the same BSON encoding as in MongoDB, so
+// what comes back has the shape the Mongo store would give.
+func NewMemory() *Store {
+	return &Store{
+		Foods:      memoryFoods{newMemoryCollection[models.Food]("food_id")},
+		Menus:      memoryMenus{newMemoryCollection[models.Menu]("menu_id")},
+		Tables:     memoryTables{newMemoryCollection[models.Table]("table_id")},
+		Orders:     memoryOrders{newMemoryCollection[models.Order]("order_id")},
+		OrderItems: memoryOrderItems{newMemoryCollection[models.OrderItem]("order_item_id")},
+		Invoices:   memoryInvoices{newMemoryCollection[models.Invoice]("invoice_id")},
+		Users:      memoryUsers{newMemoryCollection[models.User]("user_id"), &sync.Mutex{}},
+
+		Ingredients:    memoryIngredients{newMemoryCollection[models.Ingredient]("ingredient_id")},
+		StockMovements: memoryStockMovements{newMemoryCollection[models.StockMovement]("movement_id")},
+		Suppliers:      memorySuppliers{newMemoryCollection[models.Supplier]("supplier_id")},
+		PurchaseOrders: memoryPurchaseOrders{newMemoryCollection[models.PurchaseOrder]("purchase_order_id")},
+		PricingRules:   memoryPricingRules{newMemoryCollection[models.PricingRule]("pricing_rule_id")},
+		Promotions:     memoryPromotions{newMemoryCollection[models.Promotion]("promotion_id"), &sync.Mutex{}},
+		DiscountAudits: memoryDiscountAudits{newMemoryCollection[models.DiscountAudit]("audit_id")},
+		Payments:       memoryPayments{newMemoryCollection[models.Payment]("payment_id")},
+		Reservations:   memoryReservations{newMemoryCollection[models.Reservation]("reservation_id")},
+		Waitlist:       memoryWaitlist{newMemoryCollection[models.WaitlistEntry]("waitlist_id")},
+	}
+}
+
+func openCollection(client *mongo.Client, name string) *mongo.Collection {
+	return database.OpenCollection(client, name)
+}
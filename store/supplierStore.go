@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SupplierStore interface {
+	Get(ctx context.Context, supplierId string) (models.Supplier, error)
+	// List returns every supplier by name.
+	List(ctx context.Context) ([]models.Supplier, error)
+	Insert(ctx context.Context, supplier models.Supplier) (InsertResult, error)
+	Update(ctx context.Context, supplierId string, patch Patch) (UpdateResult, error)
+}
+
+type mongoSuppliers struct {
+	mongoCollection[models.Supplier]
+}
+
+func (m mongoSuppliers) Get(ctx context.Context, supplierId string) (models.Supplier, error) {
+	return m.get(ctx, supplierId)
+}
+
+func (m mongoSuppliers) List(ctx context.Context) ([]models.Supplier, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	return m.find(ctx, bson.M{}, opts)
+}
+
+func (m mongoSuppliers) Insert(ctx context.Context, supplier models.Supplier) (InsertResult, error) {
+	return m.insert(ctx, supplier)
+}
+
+func (m mongoSuppliers) Update(ctx context.Context, supplierId string, patch Patch) (UpdateResult, error) {
+	return m.update(ctx, supplierId, patch)
+}
+
+type memorySuppliers struct {
+	memoryCollection[models.Supplier]
+}
+
+func (m memorySuppliers) Get(ctx context.Context, supplierId string) (models.Supplier, error) {
+	return m.get(supplierId)
+}
+
+func (m memorySuppliers) List(ctx context.Context) ([]models.Supplier, error) {
+	suppliers, err := m.find(nil)
+	sort.SliceStable(suppliers, func(a, b int) bool {
+		return valueOfString(suppliers[a].Name) < valueOfString(suppliers[b].Name)
+	})
+	return suppliers, err
+}
+
+func (m memorySuppliers) Insert(ctx context.Context, supplier models.Supplier) (InsertResult, error) {
+	return m.insert(supplier)
+}
+
+func (m memorySuppliers) Update(ctx context.Context, supplierId string, patch Patch) (UpdateResult, error) {
+	return m.update(supplierId, patch)
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type TableStore interface {
+	Get(ctx context.Context, tableId string) (models.Table, error)
+	List(ctx context.Context) ([]models.Table, error)
+	// Seating returns the tables with room for the party, smallest first and
+	// then by table number.
+	Seating(ctx context.Context, partySize int) ([]models.Table, error)
+	Insert(ctx context.Context, table models.Table) (InsertResult, error)
+	Update(ctx context.Context, tableId string, patch Patch) (UpdateResult, error)
+	// Claim holds the table for claimId while a party is seated or booked, returning
+	// ErrNotFound while another claim made after staleBefore holds it.
+	Claim(ctx context.Context, tableId string, claimId string, at time.Time, staleBefore time.Time) error
+	// Release drops claimId's claim, leaving anyone else's alone.
+	Release(ctx context.Context, tableId string, claimId string) error
+}
+
+type mongoTables struct {
+	mongoCollection[models.Table]
+}
+
+func (m mongoTables) Get(ctx context.Context, tableId string) (models.Table, error) {
+	return m.get(ctx, tableId)
+}
+
+func (m mongoTables) List(ctx context.Context) ([]models.Table, error) {
+	return m.find(ctx, bson.M{})
+}
+
+func (m mongoTables) Seating(ctx context.Context, partySize int) ([]models.Table, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "number_of_guests", Value: 1}, {Key: "table_number", Value: 1}})
+	return m.find(ctx, bson.M{"number_of_guests": bson.M{"$gte": partySize}}, opts)
+}
+
+func (m mongoTables) Insert(ctx context.Context, table models.Table) (InsertResult, error) {
+	return m.insert(ctx, table)
+}
+
+func (m mongoTables) Update(ctx context.Context, tableId string, patch Patch) (UpdateResult, error) {
+	return m.update(ctx, tableId, patch)
+}
+
+func (m mongoTables) Claim(ctx context.Context, tableId string, claimId string, at time.Time, staleBefore time.Time) error {
+	filter := bson.M{
+		"table_id": tableId,
+		"$or": bson.A{
+			bson.M{"seating_claim": nil},
+			bson.M{"seating_claim.at": bson.M{"$lt": staleBefore}},
+		},
+	}
+	claim := models.SeatingClaim{Claim_id: claimId, At: at}
+	result, err := m.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"seating_claim": claim}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m mongoTables) Release(ctx context.Context, tableId string, claimId string) error {
+	_, err := m.update(ctx, tableId, Patch{If: bson.M{"seating_claim.claim_id": claimId}, Unset: []string{"seating_claim"}})
+	return err
+}
+
+type memoryTables struct {
+	memoryCollection[models.Table]
+}
+
+func (m memoryTables) Get(ctx context.Context, tableId string) (models.Table, error) {
+	return m.get(tableId)
+}
+
+func (m memoryTables) List(ctx context.Context) ([]models.Table, error) {
+	return m.find(nil)
+}
+
+func (m memoryTables) Seating(ctx context.Context, partySize int) ([]models.Table, error) {
+	tables, err := m.find(func(table models.Table) bool {
+		return table.Number_of_guests != nil && *table.Number_of_guests >= partySize
+	})
+	sort.SliceStable(tables, func(a, b int) bool {
+		if *tables[a].Number_of_guests != *tables[b].Number_of_guests {
+			return *tables[a].Number_of_guests < *tables[b].Number_of_guests
+		}
+		return valueOf(tables[a].Table_number) < valueOf(tables[b].Table_number)
+	})
+	return tables, err
+}
+
+func (m memoryTables) Insert(ctx context.Context, table models.Table) (InsertResult, error) {
+	return m.insert(table)
+}
+
+func (m memoryTables) Update(ctx context.Context, tableId string, patch Patch) (UpdateResult, error) {
+	return m.update(tableId, patch)
+}
+
+func (m memoryTables) Claim(ctx context.Context, tableId string, claimId string, at time.Time, staleBefore time.Time) error {
+	_, err := m.modifyIf(tableId, func(table models.Table) bool {
+		return table.Seating_claim == nil || table.Seating_claim.At.Before(staleBefore)
+	}, Patch{Set: bson.M{"seating_claim": models.SeatingClaim{Claim_id: claimId, At: at}}})
+	return err
+}
+
+func (m memoryTables) Release(ctx context.Context, tableId string, claimId string) error {
+	_, err := m.update(tableId, Patch{If: bson.M{"seating_claim.claim_id": claimId}, Unset: []string{"seating_claim"}})
+	return err
+}
+
+func valueOf(number *int) int {
+	if number == nil {
+		return 0
+	}
+	return *number
+}
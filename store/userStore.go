@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UserPage is one page of users, without their password hash and tokens.
+type UserPage struct {
+	Total_count int      `json:"total_count"`
+	User_items  []bson.M `json:"user_items"`
+}
+
+// secretUserFields never leave the store in a listing.
+var secretUserFields = []string{"password", "token", "refresh_token"}
+
+type UserStore interface {
+	Get(ctx context.Context, userId string) (models.User, error)
+	ByEmail(ctx context.Context, email string) (models.User, error)
+	Page(ctx context.Context, startIndex int, recordPerPage int) (UserPage, error)
+	Find(ctx context.Context, userIds []string) ([]models.User, error)
+	// Count counts the users whose field equals value.
+	Count(ctx context.Context, field string, value interface{}) (int64, error)
+	// Insert returns ErrDuplicate when another user has the email.
+	Insert(ctx context.Context, user models.User) (InsertResult, error)
+	Update(ctx context.Context, userId string, patch Patch) (UpdateResult, error)
+}
+
+type mongoUsers struct {
+	mongoCollection[models.User]
+}
+
+func (m mongoUsers) Get(ctx context.Context, userId string) (models.User, error) {
+	return m.get(ctx, userId)
+}
+
+func (m mongoUsers) ByEmail(ctx context.Context, email string) (models.User, error) {
+	var user models.User
+	err := m.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	return user, notFound(err)
+}
+
+func (m mongoUsers) Page(ctx context.Context, startIndex int, recordPerPage int) (UserPage, error) {
+	hidden := bson.D{}
+	for _, field := range secretUserFields {
+		hidden = append(hidden, bson.E{Key: field, Value: 0})
+	}
+	hideStage := bson.D{{Key: "$project", Value: hidden}}
+	groupStage := bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: nil}, {Key: "total_count", Value: bson.D{{Key: "$sum", Value: 1}}}, {Key: "data", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}}}}}
+	projectStage := bson.D{{Key: "$project", Value: bson.D{
+		{Key: "_id", Value: 0},
+		{Key: "total_count", Value: 1},
+		{Key: "user_items", Value: bson.D{{Key: "$slice", Value: []interface{}{"$data", startIndex, recordPerPage}}}},
+	}}}
+
+	page := UserPage{User_items: []bson.M{}}
+	cursor, err := m.collection.Aggregate(ctx, mongo.Pipeline{hideStage, groupStage, projectStage})
+	if err != nil {
+		return page, err
+	}
+	var pages []UserPage
+	if err = cursor.All(ctx, &pages); err != nil {
+		return page, err
+	}
+	if len(pages) > 0 {
+		page = pages[0]
+	}
+	return page, nil
+}
+
+func (m mongoUsers) Find(ctx context.Context, userIds []string) ([]models.User, error) {
+	return m.find(ctx, bson.M{"user_id": bson.M{"$in": userIds}})
+}
+
+func (m mongoUsers) Count(ctx context.Context, field string, value interface{}) (int64, error) {
+	return m.collection.CountDocuments(ctx, bson.M{field: value})
+}
+
+func (m mongoUsers) Insert(ctx context.Context, user models.User) (InsertResult, error) {
+	result, err := m.insert(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return result, ErrDuplicate
+	}
+	return result, err
+}
+
+func (m mongoUsers) Update(ctx context.Context, userId string, patch Patch) (UpdateResult, error) {
+	return m.update(ctx, userId, patch)
+}
+
+// memoryUsers stands in for the unique index on email by checking and
+// inserting under one lock.
+type memoryUsers struct {
+	memoryCollection[models.User]
+	emails *sync.Mutex
+}
+
+func (m memoryUsers) Get(ctx context.Context, userId string) (models.User, error) {
+	return m.get(userId)
+}
+
+func (m memoryUsers) ByEmail(ctx context.Context, email string) (models.User, error) {
+	users, err := m.find(func(user models.User) bool {
+		return user.Email != nil && *user.Email == email
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	if len(users) == 0 {
+		return models.User{}, ErrNotFound
+	}
+	return users[0], nil
+}
+
+func (m memoryUsers) Page(ctx context.Context, startIndex int, recordPerPage int) (UserPage, error) {
+	page := UserPage{User_items: []bson.M{}}
+	docs, err := m.raw(nil)
+	if err != nil {
+		return page, err
+	}
+	for _, doc := range docs {
+		for _, field := range secretUserFields {
+			delete(doc, field)
+		}
+	}
+
+	page.Total_count = len(docs)
+	if startIndex < 0 {
+		startIndex = max(len(docs)+startIndex, 0)
+	}
+	if startIndex < len(docs) {
+		page.User_items = docs[startIndex:min(startIndex+recordPerPage, len(docs))]
+	}
+	return page, nil
+}
+
+func (m memoryUsers) Find(ctx context.Context, userIds []string) ([]models.User, error) {
+	return m.find(func(user models.User) bool {
+		return containsString(userIds, user.User_id)
+	})
+}
+
+func (m memoryUsers) Count(ctx context.Context, field string, value interface{}) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, stored := range *m.docs {
+		if matches(stored, bson.M{field: value}) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m memoryUsers) Insert(ctx context.Context, user models.User) (InsertResult, error) {
+	m.emails.Lock()
+	defer m.emails.Unlock()
+
+	if user.Email != nil {
+		if count, err := m.Count(ctx, "email", *user.Email); err != nil || count > 0 {
+			return InsertResult{}, duplicateOr(err)
+		}
+	}
+	return m.insert(user)
+}
+
+func (m memoryUsers) Update(ctx context.Context, userId string, patch Patch) (UpdateResult, error) {
+	return m.update(userId, patch)
+}
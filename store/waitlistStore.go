@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WaitlistStore interface {
+	Get(ctx context.Context, waitlistId string) (models.WaitlistEntry, error)
+	// Waiting returns the parties still waiting, in the order they arrived.
+	Waiting(ctx context.Context) ([]models.WaitlistEntry, error)
+	Insert(ctx context.Context, entry models.WaitlistEntry) (InsertResult, error)
+	Update(ctx context.Context, waitlistId string, patch Patch) (UpdateResult, error)
+}
+
+type mongoWaitlist struct {
+	mongoCollection[models.WaitlistEntry]
+}
+
+func (m mongoWaitlist) Get(ctx context.Context, waitlistId string) (models.WaitlistEntry, error) {
+	return m.get(ctx, waitlistId)
+}
+
+func (m mongoWaitlist) Waiting(ctx context.Context) ([]models.WaitlistEntry, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	return m.find(ctx, bson.M{"status": models.WAITLIST_WAITING}, opts)
+}
+
+func (m mongoWaitlist) Insert(ctx context.Context, entry models.WaitlistEntry) (InsertResult, error) {
+	return m.insert(ctx, entry)
+}
+
+func (m mongoWaitlist) Update(ctx context.Context, waitlistId string, patch Patch) (UpdateResult, error) {
+	return m.update(ctx, waitlistId, patch)
+}
+
+type memoryWaitlist struct {
+	memoryCollection[models.WaitlistEntry]
+}
+
+func (m memoryWaitlist) Get(ctx context.Context, waitlistId string) (models.WaitlistEntry, error) {
+	return m.get(waitlistId)
+}
+
+func (m memoryWaitlist) Waiting(ctx context.Context) ([]models.WaitlistEntry, error) {
+	entries, err := m.find(func(entry models.WaitlistEntry) bool {
+		return entry.Status != nil && *entry.Status == models.WAITLIST_WAITING
+	})
+	// insertion order already breaks ties the way sorting on _id does
+	sort.SliceStable(entries, func(a, b int) bool { return entries[a].Created_at.Before(entries[b].Created_at) })
+	return entries, err
+}
+
+func (m memoryWaitlist) Insert(ctx context.Context, entry models.WaitlistEntry) (InsertResult, error) {
+	return m.insert(entry)
+}
+
+func (m memoryWaitlist) Update(ctx context.Context, waitlistId string, patch Patch) (UpdateResult, error) {
+	return m.update(waitlistId, patch)
+}